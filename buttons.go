@@ -0,0 +1,103 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/mrdapoyo/dofi/input"
+)
+
+// KeyboardMaps are the keys for each player's buttons, players past the
+// list only get gamepads
+var KeyboardMaps = [][input.Buttons][]ebiten.Key{
+	{
+		input.Left:   {ebiten.KeyArrowLeft},
+		input.Right:  {ebiten.KeyArrowRight},
+		input.Up:     {ebiten.KeyArrowUp},
+		input.Down:   {ebiten.KeyArrowDown},
+		input.O:      {ebiten.KeyZ, ebiten.KeyC, ebiten.KeyN},
+		input.X:      {ebiten.KeyX, ebiten.KeyV, ebiten.KeyM},
+		input.Start:  {ebiten.KeyEnter}, // not p, ctrl+p is the perf overlay
+		input.Select: {ebiten.KeyBackspace},
+	},
+	{
+		input.Left:  {ebiten.KeyS},
+		input.Right: {ebiten.KeyF},
+		input.Up:    {ebiten.KeyE},
+		input.Down:  {ebiten.KeyD},
+		input.O:     {ebiten.KeyShiftLeft, ebiten.KeyTab},
+		input.X:     {ebiten.KeyA, ebiten.KeyQ},
+	},
+}
+
+// GamepadMap uses ebiten's standard layout, gamepad n is player n
+var GamepadMap = [input.Buttons][]ebiten.StandardGamepadButton{
+	input.Left:   {ebiten.StandardGamepadButtonLeftLeft},
+	input.Right:  {ebiten.StandardGamepadButtonLeftRight},
+	input.Up:     {ebiten.StandardGamepadButtonLeftTop},
+	input.Down:   {ebiten.StandardGamepadButtonLeftBottom},
+	input.O:      {ebiten.StandardGamepadButtonRightBottom, ebiten.StandardGamepadButtonRightLeft},
+	input.X:      {ebiten.StandardGamepadButtonRightRight, ebiten.StandardGamepadButtonRightTop},
+	input.Start:  {ebiten.StandardGamepadButtonCenterRight},
+	input.Select: {ebiten.StandardGamepadButtonCenterLeft},
+}
+
+// how far the left stick has to move to count as a direction
+const StickDeadzone = 0.5
+
+// pollButtons reads the keyboard and gamepads
+func pollButtons() [input.Players]input.Mask {
+	var pressed [input.Players]input.Mask
+	for p, keys := range KeyboardMaps {
+		if p >= input.Players {
+			break
+		}
+		for b, bound := range keys {
+			for _, key := range bound {
+				if ebiten.IsKeyPressed(key) {
+					pressed[p] |= 1 << b
+				}
+			}
+		}
+	}
+
+	for p, id := range ebiten.AppendGamepadIDs(nil) {
+		if p >= input.Players || !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			continue
+		}
+		for b, bound := range GamepadMap {
+			for _, button := range bound {
+				if ebiten.IsStandardGamepadButtonPressed(id, button) {
+					pressed[p] |= 1 << b
+				}
+			}
+		}
+		x := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+		y := ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical)
+		switch {
+		case x < -StickDeadzone:
+			pressed[p] |= 1 << input.Left
+		case x > StickDeadzone:
+			pressed[p] |= 1 << input.Right
+		}
+		switch {
+		case y < -StickDeadzone:
+			pressed[p] |= 1 << input.Up
+		case y > StickDeadzone:
+			pressed[p] |= 1 << input.Down
+		}
+	}
+	return pressed
+}
+
+// LatchButtons runs every tick so short taps between two frames still count
+func (g *Game) LatchButtons() {
+	for p, m := range pollButtons() {
+		g.buttonLatch[p] |= m
+	}
+}
+
+// UpdateButtons starts a new game frame for btn and btnp
+func (g *Game) UpdateButtons() {
+	g.Buttons.Update(g.replayButtons(g.buttonLatch))
+	g.buttonLatch = [input.Players]input.Mask{}
+}
@@ -0,0 +1,296 @@
+package cart
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a cartridge is plain text so it can live in git and be diffed:
+//
+//	dofi cartridge
+//	version 1
+//	__meta__
+//	title=my game
+//	__lua__
+//	function _draw() ... end
+//	__gfx__
+//	0123...   (one hex digit per pixel, 128 per row)
+//	__gff__
+//	__map__
+//	__sfx__
+//	__music__
+//
+// binary sections are written as hex rows and trailing all-zero rows are
+// dropped, so an empty sprite sheet costs nothing.
+
+const (
+	Header  = "dofi cartridge"
+	Version = 1
+)
+
+const (
+	GfxWidth  = 128
+	GfxHeight = 128
+	GfxSize   = GfxWidth * GfxHeight // one palette index per pixel
+
+	FlagsSize = 256 // one flag byte per 8x8 sprite
+
+	MapWidth  = 128
+	MapHeight = 64
+	MapSize   = MapWidth * MapHeight // one sprite index per cell
+
+	SFXCount = 64
+	SFXSize  = 68 // 32 notes * 2 bytes + 4 header bytes
+	SFXBytes = SFXCount * SFXSize
+
+	MusicCount = 64
+	MusicSize  = 4 // one byte per channel
+	MusicBytes = MusicCount * MusicSize
+)
+
+type Cart struct {
+	Meta  map[string]string
+	Code  string
+	Gfx   []byte
+	Flags []byte
+	Map   []byte
+	SFX   []byte
+	Music []byte
+}
+
+// section layout: name, row width in bytes, hex digits per byte
+type section struct {
+	name   string
+	row    int
+	digits int
+	data   func(*Cart) []byte
+}
+
+var sections = []section{
+	{"gfx", GfxWidth, 1, func(c *Cart) []byte { return c.Gfx }},
+	{"gff", 128, 2, func(c *Cart) []byte { return c.Flags }},
+	{"map", MapWidth, 2, func(c *Cart) []byte { return c.Map }},
+	{"sfx", SFXSize, 2, func(c *Cart) []byte { return c.SFX }},
+	{"music", MusicSize, 2, func(c *Cart) []byte { return c.Music }},
+}
+
+func New() *Cart {
+	return &Cart{
+		Meta:  map[string]string{},
+		Gfx:   make([]byte, GfxSize),
+		Flags: make([]byte, FlagsSize),
+		Map:   make([]byte, MapSize),
+		SFX:   make([]byte, SFXBytes),
+		Music: make([]byte, MusicBytes),
+	}
+}
+
+func Load(path string) (*Cart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+func (c *Cart) Save(path string) error {
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+func (c *Cart) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\nversion %d\n", Header, Version)
+
+	bw.WriteString("__meta__\n")
+	keys := make([]string, 0, len(c.Meta))
+	for k := range c.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(bw, "%s=%s\n", k, strings.ReplaceAll(c.Meta[k], "\n", " "))
+	}
+
+	bw.WriteString("__lua__\n")
+	if c.Code != "" {
+		bw.WriteString(c.Code)
+		if !strings.HasSuffix(c.Code, "\n") {
+			bw.WriteString("\n")
+		}
+	}
+
+	for _, s := range sections {
+		fmt.Fprintf(bw, "__%s__\n", s.name)
+		data := s.data(c)
+		rows := len(data) / s.row
+		// drop trailing empty rows
+		for rows > 0 && isZero(data[(rows-1)*s.row:rows*s.row]) {
+			rows--
+		}
+		for r := 0; r < rows; r++ {
+			bw.WriteString(encodeRow(data[r*s.row:(r+1)*s.row], s.digits))
+			bw.WriteString("\n")
+		}
+	}
+	return bw.Flush()
+}
+
+func Read(r io.Reader) (*Cart, error) {
+	c := New()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	lineNo := 0
+	next := func() (string, bool) {
+		if !sc.Scan() {
+			return "", false
+		}
+		lineNo++
+		return strings.TrimSuffix(sc.Text(), "\r"), true
+	}
+
+	if line, ok := next(); !ok || line != Header {
+		return nil, fmt.Errorf("not a dofi cartridge")
+	}
+	line, ok := next()
+	if !ok || !strings.HasPrefix(line, "version ") {
+		return nil, fmt.Errorf("line %d: missing version", lineNo)
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(line, "version "))
+	if err != nil {
+		return nil, fmt.Errorf("line %d: bad version: %v", lineNo, err)
+	}
+	if version > Version {
+		return nil, fmt.Errorf("cartridge version %d is newer than supported version %d", version, Version)
+	}
+
+	var code []string
+	current := ""
+	row := 0
+	for {
+		line, ok := next()
+		if !ok {
+			break
+		}
+		if name, isHeader := sectionName(line); isHeader {
+			current = name
+			row = 0
+			continue
+		}
+
+		switch current {
+		case "meta":
+			if line == "" {
+				continue
+			}
+			k, v, found := strings.Cut(line, "=")
+			if !found {
+				return nil, fmt.Errorf("line %d: bad meta entry %q", lineNo, line)
+			}
+			c.Meta[k] = v
+		case "lua":
+			code = append(code, line)
+		case "":
+			return nil, fmt.Errorf("line %d: data outside of a section", lineNo)
+		default:
+			s, known := lookupSection(current)
+			if !known {
+				// sections from newer tools are skipped, not fatal
+				continue
+			}
+			if line == "" {
+				continue
+			}
+			data := s.data(c)
+			if (row+1)*s.row > len(data) {
+				return nil, fmt.Errorf("line %d: too many rows in __%s__", lineNo, s.name)
+			}
+			if err := decodeRow(line, s.digits, data[row*s.row:(row+1)*s.row]); err != nil {
+				return nil, fmt.Errorf("line %d: __%s__: %v", lineNo, s.name, err)
+			}
+			row++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(code) > 0 {
+		c.Code = strings.Join(code, "\n") + "\n"
+	}
+	return c, nil
+}
+
+// sectionName reads a __name__ header. every name is a header, even ones
+// this version doesn't know, so their data ends the section before them.
+func sectionName(line string) (string, bool) {
+	if len(line) <= 4 || !strings.HasPrefix(line, "__") || !strings.HasSuffix(line, "__") {
+		return "", false
+	}
+	name := line[2 : len(line)-2]
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+func lookupSection(name string) (section, bool) {
+	for _, s := range sections {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return section{}, false
+}
+
+func encodeRow(data []byte, digits int) string {
+	if digits == 2 {
+		return hex.EncodeToString(data)
+	}
+	var sb strings.Builder
+	for _, b := range data {
+		sb.WriteByte("0123456789abcdef"[b&0x0f])
+	}
+	return sb.String()
+}
+
+func decodeRow(line string, digits int, dst []byte) error {
+	if len(line) != len(dst)*digits {
+		return fmt.Errorf("row has %d digits, want %d", len(line), len(dst)*digits)
+	}
+	if digits == 2 {
+		_, err := hex.Decode(dst, []byte(line))
+		return err
+	}
+	for i := 0; i < len(line); i++ {
+		v, err := strconv.ParseUint(line[i:i+1], 16, 8)
+		if err != nil {
+			return err
+		}
+		dst[i] = byte(v)
+	}
+	return nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
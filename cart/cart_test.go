@@ -0,0 +1,112 @@
+package cart
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	c := New()
+	c.Meta["title"] = "donut"
+	c.Meta["author"] = "poyo"
+	c.Code = "function _draw()\n  dofi.cls()\nend\n\n-- end\n"
+	c.Gfx[0] = 7
+	c.Gfx[GfxSize-1] = 15
+	c.Flags[3] = 0x81
+	c.Map[MapWidth+2] = 200
+	c.SFX[SFXSize*5+1] = 0x3f
+	c.Music[MusicBytes-1] = 0x41
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() returned error: %v", err)
+	}
+
+	if got.Code != c.Code {
+		t.Errorf("code wrong. expected=%q, got=%q", c.Code, got.Code)
+	}
+	for k, v := range c.Meta {
+		if got.Meta[k] != v {
+			t.Errorf("meta[%q] wrong. expected=%q, got=%q", k, v, got.Meta[k])
+		}
+	}
+
+	tests := []struct {
+		name     string
+		expected []byte
+		got      []byte
+	}{
+		{"gfx", c.Gfx, got.Gfx},
+		{"flags", c.Flags, got.Flags},
+		{"map", c.Map, got.Map},
+		{"sfx", c.SFX, got.SFX},
+		{"music", c.Music, got.Music},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.expected, tt.got) {
+			t.Errorf("%s section did not round-trip", tt.name)
+		}
+	}
+}
+
+func TestEmptyCartIsSmall(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New().Write(&buf); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+
+	expected := "dofi cartridge\nversion 1\n__meta__\n__lua__\n__gfx__\n__gff__\n__map__\n__sfx__\n__music__\n"
+	if buf.String() != expected {
+		t.Fatalf("empty cart wrong. expected=%q, got=%q", expected, buf.String())
+	}
+}
+
+func TestUnknownSectionsAreSkipped(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+	}{
+		{"dofi cartridge\nversion 1\n__lua__\nprint(1)\n__label__\nabcd\n", "print(1)\n"},
+		{"dofi cartridge\nversion 1\n__gff__\n" + strings.Repeat("0", 256) + "\n__label__\nabcd\n__lua__\nx=1\n", "x=1\n"},
+		{"dofi cartridge\nversion 1\n__future_1__\nanything\n\n__lua__\n", ""},
+	}
+
+	for i, tt := range tests {
+		c, err := Read(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("tests[%d] - Read() returned error: %v", i, err)
+		}
+		if c.Code != tt.code {
+			t.Fatalf("tests[%d] - code wrong. expected=%q, got=%q", i, tt.code, c.Code)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"hello", "not a dofi cartridge"},
+		{"dofi cartridge\nversion 99\n", "newer than supported"},
+		{"dofi cartridge\nversion 1\n__gfx__\n0123\n", "row has 4 digits"},
+		{"dofi cartridge\nversion 1\n__gff__\nzz" + strings.Repeat("0", 254) + "\n", "__gff__"},
+		{"dofi cartridge\nversion 1\nstray\n", "outside of a section"},
+	}
+
+	for i, tt := range tests {
+		_, err := Read(strings.NewReader(tt.input))
+		if err == nil {
+			t.Fatalf("tests[%d] - expected error containing %q, got nil", i, tt.expected)
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Fatalf("tests[%d] - error wrong. expected to contain %q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
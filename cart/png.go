@@ -0,0 +1,155 @@
+package cart
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+)
+
+// png cartridges look like a little game cartridge with the label screenshot
+// on them. every pixel carries one byte of the zlib compressed text cartridge
+// in the two low bits of each of its a, r, g and b channels.
+
+const (
+	PNGWidth  = 160
+	PNGHeight = 205
+	PNGMagic  = "DOFI"
+
+	LabelX = 16
+	LabelY = 24
+
+	pngHeaderSize = len(PNGMagic) + 4 // magic + payload length
+	PNGCapacity   = PNGWidth*PNGHeight - pngHeaderSize
+)
+
+var (
+	CartBodyColor  = color.NRGBA{204, 116, 83, 255}
+	CartShadeColor = color.NRGBA{154, 56, 63, 255}
+	CartLabelColor = color.NRGBA{70, 82, 113, 255}
+)
+
+func (c *Cart) EncodePNG(w io.Writer, label image.Image) error {
+	var text bytes.Buffer
+	if err := c.Write(&text); err != nil {
+		return err
+	}
+
+	var payload bytes.Buffer
+	payload.WriteString(PNGMagic)
+	payload.Write(make([]byte, 4))
+	zw, err := zlib.NewWriterLevel(&payload, zlib.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(text.Bytes()); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	data := payload.Bytes()
+	if len(data) > PNGWidth*PNGHeight {
+		return fmt.Errorf("cartridge too large for png: %d bytes compressed, capacity is %d", len(data)-pngHeaderSize, PNGCapacity)
+	}
+	binary.BigEndian.PutUint32(data[len(PNGMagic):], uint32(len(data)-pngHeaderSize))
+
+	img := cartImage(label)
+	for i := 0; i < PNGWidth*PNGHeight; i++ {
+		var b byte
+		if i < len(data) {
+			b = data[i]
+		}
+		off := i * 4
+		img.Pix[off+0] = img.Pix[off+0]&^3 | (b>>4)&3 // r
+		img.Pix[off+1] = img.Pix[off+1]&^3 | (b>>2)&3 // g
+		img.Pix[off+2] = img.Pix[off+2]&^3 | b&3      // b
+		img.Pix[off+3] = img.Pix[off+3]&^3 | (b>>6)&3 // a
+	}
+
+	return png.Encode(w, img)
+}
+
+func DecodePNG(r io.Reader) (*Cart, error) {
+	src, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	if bounds.Dx() != PNGWidth || bounds.Dy() != PNGHeight {
+		return nil, fmt.Errorf("png is %dx%d, cartridges are %dx%d", bounds.Dx(), bounds.Dy(), PNGWidth, PNGHeight)
+	}
+
+	// the payload lives in the raw non-premultiplied channels, so only
+	// convert when the decoder gave us something else
+	img, ok := src.(*image.NRGBA)
+	if !ok {
+		img = image.NewNRGBA(image.Rect(0, 0, PNGWidth, PNGHeight))
+		draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	data := make([]byte, PNGWidth*PNGHeight)
+	for i := range data {
+		off := i * 4
+		data[i] = (img.Pix[off+3]&3)<<6 | (img.Pix[off+0]&3)<<4 | (img.Pix[off+1]&3)<<2 | img.Pix[off+2]&3
+	}
+
+	if string(data[:len(PNGMagic)]) != PNGMagic {
+		return nil, fmt.Errorf("png does not contain a dofi cartridge")
+	}
+	size := int(binary.BigEndian.Uint32(data[len(PNGMagic):pngHeaderSize]))
+	if size > PNGCapacity {
+		return nil, fmt.Errorf("bad payload size %d", size)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data[pngHeaderSize : pngHeaderSize+size]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return Read(zr)
+}
+
+func LoadPNG(path string) (*Cart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodePNG(f)
+}
+
+func (c *Cart) SavePNG(path string, label image.Image) error {
+	var buf bytes.Buffer
+	if err := c.EncodePNG(&buf, label); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// draws the visible part: cartridge body, label frame and the screenshot
+func cartImage(label image.Image) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, PNGWidth, PNGHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(CartBodyColor), image.Point{}, draw.Src)
+
+	// grip ridges on top and a shaded strip at the bottom
+	for x := 8; x < PNGWidth-8; x += 4 {
+		draw.Draw(img, image.Rect(x, 4, x+2, 14), image.NewUniform(CartShadeColor), image.Point{}, draw.Src)
+	}
+	draw.Draw(img, image.Rect(0, PNGHeight-20, PNGWidth, PNGHeight), image.NewUniform(CartShadeColor), image.Point{}, draw.Src)
+
+	frame := image.Rect(LabelX-4, LabelY-4, LabelX+128+4, LabelY+128+4)
+	draw.Draw(img, frame, image.NewUniform(CartLabelColor), image.Point{}, draw.Src)
+	screen := image.Rect(LabelX, LabelY, LabelX+128, LabelY+128)
+	draw.Draw(img, screen, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	if label != nil {
+		draw.Draw(img, screen, label, label.Bounds().Min, draw.Over)
+	}
+	return img
+}
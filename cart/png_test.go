@@ -0,0 +1,96 @@
+package cart
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestPNGRoundTrip(t *testing.T) {
+	c := New()
+	c.Meta["title"] = "png test"
+	c.Code = "function _draw()\n  dofi.pset(1, 2, 255, 0, 0)\nend\n"
+	for i := range c.Gfx {
+		c.Gfx[i] = byte(i % 16)
+	}
+	c.Map[42] = 17
+	c.SFX[3] = 0x99
+
+	label := image.NewRGBA(image.Rect(0, 0, 128, 128))
+	label.Set(10, 10, color.RGBA{255, 0, 77, 255})
+
+	var buf bytes.Buffer
+	if err := c.EncodePNG(&buf, label); err != nil {
+		t.Fatalf("EncodePNG() returned error: %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("encoded cartridge is not a valid png: %v", err)
+	}
+	if img.Bounds().Dx() != PNGWidth || img.Bounds().Dy() != PNGHeight {
+		t.Fatalf("png size wrong. expected=%dx%d, got=%dx%d", PNGWidth, PNGHeight, img.Bounds().Dx(), img.Bounds().Dy())
+	}
+
+	// the label is still visible, give or take the two low bits
+	px := color.NRGBAModel.Convert(img.At(LabelX+10, LabelY+10)).(color.NRGBA)
+	if px.R&^3 != 255&^3 || px.G&^3 != 0 || px.B&^3 != 77&^3 {
+		t.Errorf("label pixel wrong. got=(%d, %d, %d)", px.R, px.G, px.B)
+	}
+
+	got, err := DecodePNG(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("DecodePNG() returned error: %v", err)
+	}
+	if got.Code != c.Code {
+		t.Errorf("code wrong. expected=%q, got=%q", c.Code, got.Code)
+	}
+	if got.Meta["title"] != "png test" {
+		t.Errorf("title wrong. got=%q", got.Meta["title"])
+	}
+	if !bytes.Equal(got.Gfx, c.Gfx) || !bytes.Equal(got.Map, c.Map) || !bytes.Equal(got.SFX, c.SFX) {
+		t.Errorf("binary sections did not round-trip")
+	}
+}
+
+func TestPNGWithoutLabel(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New().EncodePNG(&buf, nil); err != nil {
+		t.Fatalf("EncodePNG() returned error: %v", err)
+	}
+	if _, err := DecodePNG(&buf); err != nil {
+		t.Fatalf("DecodePNG() returned error: %v", err)
+	}
+}
+
+func TestPNGErrors(t *testing.T) {
+	plain := image.NewNRGBA(image.Rect(0, 0, PNGWidth, PNGHeight))
+	var buf bytes.Buffer
+	png.Encode(&buf, plain)
+	if _, err := DecodePNG(&buf); err == nil || !strings.Contains(err.Error(), "does not contain") {
+		t.Errorf("expected missing cartridge error, got=%v", err)
+	}
+
+	buf.Reset()
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 16, 16)))
+	if _, err := DecodePNG(&buf); err == nil || !strings.Contains(err.Error(), "16x16") {
+		t.Errorf("expected size error, got=%v", err)
+	}
+
+	// random noise doesn't compress, so it can't fit
+	c := New()
+	seed := uint32(1)
+	var code strings.Builder
+	for i := 0; i < PNGCapacity*2; i++ {
+		seed = seed*1664525 + 1013904223
+		code.WriteByte(byte('a' + seed>>24%26))
+	}
+	c.Code = code.String()
+	buf.Reset()
+	if err := c.EncodePNG(&buf, nil); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("expected too large error, got=%v", err)
+	}
+}
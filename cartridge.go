@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrdapoyo/dofi/cart"
+)
+
+const CartExtension = ".dofi"
+
+func cartPath(name string) string {
+	if filepath.Ext(name) == "" {
+		return name + CartExtension
+	}
+	return name
+}
+
+// collects whatever the editor tabs currently hold into g.Cart
+func (g *Game) StoreCart() *cart.Cart {
+	if g.Cart == nil {
+		g.Cart = cart.New()
+	}
+	if editor, exists := CodeEditors[CodeEditorIndex]; exists {
+		g.Cart.Code = strings.Join(editor.Content, "\n")
+	}
+	copy(g.Cart.Gfx, g.Sprites.Pixels.Pix)
+	copy(g.Cart.Flags, g.Sprites.Flags)
+	copy(g.Cart.Map, g.Map.Cells)
+	copy(g.Cart.SFX, g.Sound.SFX)
+	copy(g.Cart.Music, g.Sound.Music)
+	return g.Cart
+}
+
+// puts a cartridge into the editor tabs
+func (g *Game) OpenCart(c *cart.Cart) {
+	g.Cart = c
+	copy(g.Sprites.Pixels.Pix, c.Gfx)
+	copy(g.Sprites.Flags, c.Flags)
+	copy(g.Map.Cells, c.Map)
+	copy(g.Sound.SFX, c.SFX)
+	copy(g.Sound.Music, c.Music)
+	content := strings.Split(strings.TrimSuffix(c.Code, "\n"), "\n")
+	editor := NewCodeEditor(content)
+	editor.Saved = true
+	CodeEditors[CodeEditorIndex] = editor
+}
+
+func (g *Game) SaveCart(name string) error {
+	path := cartPath(name)
+	c := g.StoreCart()
+	if _, exists := c.Meta["title"]; !exists {
+		c.Meta["title"] = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := c.Save(path); err != nil {
+		return err
+	}
+	if editor, exists := CodeEditors[CodeEditorIndex]; exists {
+		editor.Saved = true
+	}
+	return nil
+}
+
+func (g *Game) LoadCart(name string) error {
+	path := cartPath(name)
+	load := cart.Load
+	if strings.EqualFold(filepath.Ext(path), ".png") {
+		load = cart.LoadPNG
+	}
+	c, err := load(path)
+	if err != nil {
+		return err
+	}
+	g.OpenCart(c)
+	return nil
+}
+
+// ExportCart writes the cartridge as a png, adding the extension if path
+// has none, and returns where it went
+func (g *Game) ExportCart(path string) (string, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".png") {
+		path += ".png"
+	}
+	return path, g.StoreCart().SavePNG(path, g.LabelImage())
+}
+
+// screenshot of the framebuffer, used as the png cartridge label
+func (g *Game) LabelImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, g.Screen.Buffer.Width, g.Screen.Buffer.Height))
+	g.Screen.Buffer.RGBA(img.Pix, &g.Screen.Palette, &g.DrawState.DisplayPal)
+	return img
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/mrdapoyo/dofi/clipboard"
+)
+
+// pendingPaste waits for the clipboard, it answers a frame or more after
+// it's asked
+type pendingPaste struct {
+	result <-chan clipboard.Result
+	editor *CodeEditor // nil pastes into the cli
+}
+
+// systemClipboard is the platform's clipboard, one inside dofi if there's
+// none
+func (g *Game) systemClipboard() clipboard.Clipboard {
+	if g.Clipboard == nil {
+		c, err := clipboard.DefaultClipboard()
+		if err != nil {
+			log.Println("copy and paste stay inside dofi:", err)
+			c = &clipboard.Memory{}
+		}
+		g.Clipboard = c
+	}
+	return g.Clipboard
+}
+
+func (g *Game) copyText(text string) {
+	if err := g.systemClipboard().Write(text); err != nil {
+		log.Println("copy failed:", err)
+	}
+}
+
+// startPaste asks the clipboard for its text, editor nil pastes into the cli
+func (g *Game) startPaste(editor *CodeEditor) {
+	if g.paste != nil {
+		return
+	}
+	g.paste = &pendingPaste{result: g.systemClipboard().Read(), editor: editor}
+}
+
+// UpdatePaste puts the pasted text in once the clipboard has answered
+func (g *Game) UpdatePaste() {
+	if g.paste == nil {
+		return
+	}
+	var r clipboard.Result
+	select {
+	case r = <-g.paste.result:
+	default:
+		return
+	}
+	editor := g.paste.editor
+	g.paste = nil
+	if r.Err != nil {
+		log.Println("paste failed:", r.Err)
+		return
+	}
+	if r.Text == "" {
+		return
+	}
+	if editor != nil {
+		editor.Insert(r.Text)
+		editor.Saved = false
+		return
+	}
+	// the cli is one line, take the first
+	line, _, _ := strings.Cut(strings.ReplaceAll(r.Text, "\r\n", "\n"), "\n")
+	g.Input.CurrentInputString += line
+}
+
+// UpdateCliClipboard is ctrl+c, ctrl+x and ctrl+v on the cli input line
+func (g *Game) UpdateCliClipboard() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyC):
+		g.copyText(g.Input.CurrentInputString)
+	case inpututil.IsKeyJustPressed(ebiten.KeyX):
+		g.copyText(g.Input.CurrentInputString)
+		g.Input.CurrentInputString = ""
+	case inpututil.IsKeyJustPressed(ebiten.KeyV):
+		g.startPaste(nil)
+	}
+}
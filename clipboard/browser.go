@@ -0,0 +1,61 @@
+//go:build js
+
+package clipboard
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// Browser uses navigator.clipboard. the browser can refuse it, it needs a
+// secure page and may ask the player first, so what was copied is kept too
+// and pasted when reading fails.
+type Browser struct {
+	last Memory
+}
+
+// DefaultClipboard uses the browser's clipboard
+func DefaultClipboard() (Clipboard, error) {
+	if api := js.Global().Get("navigator").Get("clipboard"); api.IsUndefined() || api.IsNull() {
+		return nil, errors.New("navigator.clipboard is not available")
+	}
+	return &Browser{}, nil
+}
+
+func (b *Browser) Read() <-chan Result {
+	api := js.Global().Get("navigator").Get("clipboard")
+	if api.Get("readText").Type() != js.TypeFunction {
+		// some browsers only let pages write
+		return b.last.Read()
+	}
+	ch := make(chan Result, 1)
+	fallback := b.last.text
+	var resolve, reject js.Func
+	settle := func(r Result) {
+		ch <- r
+		resolve.Release()
+		reject.Release()
+	}
+	resolve = js.FuncOf(func(this js.Value, args []js.Value) any {
+		settle(Result{Text: args[0].String()})
+		return nil
+	})
+	reject = js.FuncOf(func(this js.Value, args []js.Value) any {
+		settle(Result{Text: fallback})
+		return nil
+	})
+	api.Call("readText").Call("then", resolve, reject)
+	return ch
+}
+
+func (b *Browser) Write(text string) (err error) {
+	b.last.Write(text)
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("clipboard: cannot write")
+		}
+	}()
+	// the promise is left alone, a refused write still has b.last
+	js.Global().Get("navigator").Get("clipboard").Call("writeText", text)
+	return nil
+}
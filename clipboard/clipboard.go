@@ -0,0 +1,39 @@
+package clipboard
+
+// copy and paste for the code editor and the cli. desktop builds run the
+// platform's clipboard tools, the browser build uses navigator.clipboard,
+// and Memory stands in when neither works.
+
+// Clipboard is where copied text goes. Read answers on a channel because
+// the browser's clipboard only answers with a promise, and the desktop's
+// tools take a while to run.
+type Clipboard interface {
+	Read() <-chan Result
+	Write(text string) error
+}
+
+type Result struct {
+	Text string
+	Err  error
+}
+
+// done is a Read that already has its answer
+func done(text string, err error) <-chan Result {
+	ch := make(chan Result, 1)
+	ch <- Result{text, err}
+	return ch
+}
+
+// Memory is a clipboard inside the program, other programs can't see it
+type Memory struct {
+	text string
+}
+
+func (m *Memory) Read() <-chan Result {
+	return done(m.text, nil)
+}
+
+func (m *Memory) Write(text string) error {
+	m.text = text
+	return nil
+}
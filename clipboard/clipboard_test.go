@@ -0,0 +1,14 @@
+package clipboard
+
+import "testing"
+
+func TestMemory(t *testing.T) {
+	var m Memory
+	tests := []string{"", "x = 1", "line one\nline two"}
+	for i, text := range tests {
+		m.Write(text)
+		if got := <-m.Read(); got.Text != text || got.Err != nil {
+			t.Fatalf("tests[%d] - read wrong. expected=%q, got=%+v", i, text, got)
+		}
+	}
+}
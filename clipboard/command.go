@@ -0,0 +1,129 @@
+//go:build !js
+
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long the copy or paste tool may take before the text copied
+// inside dofi is pasted instead
+const DefaultTimeout = 2 * time.Second
+
+// Command copies and pastes by running the platform's clipboard tools. what
+// was copied is kept too, so paste still works when the tools fail, like
+// over ssh without a display.
+type Command struct {
+	Copy    []string      // reads the text on stdin
+	Paste   []string      // writes the text on stdout
+	Timeout time.Duration // how long Copy or Paste may run, 0 is DefaultTimeout
+
+	last    Memory
+	copying chan struct{} // closed once the last copy tool is done
+}
+
+// DefaultClipboard finds the clipboard tools for this platform
+func DefaultClipboard() (Clipboard, error) {
+	var tools [][2][]string
+	switch runtime.GOOS {
+	case "darwin":
+		tools = [][2][]string{{{"pbcopy"}, {"pbpaste"}}}
+	case "windows":
+		tools = [][2][]string{{
+			{"powershell", "-NoProfile", "-Command", "$input | Set-Clipboard"},
+			{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+		}}
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			tools = append(tools, [2][]string{{"wl-copy"}, {"wl-paste", "--no-newline"}})
+		}
+		tools = append(tools,
+			[2][]string{{"xclip", "-selection", "clipboard"}, {"xclip", "-selection", "clipboard", "-o"}},
+			[2][]string{{"xsel", "--clipboard", "--input"}, {"xsel", "--clipboard", "--output"}},
+		)
+	}
+	for _, t := range tools {
+		if _, err := exec.LookPath(t[0][0]); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath(t[1][0]); err != nil {
+			continue
+		}
+		return &Command{Copy: t[0], Paste: t[1]}, nil
+	}
+	return nil, errors.New("no clipboard tool found")
+}
+
+// Read runs the paste tool in the background so a slow or stuck one doesn't
+// hold up the game loop, the answer comes on a later frame
+func (c *Command) Read() <-chan Result {
+	ch := make(chan Result, 1)
+	fallback := c.last.text
+	timeout := c.timeout()
+	copying := c.copying
+	args := c.Paste
+	go func() {
+		// a copy still running would be pasted from before it
+		if copying != nil {
+			<-copying
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		// children the tool left behind can keep stdout open
+		cmd.WaitDelay = timeout / 4
+		out, err := cmd.Output()
+		if err != nil {
+			ch <- Result{Text: fallback}
+			return
+		}
+		// powershell ends its output with a newline of its own
+		text := string(out)
+		if runtime.GOOS == "windows" {
+			text = strings.TrimSuffix(text, "\r\n")
+		}
+		ch <- Result{Text: text}
+	}()
+	return ch
+}
+
+// Write keeps the text and runs the copy tool in the background, like Read.
+// the tool failing is only logged, pasting inside dofi still works.
+func (c *Command) Write(text string) error {
+	c.last.Write(text)
+	timeout := c.timeout()
+	copying := make(chan struct{})
+	prev := c.copying
+	c.copying = copying
+	args := c.Copy
+	go func() {
+		defer close(copying)
+		// copies land in the order they were made
+		if prev != nil {
+			<-prev
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		cmd.WaitDelay = timeout / 4
+		if err := cmd.Run(); err != nil {
+			log.Println("copy failed:", err)
+		}
+	}()
+	return nil
+}
+
+func (c *Command) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
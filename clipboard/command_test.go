@@ -0,0 +1,84 @@
+//go:build !js
+
+package clipboard
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	file := filepath.Join(t.TempDir(), "clip")
+	c := &Command{Copy: []string{"sh", "-c", "cat > " + file}, Paste: []string{"cat", file}}
+
+	text := "print(\"hi\")\n  x = 1"
+	if err := c.Write(text); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if got := <-c.Read(); got.Text != text || got.Err != nil {
+		t.Fatalf("read wrong. expected=%q, got=%+v", text, got)
+	}
+
+	// a paste tool that fails gives back what was copied here
+	c.Paste = []string{"sh", "-c", "exit 1"}
+	if got := <-c.Read(); got.Text != text {
+		t.Fatalf("fallback read wrong. expected=%q, got=%+v", text, got)
+	}
+
+	// so does one that never finishes, once it times out
+	c.Paste = []string{"sh", "-c", "sleep 10"}
+	c.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	ch := c.Read()
+	if time.Since(start) > c.Timeout {
+		t.Fatalf("read waited for the paste tool")
+	}
+	if got := <-ch; got.Text != text {
+		t.Fatalf("timed out read wrong. expected=%q, got=%+v", text, got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("read didn't time out. took=%v", elapsed)
+	}
+}
+
+func TestCommandWrite(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	file := filepath.Join(t.TempDir(), "clip")
+	c := &Command{
+		Copy:    []string{"sh", "-c", "sleep 0.2; cat > " + file},
+		Paste:   []string{"cat", file},
+		Timeout: time.Second,
+	}
+
+	// a slow copy tool doesn't hold up the caller
+	start := time.Now()
+	c.Write("first")
+	c.Write("second")
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("write waited for the copy tool. took=%v", elapsed)
+	}
+	// but a paste after it waits for the last copy to land
+	if got := <-c.Read(); got.Text != "second" {
+		t.Fatalf("read wrong. expected=%q, got=%+v", "second", got)
+	}
+
+	// one that never finishes is stopped, and paste still works
+	c.Copy = []string{"sh", "-c", "sleep 10"}
+	c.Paste = []string{"sh", "-c", "exit 1"}
+	c.Timeout = 100 * time.Millisecond
+	start = time.Now()
+	c.Write("third")
+	if got := <-c.Read(); got.Text != "third" {
+		t.Fatalf("fallback read wrong. expected=%q, got=%+v", "third", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("write didn't time out. took=%v", elapsed)
+	}
+}
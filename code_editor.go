@@ -0,0 +1,227 @@
+package main
+
+import (
+	"image"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/mrdapoyo/dofi/edit"
+)
+
+// held keys repeat after keyRepeatDelay ticks, then every keyRepeatInterval
+const (
+	keyRepeatDelay    = 24
+	keyRepeatInterval = 3
+)
+
+const (
+	codeWheelRows      = 3 // rows one wheel step scrolls
+	codeScrollbarWidth = 2
+	codeScrollbarGrab  = 5 // the scrollbar takes clicks a little wider than it's drawn
+)
+
+func NewCodeEditor(content []string) *CodeEditor {
+	if len(content) == 0 {
+		content = []string{""}
+	}
+	return &CodeEditor{Buffer: edit.Buffer{Content: content}}
+}
+
+// keyRepeated is true on the tick a key goes down and while it repeats
+func keyRepeated(key ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(key)
+	if d == 1 {
+		return true
+	}
+	return d > keyRepeatDelay && (d-keyRepeatDelay)%keyRepeatInterval == 0
+}
+
+var codeEditorMotions = []struct {
+	key  ebiten.Key
+	ctrl edit.Motion
+	move edit.Motion
+}{
+	{ebiten.KeyLeft, edit.WordLeft, edit.Left},
+	{ebiten.KeyRight, edit.WordRight, edit.Right},
+	{ebiten.KeyUp, edit.Up, edit.Up},
+	{ebiten.KeyDown, edit.Down, edit.Down},
+	{ebiten.KeyHome, edit.Top, edit.Home},
+	{ebiten.KeyEnd, edit.Bottom, edit.End},
+	{ebiten.KeyPageUp, edit.PageUp, edit.PageUp},
+	{ebiten.KeyPageDown, edit.PageDown, edit.PageDown},
+}
+
+func (g *Game) UpdateCodeEditor(editor *CodeEditor, chars []rune) {
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+	editor.Clamp()
+
+	for _, m := range codeEditorMotions {
+		if keyRepeated(m.key) {
+			if ctrl {
+				editor.Move(m.ctrl, shift)
+			} else {
+				editor.Move(m.move, shift)
+			}
+		}
+	}
+
+	if ctrl {
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyA):
+			editor.SelectAll()
+		case inpututil.IsKeyJustPressed(ebiten.KeyC):
+			if text := editor.SelectedText(); text != "" {
+				g.copyText(text)
+			}
+		case inpututil.IsKeyJustPressed(ebiten.KeyX):
+			if text := editor.SelectedText(); text != "" {
+				g.copyText(text)
+				editor.DeleteSelection()
+				editor.Saved = false
+			}
+		case inpututil.IsKeyJustPressed(ebiten.KeyV):
+			g.startPaste(editor)
+		case keyRepeated(ebiten.KeyZ) && shift, keyRepeated(ebiten.KeyY):
+			if editor.Redo() {
+				editor.Saved = false
+			}
+		case keyRepeated(ebiten.KeyZ):
+			if editor.Undo() {
+				editor.Saved = false
+			}
+		}
+		return
+	}
+
+	edited := false
+	for _, r := range chars {
+		// enter and tab come through as keys below
+		if r < 0x20 || r == 0x7f {
+			continue
+		}
+		editor.Type(string(r))
+		edited = true
+	}
+
+	switch {
+	case keyRepeated(ebiten.KeyEnter):
+		editor.Newline()
+	case keyRepeated(ebiten.KeyBackspace):
+		editor.Backspace()
+	case keyRepeated(ebiten.KeyDelete):
+		editor.Delete()
+	case keyRepeated(ebiten.KeyTab) && shift:
+		editor.DedentLines()
+	case keyRepeated(ebiten.KeyTab):
+		editor.IndentLines()
+	default:
+		if !edited {
+			return
+		}
+	}
+	editor.Saved = false
+}
+
+// codeLayout is where the code tab puts an editor's lines. long lines wrap
+// onto several rows, and ScrollY counts rows, not lines.
+type codeLayout struct {
+	gutter int   // width of the line numbers, 0 when they're off
+	width  int   // what the text wraps at
+	rows   int   // rows that fit on screen
+	starts []int // first row of every line, then the total
+}
+
+func (g *Game) codeLayout(editor *CodeEditor, navbarHeight int) codeLayout {
+	lineHeight := g.Screen.FontSize + 2
+	l := codeLayout{rows: max((g.Screen.Height-navbarHeight)/lineHeight, 1)}
+	if g.LineNumbers {
+		l.gutter = len(strconv.Itoa(len(editor.Content)))*(g.Screen.FontWidth+1) + 2
+	}
+	l.width = g.Screen.Width - l.gutter
+	l.starts = make([]int, len(editor.Content)+1)
+	for i, line := range editor.Content {
+		l.starts[i+1] = l.starts[i] + len(g.wrapText(line, l.width))
+	}
+	return l
+}
+
+func (l codeLayout) total() int {
+	return l.starts[len(l.starts)-1]
+}
+
+// wrappedColumn is the row of a wrapped line that column lands on, and the
+// column within that row. the end of a full row stays on it.
+func (g *Game) wrappedColumn(line string, column, width int) (row, col int) {
+	processed := 0
+	for i, segment := range g.wrapText(line, width) {
+		if column <= processed+len(segment) {
+			return i, column - processed
+		}
+		processed += len(segment)
+	}
+	return 0, column
+}
+
+// cursorRow is the row of the cursor, counted from the top of the text
+func (g *Game) cursorRow(editor *CodeEditor, l codeLayout) int {
+	if editor.Line >= len(editor.Content) {
+		return 0
+	}
+	row, _ := g.wrappedColumn(editor.Content[editor.Line], editor.Column, l.width)
+	return l.starts[editor.Line] + row
+}
+
+// scrollbar is the part of the scrollbar showing which rows are on screen,
+// empty when everything fits
+func (l codeLayout) scrollbar(scrollY int, bounds image.Rectangle) image.Rectangle {
+	total := l.total()
+	if total <= l.rows {
+		return image.Rectangle{}
+	}
+	h := bounds.Dy()
+	top := h * scrollY / total
+	bottom := max(h*(scrollY+l.rows)/total, top+3)
+	return image.Rect(bounds.Max.X-codeScrollbarWidth, top, bounds.Max.X, bottom)
+}
+
+// ScrollCodeEditor scrolls with the wheel and the scrollbar, and back to the
+// cursor whenever it has moved
+func (g *Game) ScrollCodeEditor(editor *CodeEditor) {
+	l := g.codeLayout(editor, g.Navbar.NavbarHeight)
+	editor.PageSize = l.rows
+	height := g.Screen.Height - g.Navbar.NavbarHeight
+
+	if _, wheel := ebiten.Wheel(); wheel > 0 {
+		editor.ScrollY -= codeWheelRows
+	} else if wheel < 0 {
+		editor.ScrollY += codeWheelRows
+	}
+
+	mx, my := g.contentMouse()
+	track := image.Rect(g.Screen.Width-codeScrollbarGrab, 0, g.Screen.Width, height)
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && image.Pt(mx, my).In(track) && l.total() > l.rows {
+		editor.scrolling = true
+	}
+	if editor.scrolling {
+		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			// the middle of the bar follows the mouse
+			editor.ScrollY = my*l.total()/max(height, 1) - l.rows/2
+		} else {
+			editor.scrolling = false
+		}
+	}
+
+	if cursor := editor.Cursor(); cursor != editor.shown {
+		editor.shown = cursor
+		row := g.cursorRow(editor, l)
+		if row < editor.ScrollY {
+			editor.ScrollY = row
+		} else if row >= editor.ScrollY+l.rows {
+			editor.ScrollY = row - l.rows + 1
+		}
+	}
+	editor.ScrollY = max(min(editor.ScrollY, l.total()-l.rows), 0)
+}
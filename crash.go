@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/mrdapoyo/dofi/sandbox"
+)
+
+// CrashReport is what the crash screen shows after a lua error
+type CrashReport struct {
+	Where  string // callback that failed, empty while loading
+	Err    *sandbox.RuntimeError
+	Source string // the line the error points at
+	Editor bool   // the code came from the code editor, so the line can be opened
+}
+
+// FailCart stops a run after an error. going over a limit is reported in the
+// cli, lua errors get the crash screen.
+func (g *Game) FailCart(where string, err error) {
+	if sandbox.IsLimit(err) {
+		g.AbortCart(err)
+		return
+	}
+	log.Println("Lua error:", err)
+	g.leaveCart()
+
+	report := &CrashReport{
+		Where:  where,
+		Err:    sandbox.ParseError(err),
+		Editor: g.Play.FromEditor,
+	}
+	if line := report.Err.Line; line > 0 && line <= len(g.Play.Source) {
+		report.Source = strings.TrimSpace(g.Play.Source[line-1])
+	}
+	g.Crash = report
+
+	if g.Play.ReturnCli {
+		g.AppendLine("Lua error: "+report.Err.Error(), false)
+		g.AppendLine("", true)
+	}
+}
+
+// UpdateCrash waits for enter to open the failing line or escape to go back
+func (g *Game) UpdateCrash() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter) && g.Crash.Editor && g.Crash.Err.Line > 0:
+		g.JumpToLine(g.Crash.Err.Line)
+		g.Crash = nil
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.Crash = nil
+	}
+}
+
+// JumpToLine opens the code editor with the cursor at the start of line n
+func (g *Game) JumpToLine(n int) {
+	editor, exists := CodeEditors[CodeEditorIndex]
+	if !exists || len(editor.Content) == 0 {
+		return
+	}
+	if code := g.TabIndex("code"); code >= 0 {
+		g.Navbar.PreviousTab = g.Navbar.CurrentTab
+		g.Navbar.CurrentTab = code
+	}
+	g.Navbar.CliEnabled = false
+
+	editor.Line = min(max(n-1, 0), len(editor.Content)-1)
+	line := editor.Content[editor.Line]
+	editor.Column = len(line) - len(strings.TrimLeft(line, " \t"))
+	editor.ClearSelection()
+}
+
+func (g *Game) DrawCrash(screen *ebiten.Image) {
+	c := g.Crash
+	screen.Fill(g.Screen.CliBgColor)
+	lineHeight := g.Screen.FontSize + 2
+	footer := g.Screen.Height - lineHeight - 2
+	red := g.Screen.Palette[8]
+	white := g.Screen.Palette[7]
+	grey := g.Screen.Palette[6]
+	yellow := g.Screen.Palette[10]
+
+	y := 2
+	// draws wrapped text, at most limit lines, keeping clear of the footer
+	write := func(value string, col color.Color, limit int) {
+		for i, line := range g.wrapText(value, g.Screen.Width) {
+			if i >= limit || y+lineHeight > footer {
+				return
+			}
+			drawLabel(screen, 2, y, line, col)
+			y += lineHeight
+		}
+	}
+
+	title := "runtime error"
+	if c.Err.Syntax {
+		title = "syntax error"
+	}
+	if c.Where != "" {
+		title += " in " + c.Where
+	}
+	write(title, red, 1)
+	write(c.Err.Message, white, 5)
+	y += 2
+
+	if c.Err.Line > 0 {
+		write(fmt.Sprintf("line %d:", c.Err.Line), grey, 1)
+		write(c.Source, yellow, 2)
+		y += 2
+	}
+
+	if len(c.Err.Traceback) > 0 {
+		write("traceback:", grey, 1)
+		for _, frame := range c.Err.Traceback {
+			write(frame, white, 2)
+		}
+	}
+
+	if c.Editor && c.Err.Line > 0 {
+		drawLabel(screen, 2, footer, "enter: edit line  esc: back", grey)
+	} else {
+		drawLabel(screen, 2, footer, "esc: back", grey)
+	}
+}
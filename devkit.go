@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/mrdapoyo/dofi/input"
+)
+
+// Devkit gives scripts the raw mouse and keyboard, for tools made in dofi.
+// scripts turn it on with devkit(true).
+type Devkit struct {
+	Enabled bool
+	Mouse   input.Mouse
+	Keys    input.Keyboard
+
+	// collected every tick until the next frame
+	held    map[ebiten.Key]bool
+	buttons uint8
+	typed   []rune
+	wheel   float64
+}
+
+// keys by lowercase name, like "a", "space", "arrowleft" or "left"
+var keyNames = map[string]ebiten.Key{}
+
+func init() {
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		keyNames[strings.ToLower(k.String())] = k
+	}
+	for i := 0; i <= 9; i++ {
+		keyNames[string(rune('0'+i))] = ebiten.KeyDigit0 + ebiten.Key(i)
+	}
+	aliases := map[string]ebiten.Key{
+		"left":   ebiten.KeyArrowLeft,
+		"right":  ebiten.KeyArrowRight,
+		"up":     ebiten.KeyArrowUp,
+		"down":   ebiten.KeyArrowDown,
+		"ctrl":   ebiten.KeyControl,
+		"esc":    ebiten.KeyEscape,
+		"return": ebiten.KeyEnter,
+		"del":    ebiten.KeyDelete,
+	}
+	for name, k := range aliases {
+		keyNames[name] = k
+	}
+}
+
+func keyByName(name string) (ebiten.Key, bool) {
+	k, ok := keyNames[strings.ToLower(name)]
+	return k, ok
+}
+
+// LatchDevkit runs every tick so nothing typed between frames is lost
+func (g *Game) LatchDevkit() {
+	d := &g.Devkit
+	if !d.Enabled {
+		return
+	}
+	if d.held == nil {
+		d.held = map[ebiten.Key]bool{}
+	}
+	for _, k := range inpututil.AppendPressedKeys(nil) {
+		d.held[k] = true
+	}
+	d.typed = ebiten.AppendInputChars(d.typed)
+	// control keys come through as characters too, like pico-8
+	for k, r := range map[ebiten.Key]rune{ebiten.KeyEnter: '\r', ebiten.KeyBackspace: '\b', ebiten.KeyTab: '\t'} {
+		if inpututil.IsKeyJustPressed(k) {
+			d.typed = append(d.typed, r)
+		}
+	}
+
+	mouseButtons := map[ebiten.MouseButton]uint8{
+		ebiten.MouseButtonLeft:   input.MouseLeft,
+		ebiten.MouseButtonRight:  input.MouseRight,
+		ebiten.MouseButtonMiddle: input.MouseMiddle,
+	}
+	for b, bit := range mouseButtons {
+		if ebiten.IsMouseButtonPressed(b) {
+			d.buttons |= bit
+		}
+	}
+	_, dy := ebiten.Wheel()
+	d.wheel += dy
+}
+
+// UpdateDevkit starts a new frame for mouse(), key() and keyp()
+func (g *Game) UpdateDevkit() {
+	d := &g.Devkit
+	if !d.Enabled {
+		return
+	}
+	// the layout is the framebuffer size, so this is already in pixels
+	x, y := ebiten.CursorPosition()
+	steps := int(d.wheel)
+	d.wheel -= float64(steps)
+	d.Mouse.Update(x, y, d.buttons, steps)
+	g.Input.MouseX, g.Input.MouseY = x, y
+
+	held := make([]int, 0, len(d.held))
+	for k := range d.held {
+		held = append(held, int(k))
+	}
+	d.Keys.Update(held, d.typed)
+
+	d.held = map[ebiten.Key]bool{}
+	d.buttons = 0
+	d.typed = d.typed[:0]
+}
@@ -0,0 +1,491 @@
+package edit
+
+import "strings"
+
+// the code editor's text and cursor, without any ebiten so it can be tested.
+// columns are byte offsets into the line.
+
+// Pos is a place between two characters
+type Pos struct {
+	Line   int
+	Column int
+}
+
+func (p Pos) Before(q Pos) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
+
+const DefaultIndent = "    "
+
+type Motion int
+
+const (
+	Left Motion = iota
+	Right
+	Up
+	Down
+	Home // first non blank character, then the start of the line
+	End
+	PageUp
+	PageDown
+	WordLeft
+	WordRight
+	Top // start of the buffer
+	Bottom
+)
+
+type Buffer struct {
+	Content  []string
+	Line     int
+	Column   int
+	Indent   string // what tab inserts, DefaultIndent when empty
+	PageSize int    // lines PageUp and PageDown move
+
+	anchor    Pos // other end of the selection
+	selecting bool
+	goal      int // column Up and Down keep to
+	hasGoal   bool
+	history   History
+	editing   bool // inside an edit, see edit
+}
+
+func (b *Buffer) Cursor() Pos {
+	return Pos{b.Line, b.Column}
+}
+
+func (b *Buffer) setCursor(p Pos) {
+	b.Line, b.Column = p.Line, p.Column
+}
+
+func (b *Buffer) indent() string {
+	if b.Indent == "" {
+		return DefaultIndent
+	}
+	return b.Indent
+}
+
+// Clamp keeps the cursor inside the text, there's always at least one line
+func (b *Buffer) Clamp() {
+	if len(b.Content) == 0 {
+		b.Content = []string{""}
+	}
+	b.Line = min(max(b.Line, 0), len(b.Content)-1)
+	b.Column = min(max(b.Column, 0), len(b.Content[b.Line]))
+	b.anchor.Line = min(max(b.anchor.Line, 0), len(b.Content)-1)
+	b.anchor.Column = min(max(b.anchor.Column, 0), len(b.Content[b.anchor.Line]))
+}
+
+// Selection is the selected range in order, ok is false when nothing is
+// selected
+func (b *Buffer) Selection() (start, end Pos, ok bool) {
+	if !b.selecting {
+		return b.Cursor(), b.Cursor(), false
+	}
+	start, end = b.anchor, b.Cursor()
+	if end.Before(start) {
+		start, end = end, start
+	}
+	return start, end, start != end
+}
+
+func (b *Buffer) ClearSelection() {
+	b.selecting = false
+}
+
+func (b *Buffer) SelectAll() {
+	b.Clamp()
+	b.anchor = Pos{}
+	b.selecting = true
+	last := len(b.Content) - 1
+	b.setCursor(Pos{last, len(b.Content[last])})
+	b.hasGoal = false
+}
+
+// Text is the text between start and end, lines joined with \n
+func (b *Buffer) Text(start, end Pos) string {
+	if start.Line == end.Line {
+		return b.Content[start.Line][start.Column:end.Column]
+	}
+	parts := []string{b.Content[start.Line][start.Column:]}
+	parts = append(parts, b.Content[start.Line+1:end.Line]...)
+	parts = append(parts, b.Content[end.Line][:end.Column])
+	return strings.Join(parts, "\n")
+}
+
+func (b *Buffer) SelectedText() string {
+	start, end, ok := b.Selection()
+	if !ok {
+		return ""
+	}
+	return b.Text(start, end)
+}
+
+// Move moves the cursor, extending the selection when extend is set
+func (b *Buffer) Move(m Motion, extend bool) {
+	b.Clamp()
+	if extend && !b.selecting {
+		b.anchor = b.Cursor()
+		b.selecting = true
+	}
+	if !extend && b.selecting {
+		start, end, ok := b.Selection()
+		b.selecting = false
+		// left and right drop the selection at its edge
+		if ok && (m == Left || m == Right) {
+			if m == Left {
+				b.setCursor(start)
+			} else {
+				b.setCursor(end)
+			}
+			b.hasGoal = false
+			return
+		}
+	}
+
+	switch m {
+	case Up, Down, PageUp, PageDown:
+		b.moveLines(m)
+		return
+	}
+	b.hasGoal = false
+
+	line := b.Content[b.Line]
+	switch m {
+	case Left:
+		if b.Column > 0 {
+			b.Column--
+		} else if b.Line > 0 {
+			b.Line--
+			b.Column = len(b.Content[b.Line])
+		}
+	case Right:
+		if b.Column < len(line) {
+			b.Column++
+		} else if b.Line < len(b.Content)-1 {
+			b.Line++
+			b.Column = 0
+		}
+	case Home:
+		if indent := leadingSpace(line); b.Column != indent {
+			b.Column = indent
+		} else {
+			b.Column = 0
+		}
+	case End:
+		b.Column = len(line)
+	case WordLeft:
+		b.wordLeft()
+	case WordRight:
+		b.wordRight()
+	case Top:
+		b.setCursor(Pos{})
+	case Bottom:
+		last := len(b.Content) - 1
+		b.setCursor(Pos{last, len(b.Content[last])})
+	}
+}
+
+// moveLines goes up or down keeping to the column the move started from,
+// past short lines
+func (b *Buffer) moveLines(m Motion) {
+	if !b.hasGoal {
+		b.goal = b.Column
+		b.hasGoal = true
+	}
+	page := max(b.PageSize, 1)
+	last := len(b.Content) - 1
+	switch m {
+	case Up:
+		if b.Line == 0 {
+			b.Column = 0
+			return
+		}
+		b.Line--
+	case Down:
+		if b.Line == last {
+			b.Column = len(b.Content[last])
+			return
+		}
+		b.Line++
+	case PageUp:
+		b.Line = max(b.Line-page, 0)
+	case PageDown:
+		b.Line = min(b.Line+page, last)
+	}
+	b.Column = min(b.goal, len(b.Content[b.Line]))
+}
+
+func leadingSpace(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// character classes for word movement
+func class(c byte) int {
+	switch {
+	case c == ' ' || c == '\t':
+		return 0
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_':
+		return 1
+	}
+	return 2
+}
+
+func (b *Buffer) wordLeft() {
+	if b.Column == 0 {
+		if b.Line > 0 {
+			b.Line--
+			b.Column = len(b.Content[b.Line])
+		}
+		return
+	}
+	line := b.Content[b.Line]
+	i := b.Column
+	for i > 0 && class(line[i-1]) == 0 {
+		i--
+	}
+	if i > 0 {
+		c := class(line[i-1])
+		for i > 0 && class(line[i-1]) == c {
+			i--
+		}
+	}
+	b.Column = i
+}
+
+func (b *Buffer) wordRight() {
+	line := b.Content[b.Line]
+	if b.Column == len(line) {
+		if b.Line < len(b.Content)-1 {
+			b.Line++
+			b.Column = 0
+		}
+		return
+	}
+	i := b.Column
+	if c := class(line[i]); c != 0 {
+		for i < len(line) && class(line[i]) == c {
+			i++
+		}
+	}
+	for i < len(line) && class(line[i]) == 0 {
+		i++
+	}
+	b.Column = i
+}
+
+// endOf is where text ends when it's put at p
+func endOf(p Pos, text string) Pos {
+	if n := strings.LastIndexByte(text, '\n'); n >= 0 {
+		return Pos{p.Line + strings.Count(text, "\n"), len(text) - n - 1}
+	}
+	return Pos{p.Line, p.Column + len(text)}
+}
+
+// contains is true when p is a place in the text
+func (b *Buffer) contains(p Pos) bool {
+	return p.Line >= 0 && p.Line < len(b.Content) && p.Column >= 0 && p.Column <= len(b.Content[p.Line])
+}
+
+// splice swaps the text between start and end for text
+func (b *Buffer) splice(start, end Pos, text string) {
+	head := b.Content[start.Line][:start.Column]
+	tail := b.Content[end.Line][end.Column:]
+	lines := strings.Split(text, "\n")
+	if start.Line == end.Line && len(lines) == 1 {
+		b.Content[start.Line] = head + text + tail
+		return
+	}
+
+	last := len(lines) - 1
+	lines[0] = head + lines[0]
+	lines[last] += tail
+	content := make([]string, 0, len(b.Content)-(end.Line-start.Line)+last)
+	content = append(content, b.Content[:start.Line]...)
+	content = append(content, lines...)
+	content = append(content, b.Content[end.Line+1:]...)
+	b.Content = content
+}
+
+// replace is splice, recorded in the history. every edit goes through it.
+func (b *Buffer) replace(start, end Pos, text string) Pos {
+	b.history.record(op{at: start, removed: b.Text(start, end), inserted: text})
+	b.splice(start, end, text)
+	return endOf(start, text)
+}
+
+// edit runs f as one undo step. edits made inside another one, like the
+// Insert in Newline, belong to the outer step.
+func (b *Buffer) edit(kind editKind, merge bool, f func()) {
+	if b.editing {
+		f()
+		return
+	}
+	b.Clamp()
+	b.editing = true
+	b.history.begin(kind, merge, b.Cursor())
+	f()
+	b.history.end(b.Cursor())
+	b.editing = false
+}
+
+// delete removes the text between start and end and puts the cursor there
+func (b *Buffer) delete(start, end Pos) {
+	b.replace(start, end, "")
+	b.setCursor(start)
+	b.selecting = false
+	b.hasGoal = false
+}
+
+// DeleteSelection removes the selected text, false when there was none
+func (b *Buffer) DeleteSelection() bool {
+	b.Clamp()
+	start, end, ok := b.Selection()
+	b.selecting = false
+	if !ok {
+		return false
+	}
+	b.edit(stepEdit, false, func() { b.delete(start, end) })
+	return true
+}
+
+// Insert puts text at the cursor, replacing the selection, as one undo
+// step. text can hold several lines.
+func (b *Buffer) Insert(text string) {
+	b.edit(stepEdit, false, func() { b.insert(text) })
+}
+
+func (b *Buffer) insert(text string) {
+	b.DeleteSelection()
+	b.hasGoal = false
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	b.setCursor(b.replace(b.Cursor(), b.Cursor(), text))
+}
+
+// Type is Insert for typed text. a run of typing undoes a word at a time,
+// a space typed after a word starts the next step.
+func (b *Buffer) Type(text string) {
+	b.Clamp()
+	_, _, selected := b.Selection()
+	line := b.Content[b.Line]
+	wordEnds := strings.HasPrefix(text, " ") && b.Column > 0 && line[b.Column-1] != ' '
+	b.edit(typeEdit, !selected && !wordEnds, func() { b.insert(text) })
+}
+
+// Newline splits the line at the cursor, the new line starts with the same
+// indentation
+func (b *Buffer) Newline() {
+	b.edit(stepEdit, false, func() {
+		b.DeleteSelection()
+		line := b.Content[b.Line]
+		indent := line[:min(leadingSpace(line), b.Column)]
+		b.insert("\n" + indent)
+	})
+}
+
+// Backspace deletes the selection or the character before the cursor,
+// joining lines at the start of one. a run of them is one undo step.
+func (b *Buffer) Backspace() {
+	if b.DeleteSelection() {
+		return
+	}
+	b.edit(deleteEdit, true, func() {
+		cursor := b.Cursor()
+		b.Move(Left, false)
+		if b.Cursor() != cursor {
+			b.delete(b.Cursor(), cursor)
+		}
+	})
+}
+
+// Delete deletes the selection or the character after the cursor
+func (b *Buffer) Delete() {
+	if b.DeleteSelection() {
+		return
+	}
+	b.edit(deleteEdit, true, func() {
+		cursor := b.Cursor()
+		b.Move(Right, false)
+		if b.Cursor() != cursor {
+			b.delete(cursor, b.Cursor())
+		}
+	})
+}
+
+// selectedLines is the first and last line a block indent works on. a
+// selection ending at the start of a line leaves that line alone.
+func (b *Buffer) selectedLines() (first, last int, ok bool) {
+	start, end, ok := b.Selection()
+	if !ok || start.Line == end.Line {
+		return b.Line, b.Line, false
+	}
+	if end.Column == 0 {
+		end.Line--
+	}
+	return start.Line, end.Line, true
+}
+
+// shiftColumn moves the cursor or anchor on an indented line along with its
+// text
+func shiftColumn(p *Pos, first, last, by int) {
+	if p.Line < first || p.Line > last || p.Column == 0 && by > 0 {
+		return
+	}
+	p.Column = max(p.Column+by, 0)
+}
+
+// IndentLines is tab: it indents every selected line, or inserts spaces up
+// to the next indent stop without a selection over several lines
+func (b *Buffer) IndentLines() {
+	b.Clamp()
+	first, last, block := b.selectedLines()
+	indent := b.indent()
+	if !block {
+		width := len(indent)
+		b.Insert(strings.Repeat(" ", width-b.Column%width))
+		return
+	}
+	cursor := b.Cursor()
+	b.edit(stepEdit, false, func() {
+		for i := first; i <= last; i++ {
+			if b.Content[i] != "" {
+				b.replace(Pos{i, 0}, Pos{i, 0}, indent)
+			}
+		}
+		shiftColumn(&cursor, first, last, len(indent))
+		shiftColumn(&b.anchor, first, last, len(indent))
+		b.setCursor(cursor)
+	})
+	b.Clamp()
+}
+
+// DedentLines is shift+tab: it takes one indent off the selected lines, or
+// the cursor's line
+func (b *Buffer) DedentLines() {
+	b.Clamp()
+	first, last, _ := b.selectedLines()
+	indent := b.indent()
+	b.edit(stepEdit, false, func() {
+		for i := first; i <= last; i++ {
+			line := b.Content[i]
+			n := 0
+			if strings.HasPrefix(line, "\t") {
+				n = 1
+			} else {
+				for n < len(indent) && n < len(line) && line[n] == ' ' {
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			b.replace(Pos{i, 0}, Pos{i, n}, "")
+			if b.Line == i {
+				b.Column = max(b.Column-n, 0)
+			}
+			if b.selecting && b.anchor.Line == i {
+				b.anchor.Column = max(b.anchor.Column-n, 0)
+			}
+		}
+	})
+	b.hasGoal = false
+}
@@ -0,0 +1,191 @@
+package edit
+
+import (
+	"strings"
+	"testing"
+)
+
+// show draws the buffer with | at the cursor and [ ] around the selection
+func show(b *Buffer) string {
+	start, end, ok := b.Selection()
+	var out []string
+	for i, line := range b.Content {
+		marks := map[int]string{}
+		if ok {
+			if start.Line == i {
+				marks[start.Column] += "["
+			}
+			if end.Line == i {
+				marks[end.Column] += "]"
+			}
+		}
+		if b.Line == i {
+			marks[b.Column] += "|"
+		}
+		var s strings.Builder
+		for c := 0; c <= len(line); c++ {
+			s.WriteString(marks[c])
+			if c < len(line) {
+				s.WriteByte(line[c])
+			}
+		}
+		out = append(out, s.String())
+	}
+	return strings.Join(out, "\n")
+}
+
+func newBuffer(cursor Pos, lines ...string) *Buffer {
+	return &Buffer{Content: lines, Line: cursor.Line, Column: cursor.Column, PageSize: 2}
+}
+
+func TestMove(t *testing.T) {
+	lines := []string{
+		"function _draw()",
+		"    cls()",
+		"",
+		"    dofi.pset(x, y)",
+		"end",
+	}
+	tests := []struct {
+		name     string
+		start    Pos
+		moves    []Motion
+		expected Pos
+	}{
+		{"left wraps", Pos{1, 0}, []Motion{Left}, Pos{0, 16}},
+		{"right wraps", Pos{0, 16}, []Motion{Right}, Pos{1, 0}},
+		{"left at start", Pos{0, 0}, []Motion{Left}, Pos{0, 0}},
+		{"down keeps column", Pos{0, 12}, []Motion{Down, Down, Down}, Pos{3, 12}},
+		{"down clamps to short line", Pos{0, 12}, []Motion{Down}, Pos{1, 9}},
+		{"sticky column", Pos{3, 14}, []Motion{Up, Up, Down}, Pos{2, 0}},
+		{"sticky column back", Pos{3, 14}, []Motion{Up, Up, Down, Down}, Pos{3, 14}},
+		{"up on first line", Pos{0, 5}, []Motion{Up}, Pos{0, 0}},
+		{"down on last line", Pos{4, 1}, []Motion{Down}, Pos{4, 3}},
+		{"home to indent", Pos{1, 7}, []Motion{Home}, Pos{1, 4}},
+		{"home twice", Pos{1, 7}, []Motion{Home, Home}, Pos{1, 0}},
+		{"end", Pos{1, 0}, []Motion{End}, Pos{1, 9}},
+		{"page down", Pos{0, 3}, []Motion{PageDown}, Pos{2, 0}},
+		{"page down keeps column", Pos{0, 3}, []Motion{PageDown, PageDown}, Pos{4, 3}},
+		{"page up", Pos{4, 0}, []Motion{PageUp, PageUp, PageUp}, Pos{0, 0}},
+		{"word right", Pos{3, 4}, []Motion{WordRight}, Pos{3, 8}},
+		{"word right over punctuation", Pos{3, 8}, []Motion{WordRight, WordRight}, Pos{3, 13}},
+		{"word right wraps", Pos{0, 16}, []Motion{WordRight}, Pos{1, 0}},
+		{"word left", Pos{3, 13}, []Motion{WordLeft}, Pos{3, 9}},
+		{"word left over spaces", Pos{1, 4}, []Motion{WordLeft}, Pos{1, 0}},
+		{"word left wraps", Pos{2, 0}, []Motion{WordLeft}, Pos{1, 9}},
+		{"top", Pos{3, 3}, []Motion{Top}, Pos{0, 0}},
+		{"bottom", Pos{1, 3}, []Motion{Bottom}, Pos{4, 3}},
+		{"sideways forgets the column", Pos{3, 14}, []Motion{Up, Up, Left, Down, Down}, Pos{3, 8}},
+	}
+
+	for i, tt := range tests {
+		b := newBuffer(tt.start, lines...)
+		for _, m := range tt.moves {
+			b.Move(m, false)
+		}
+		if got := b.Cursor(); got != tt.expected {
+			t.Fatalf("tests[%d] - %s: cursor wrong. expected=%+v, got=%+v", i, tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		cursor   Pos
+		edit     func(b *Buffer)
+		expected string
+	}{
+		{"type", []string{"ab"}, Pos{0, 1}, func(b *Buffer) { b.Insert("x") }, "ax|b"},
+		{"enter splits", []string{"foo()bar"}, Pos{0, 5}, func(b *Buffer) { b.Newline() }, "foo()\n|bar"},
+		{"enter keeps indent", []string{"    if a then"}, Pos{0, 13}, func(b *Buffer) { b.Newline() }, "    if a then\n    |"},
+		{"enter inside indent", []string{"    x"}, Pos{0, 2}, func(b *Buffer) { b.Newline() }, "  \n  |  x"},
+		{"backspace", []string{"abc"}, Pos{0, 2}, func(b *Buffer) { b.Backspace() }, "a|c"},
+		{"backspace joins", []string{"ab", "cd"}, Pos{1, 0}, func(b *Buffer) { b.Backspace() }, "ab|cd"},
+		{"backspace at start", []string{"ab"}, Pos{0, 0}, func(b *Buffer) { b.Backspace() }, "|ab"},
+		{"delete", []string{"abc"}, Pos{0, 1}, func(b *Buffer) { b.Delete() }, "a|c"},
+		{"delete joins", []string{"ab", "cd"}, Pos{0, 2}, func(b *Buffer) { b.Delete() }, "ab|cd"},
+		{"delete at end", []string{"ab"}, Pos{0, 2}, func(b *Buffer) { b.Delete() }, "ab|"},
+		{"insert lines", []string{"ab"}, Pos{0, 1}, func(b *Buffer) { b.Insert("1\n2\n3") }, "a1\n2\n3|b"},
+		{"tab to indent stop", []string{"ab"}, Pos{0, 1}, func(b *Buffer) { b.IndentLines() }, "a   |b"},
+		{"shift tab", []string{"      x"}, Pos{0, 7}, func(b *Buffer) { b.DedentLines() }, "  x|"},
+		{"shift tab without indent", []string{"x"}, Pos{0, 1}, func(b *Buffer) { b.DedentLines() }, "x|"},
+		{"shift tab on a tab", []string{"\tx"}, Pos{0, 2}, func(b *Buffer) { b.DedentLines() }, "x|"},
+	}
+
+	for i, tt := range tests {
+		b := newBuffer(tt.cursor, tt.lines...)
+		tt.edit(b)
+		if got := show(b); got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong.\nexpected=%q\ngot=     %q", i, tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestSelection(t *testing.T) {
+	lines := []string{"one two", "three", "four"}
+	tests := []struct {
+		name     string
+		cursor   Pos
+		edit     func(b *Buffer)
+		expected string
+	}{
+		{"shift right", Pos{0, 0}, func(b *Buffer) {
+			b.Move(Right, true)
+			b.Move(Right, true)
+		}, "[on]|e two\nthree\nfour"},
+		{"shift down", Pos{0, 4}, func(b *Buffer) { b.Move(Down, true) }, "one [two\nthre]|e\nfour"},
+		{"backwards", Pos{1, 2}, func(b *Buffer) { b.Move(WordLeft, true) }, "one two\n[|th]ree\nfour"},
+		{"left collapses to start", Pos{0, 4}, func(b *Buffer) {
+			b.Move(End, true)
+			b.Move(Left, false)
+		}, "one |two\nthree\nfour"},
+		{"moving drops selection", Pos{0, 4}, func(b *Buffer) {
+			b.Move(End, true)
+			b.Move(Down, false)
+		}, "one two\nthree|\nfour"},
+		{"typing replaces", Pos{0, 4}, func(b *Buffer) {
+			b.Move(Down, true)
+			b.Insert("X")
+		}, "one X|e\nfour"},
+		{"backspace deletes", Pos{1, 0}, func(b *Buffer) {
+			b.Move(Down, true)
+			b.Backspace()
+		}, "one two\n|four"},
+		{"enter replaces", Pos{0, 3}, func(b *Buffer) {
+			b.Move(WordRight, true)
+			b.Newline()
+		}, "one\n|two\nthree\nfour"},
+		{"select all", Pos{1, 1}, func(b *Buffer) { b.SelectAll() }, "[one two\nthree\nfour]|"},
+		{"block indent", Pos{0, 2}, func(b *Buffer) {
+			b.Move(Down, true)
+			b.IndentLines()
+		}, "    on[e two\n    th]|ree\nfour"},
+		{"block indent skips line at column 0", Pos{0, 0}, func(b *Buffer) {
+			b.Move(Down, true)
+			b.Move(Down, true)
+			b.IndentLines()
+		}, "[    one two\n    three\n]|four"},
+		{"block dedent", Pos{0, 0}, func(b *Buffer) {
+			b.Move(Down, true)
+			b.Move(End, true)
+			b.IndentLines()
+			b.DedentLines()
+		}, "[one two\nthree]|\nfour"},
+	}
+
+	for i, tt := range tests {
+		b := newBuffer(tt.cursor, append([]string(nil), lines...)...)
+		tt.edit(b)
+		if got := show(b); got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong.\nexpected=%q\ngot=     %q", i, tt.name, tt.expected, got)
+		}
+	}
+
+	b := newBuffer(Pos{0, 4}, append([]string(nil), lines...)...)
+	b.Move(Bottom, true)
+	if got := b.SelectedText(); got != "two\nthree\nfour" {
+		t.Fatalf("selected text wrong. got=%q", got)
+	}
+}
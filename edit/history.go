@@ -0,0 +1,150 @@
+package edit
+
+// MaxHistory is about how many bytes of edits a buffer keeps for undo and
+// redo, the oldest steps are dropped past it
+const MaxHistory = 256 << 10
+
+// rough size of an op besides its text
+const opSize = 48
+
+// editKind says which steps can grow: typing and deleting keep adding to
+// the step they're in while the cursor hasn't moved away
+type editKind int
+
+const (
+	stepEdit editKind = iota // never grows
+	typeEdit
+	deleteEdit
+)
+
+// op is one change: removed was replaced by inserted at at
+type op struct {
+	at       Pos
+	removed  string
+	inserted string
+}
+
+func (o op) size() int {
+	return opSize + len(o.removed) + len(o.inserted)
+}
+
+// step is what one undo takes back
+type step struct {
+	kind   editKind
+	ops    []op
+	before Pos // cursor before and after the step
+	after  Pos
+	size   int
+}
+
+// History is a buffer's undo and redo stacks. it only holds the changes,
+// not copies of the text.
+type History struct {
+	undo []step
+	redo []step
+	size int  // bytes held by both stacks
+	open bool // the newest step can still grow
+}
+
+// begin starts a step, or keeps adding to the newest one when it's of the
+// same kind and ended where the cursor is now
+func (h *History) begin(kind editKind, merge bool, cursor Pos) {
+	if n := len(h.undo); h.open && merge && kind != stepEdit && n > 0 &&
+		h.undo[n-1].kind == kind && h.undo[n-1].after == cursor {
+		return
+	}
+	h.undo = append(h.undo, step{kind: kind, before: cursor})
+	h.open = false
+}
+
+func (h *History) record(o op) {
+	if len(h.undo) == 0 {
+		return
+	}
+	for _, s := range h.redo {
+		h.size -= s.size
+	}
+	h.redo = nil
+	s := &h.undo[len(h.undo)-1]
+	s.ops = append(s.ops, o)
+	s.size += o.size()
+	h.size += o.size()
+}
+
+func (h *History) end(cursor Pos) {
+	n := len(h.undo)
+	if len(h.undo[n-1].ops) == 0 {
+		// nothing changed, like backspace at the start of the text
+		h.undo = h.undo[:n-1]
+		return
+	}
+	h.undo[n-1].after = cursor
+	h.open = true
+	for h.size > MaxHistory && len(h.undo) > 1 {
+		h.size -= h.undo[0].size
+		h.undo = h.undo[1:]
+	}
+}
+
+// apply runs ops forward, or backward to take them back, false when the
+// text no longer matches them
+func (b *Buffer) apply(ops []op, backward bool) bool {
+	for i := range ops {
+		o := ops[i]
+		if backward {
+			o = ops[len(ops)-1-i]
+			o.removed, o.inserted = o.inserted, o.removed
+		}
+		from, to := o.removed, o.inserted
+		end := endOf(o.at, from)
+		if !b.contains(o.at) || !b.contains(end) || b.Text(o.at, end) != from {
+			return false
+		}
+		b.splice(o.at, end, to)
+	}
+	return true
+}
+
+// Undo takes back the last step, false when there's nothing to undo
+func (b *Buffer) Undo() bool {
+	h := &b.history
+	if len(h.undo) == 0 {
+		return false
+	}
+	s := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	if !b.apply(s.ops, true) {
+		// the text was changed behind the history's back
+		b.history = History{}
+		return false
+	}
+	h.redo = append(h.redo, s)
+	h.open = false
+	b.afterHistory(s.before)
+	return true
+}
+
+// Redo puts back the last undone step
+func (b *Buffer) Redo() bool {
+	h := &b.history
+	if len(h.redo) == 0 {
+		return false
+	}
+	s := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	if !b.apply(s.ops, false) {
+		b.history = History{}
+		return false
+	}
+	h.undo = append(h.undo, s)
+	h.open = false
+	b.afterHistory(s.after)
+	return true
+}
+
+func (b *Buffer) afterHistory(cursor Pos) {
+	b.setCursor(cursor)
+	b.selecting = false
+	b.hasGoal = false
+	b.Clamp()
+}
@@ -0,0 +1,127 @@
+package edit
+
+import (
+	"strings"
+	"testing"
+)
+
+func typeText(b *Buffer, text string) {
+	for _, r := range text {
+		b.Type(string(r))
+	}
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		edit  func(b *Buffer)
+		// what each undo leaves, last to first
+		undone []string
+	}{
+		{"typing undoes a word at a time", []string{""}, func(b *Buffer) { typeText(b, "local x = 1") },
+			[]string{"local x =|", "local x|", "local|", "|"}},
+		{"paste is one step", []string{"ab"}, func(b *Buffer) {
+			b.Move(Right, false)
+			b.Insert("1\n2\n3")
+		}, []string{"a|b"}},
+		{"moving starts a new step", []string{""}, func(b *Buffer) {
+			typeText(b, "ab")
+			b.Move(Left, false)
+			typeText(b, "c")
+		}, []string{"a|b", "|"}},
+		{"backspaces group", []string{"abc", "de"}, func(b *Buffer) {
+			b.Move(Bottom, false)
+			for range 4 {
+				b.Backspace()
+			}
+		}, []string{"abc\nde|"}},
+		{"typing after deleting", []string{"ab"}, func(b *Buffer) {
+			b.Move(End, false)
+			b.Backspace()
+			typeText(b, "x")
+		}, []string{"a|", "ab|"}},
+		{"enter keeps its indent", []string{"  if a then"}, func(b *Buffer) {
+			b.Move(End, false)
+			b.Newline()
+			typeText(b, "x")
+		}, []string{"  if a then\n  |", "  if a then|"}},
+		{"block indent", []string{"a", "b"}, func(b *Buffer) {
+			b.SelectAll()
+			b.IndentLines()
+			b.DedentLines()
+		}, []string{"    a\n    b|", "a\nb|"}},
+		{"typing over a selection", []string{"one two"}, func(b *Buffer) {
+			b.Move(WordRight, true)
+			typeText(b, "xy")
+		}, []string{"one |two"}},
+	}
+
+	for i, tt := range tests {
+		b := newBuffer(Pos{}, tt.lines...)
+		tt.edit(b)
+		done := strings.Join(b.Content, "\n")
+		for j, expected := range tt.undone {
+			if !b.Undo() {
+				t.Fatalf("tests[%d] - %s: undo %d did nothing", i, tt.name, j)
+			}
+			if got := show(b); got != expected {
+				t.Fatalf("tests[%d] - %s: undo %d wrong.\nexpected=%q\ngot=     %q", i, tt.name, j, expected, got)
+			}
+		}
+		if b.Undo() {
+			t.Fatalf("tests[%d] - %s: more to undo than expected, got %q", i, tt.name, show(b))
+		}
+		for b.Redo() {
+		}
+		if got := strings.Join(b.Content, "\n"); got != done {
+			t.Fatalf("tests[%d] - %s: redo wrong.\nexpected=%q\ngot=     %q", i, tt.name, done, got)
+		}
+	}
+}
+
+func TestRedoDroppedByEdit(t *testing.T) {
+	b := newBuffer(Pos{}, "")
+	typeText(b, "ab")
+	b.Undo()
+	typeText(b, "c")
+	if b.Redo() {
+		t.Fatalf("redo after an edit should do nothing, got %q", show(b))
+	}
+	if got := show(b); got != "c|" {
+		t.Fatalf("text wrong. expected=%q, got=%q", "c|", got)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	b := newBuffer(Pos{}, "")
+	line := strings.Repeat("x", 1000)
+	for range 1000 {
+		b.Insert(line)
+	}
+	if b.history.size > MaxHistory {
+		t.Fatalf("history too big. limit=%d, got=%d", MaxHistory, b.history.size)
+	}
+	undone := 0
+	for b.Undo() {
+		undone++
+	}
+	if undone == 0 || undone >= 1000 {
+		t.Fatalf("undo steps wrong. got=%d", undone)
+	}
+	if len(b.Content[0]) != (1000-undone)*len(line) {
+		t.Fatalf("text left wrong. got %d bytes after %d undos", len(b.Content[0]), undone)
+	}
+}
+
+func TestUndoAfterOutsideChange(t *testing.T) {
+	b := newBuffer(Pos{}, "")
+	typeText(b, "abc")
+	b.Content = []string{"other"}
+	if b.Undo() {
+		t.Fatalf("undo should refuse text it doesn't know, got %q", show(b))
+	}
+	if b.Content[0] != "other" {
+		t.Fatalf("text changed. got=%q", b.Content[0])
+	}
+}
@@ -0,0 +1,218 @@
+package fixed
+
+import "math"
+
+// 16.16 fixed point numbers, like pico-8 has them: 16 bits of signed integer
+// and 16 bits of fraction in an int32. arithmetic wraps around instead of
+// overflowing, so the same inputs always give the same bits.
+
+type Fix int32
+
+const (
+	One  Fix = 1 << 16
+	Half Fix = One / 2
+	Min  Fix = math.MinInt32
+	Max  Fix = math.MaxInt32
+)
+
+// FromFloat rounds f down to the nearest 1/65536 and wraps it into range
+func FromFloat(f float64) Fix {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	v := math.Floor(f * float64(One))
+	// keep the low 32 bits, like an integer overflow would
+	v = math.Mod(v, 1<<32)
+	if v < 0 {
+		v += 1 << 32
+	}
+	return Fix(int32(uint32(v)))
+}
+
+func FromInt(i int) Fix {
+	return Fix(int32(i << 16))
+}
+
+// Float is exact, every fixed point value fits in a float64
+func (f Fix) Float() float64 {
+	return float64(f) / float64(One)
+}
+
+func (f Fix) Mul(g Fix) Fix {
+	return Fix(int32(int64(f) * int64(g) >> 16))
+}
+
+// Div divides like pico-8, x/0 is the largest value with the sign of x
+func (f Fix) Div(g Fix) Fix {
+	if g == 0 {
+		if f < 0 {
+			return Min
+		}
+		return Max
+	}
+	return Fix(int32(int64(f) << 16 / int64(g)))
+}
+
+func Flr(f Fix) Fix {
+	return f &^ (One - 1)
+}
+
+func Ceil(f Fix) Fix {
+	return Flr(f + One - 1)
+}
+
+// Abs wraps too, the most negative value stays negative
+func Abs(f Fix) Fix {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+// Sgn is 1 for zero, like pico-8
+func Sgn(f Fix) Fix {
+	if f < 0 {
+		return -One
+	}
+	return One
+}
+
+// Sqrt is rounded down, negative numbers give 0
+func Sqrt(f Fix) Fix {
+	if f <= 0 {
+		return 0
+	}
+	// sqrt(f/2^16) * 2^16 = sqrt(f * 2^16)
+	n := uint64(f) << 16
+	r := uint64(math.Sqrt(float64(n)))
+	// the float guess can be one off either way
+	for r*r > n {
+		r--
+	}
+	for (r+1)*(r+1) <= n {
+		r++
+	}
+	return Fix(r)
+}
+
+// quarter of a sine wave, one entry per 1/65536 of a turn
+const quarter = 1 << 14
+
+var sine [quarter + 1]Fix
+
+func init() {
+	for i := range sine {
+		sine[i] = Fix(math.Round(math.Sin(float64(i)/(4*quarter)*2*math.Pi) * float64(One)))
+	}
+}
+
+// turnSin is sin(2*pi*t) from the table, by symmetry
+func turnSin(t Fix) Fix {
+	i := int(uint32(t) & 0xffff) // fraction of a turn
+	switch {
+	case i <= quarter:
+		return sine[i]
+	case i <= 2*quarter:
+		return sine[2*quarter-i]
+	case i <= 3*quarter:
+		return -sine[i-2*quarter]
+	default:
+		return -sine[4*quarter-i]
+	}
+}
+
+// Sin takes turns instead of radians and is upside down, like pico-8, so
+// sin(0.25) is -1 to match a y axis that points down
+func Sin(t Fix) Fix {
+	return -turnSin(t)
+}
+
+// Cos takes turns, cos(0.5) is -1
+func Cos(t Fix) Fix {
+	return turnSin(t + One/4)
+}
+
+// Atan2 is the angle of dx, dy in turns from 0 to 1, with y pointing down.
+// atan2(0, 0) is 0.25 like pico-8.
+func Atan2(dx, dy Fix) Fix {
+	if dx == 0 && dy == 0 {
+		return One / 4
+	}
+	a := math.Atan2(-dy.Float(), dx.Float()) / (2 * math.Pi)
+	if a < 0 {
+		a++
+	}
+	return FromFloat(a+0.5/float64(One)) & (One - 1)
+}
+
+func Band(a, b Fix) Fix { return a & b }
+func Bor(a, b Fix) Fix  { return a | b }
+func Bxor(a, b Fix) Fix { return a ^ b }
+
+// Shl shifts the bits left by n, a negative n shifts right
+func Shl(f Fix, n int) Fix {
+	switch {
+	case n < 0:
+		return Shr(f, -n)
+	case n >= 32:
+		return 0
+	}
+	return f << n
+}
+
+// Shr is an arithmetic shift, the sign is kept. a negative n shifts left.
+func Shr(f Fix, n int) Fix {
+	switch {
+	case n < 0:
+		return Shl(f, -n)
+	case n >= 32:
+		return f >> 31
+	}
+	return f >> n
+}
+
+// Rand is a small xorshift generator, the same seed always gives the same
+// numbers on every platform
+type Rand struct {
+	state uint32
+}
+
+func NewRand(seed Fix) *Rand {
+	r := &Rand{}
+	r.Seed(seed)
+	return r
+}
+
+func (r *Rand) Seed(seed Fix) {
+	r.state = uint32(seed)*0x9e3779b9 ^ 0x6d2b79f5
+	if r.state == 0 {
+		r.state = 1
+	}
+	// the first few numbers after a small seed look alike, skip them
+	for i := 0; i < 4; i++ {
+		r.Next()
+	}
+}
+
+func (r *Rand) Next() uint32 {
+	x := r.state
+	x ^= x << 13
+	x ^= x >> 17
+	x ^= x << 5
+	r.state = x
+	return x
+}
+
+// Rnd is a random number from 0 up to but not including limit, 0 when
+// limit isn't positive
+func (r *Rand) Rnd(limit Fix) Fix {
+	if limit <= 0 {
+		return 0
+	}
+	return Fix(uint64(r.Next()) % uint64(limit))
+}
+
+// Float is a random float from 0 up to but not including 1
+func (r *Rand) Float() float64 {
+	return float64(r.Next()) / (1 << 32)
+}
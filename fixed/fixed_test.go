@@ -0,0 +1,161 @@
+package fixed
+
+import "testing"
+
+// f is a float known to be exact in 16.16
+func f(v float64) Fix {
+	return FromFloat(v)
+}
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		input    float64
+		expected Fix
+	}{
+		{1, One},
+		{-1, -One},
+		{0.5, Half},
+		{1.0 / 65536, 1},
+		{-1.0 / 65536, -1},
+		{1.0 / 131072, 0},   // rounds down
+		{-1.0 / 131072, -1}, // down, not towards zero
+		{32767.99998474121, Max},
+		{-32768, Min},
+		{32768, Min}, // wraps around
+		{65536 + 2, 2 * One},
+	}
+
+	for i, tt := range tests {
+		if got := FromFloat(tt.input); got != tt.expected {
+			t.Fatalf("tests[%d] - FromFloat(%v) wrong. expected=0x%08x, got=0x%08x", i, tt.input, uint32(tt.expected), uint32(got))
+		}
+	}
+	if got := FromInt(40000); got != FromFloat(40000-65536) {
+		t.Fatalf("FromInt doesn't wrap. got=%v", got.Float())
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      Fix
+		expected Fix
+	}{
+		{"mul", f(1.5).Mul(f(-2)), f(-3)},
+		{"mul fraction", f(0.5).Mul(f(0.5)), f(0.25)},
+		{"mul wraps", f(256).Mul(f(256)), 0},
+		{"div", f(3).Div(f(2)), f(1.5)},
+		{"div by zero", f(3).Div(0), Max},
+		{"negative div by zero", f(-3).Div(0), Min},
+		{"flr", Flr(f(1.75)), f(1)},
+		{"flr negative", Flr(f(-1.25)), f(-2)},
+		{"ceil", Ceil(f(1.25)), f(2)},
+		{"ceil negative", Ceil(f(-1.75)), f(-1)},
+		{"ceil whole", Ceil(f(3)), f(3)},
+		{"abs", Abs(f(-2.5)), f(2.5)},
+		{"abs min wraps", Abs(Min), Min},
+		{"sgn zero", Sgn(0), One},
+		{"sgn negative", Sgn(f(-0.1)), -One},
+		{"sqrt", Sqrt(f(16)), f(4)},
+		{"sqrt fraction", Sqrt(f(0.25)), f(0.5)},
+		{"sqrt 2", Sqrt(f(2)), 92681}, // 1.41420746, rounded down
+		{"sqrt max", Sqrt(Max), 11863283},
+		{"sqrt negative", Sqrt(f(-4)), 0},
+	}
+
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong. expected=%v, got=%v", i, tt.name, tt.expected.Float(), tt.got.Float())
+		}
+	}
+}
+
+func TestTrig(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      Fix
+		expected Fix
+	}{
+		{"sin 0", Sin(0), 0},
+		{"sin quarter", Sin(f(0.25)), -One},
+		{"sin half", Sin(f(0.5)), 0},
+		{"sin three quarters", Sin(f(0.75)), One},
+		{"sin whole turns", Sin(f(3.25)), -One},
+		{"sin negative", Sin(f(-0.25)), One},
+		{"sin eighth", Sin(f(0.125)), -46341},
+		{"cos 0", Cos(0), One},
+		{"cos half", Cos(f(0.5)), -One},
+		{"cos quarter", Cos(f(0.25)), 0},
+		{"atan2 right", Atan2(One, 0), 0},
+		{"atan2 up", Atan2(0, -One), f(0.25)},
+		{"atan2 left", Atan2(-One, 0), f(0.5)},
+		{"atan2 down", Atan2(0, One), f(0.75)},
+		{"atan2 diagonal", Atan2(One, -One), f(0.125)},
+		{"atan2 zero", Atan2(0, 0), f(0.25)},
+		{"atan2 just below right", Atan2(One, 1), 0}, // rounds to 1, which wraps
+	}
+
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong. expected=%v, got=%v", i, tt.name, tt.expected.Float(), tt.got.Float())
+		}
+	}
+}
+
+func TestBitwise(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      Fix
+		expected Fix
+	}{
+		{"band", Band(f(3.5), f(6.25)), f(2)},
+		{"bor", Bor(f(1), f(0.5)), f(1.5)},
+		{"bxor", Bxor(f(3), f(1)), f(2)},
+		{"shl", Shl(f(1), 4), f(16)},
+		{"shl fraction", Shl(f(0.5), 1), f(1)},
+		{"shl wraps", Shl(f(0x4000), 2), 0},
+		{"shl 32", Shl(f(1), 32), 0},
+		{"shl negative", Shl(f(4), -1), f(2)},
+		{"shr", Shr(f(16), 4), f(1)},
+		{"shr fraction", Shr(f(1), 1), f(0.5)},
+		{"shr keeps sign", Shr(f(-2), 1), f(-1)},
+		{"shr 32", Shr(f(-2), 40), -1},
+		{"shr 32 positive", Shr(f(2), 32), 0},
+	}
+
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong. expected=%v, got=%v", i, tt.name, tt.expected.Float(), tt.got.Float())
+		}
+	}
+}
+
+func TestRand(t *testing.T) {
+	a := NewRand(f(42))
+	b := NewRand(f(42))
+	c := NewRand(f(43))
+	same := true
+	for i := 0; i < 100; i++ {
+		x, y, z := a.Rnd(f(10)), b.Rnd(f(10)), c.Rnd(f(10))
+		if x != y {
+			t.Fatalf("same seed gave different numbers at %d. got=%v, %v", i, x.Float(), y.Float())
+		}
+		if x < 0 || x >= f(10) {
+			t.Fatalf("rnd out of range. got=%v", x.Float())
+		}
+		same = same && x == z
+	}
+	if same {
+		t.Fatalf("different seeds gave the same numbers")
+	}
+
+	a.Seed(f(7))
+	first := a.Next()
+	a.Seed(f(7))
+	if a.Next() != first {
+		t.Fatalf("reseeding doesn't restart the sequence")
+	}
+	if got := a.Rnd(0); got != 0 {
+		t.Fatalf("rnd(0) wrong. expected=0, got=%v", got.Float())
+	}
+}
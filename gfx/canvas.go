@@ -0,0 +1,45 @@
+package gfx
+
+// Canvas draws into a framebuffer through a draw state
+type Canvas struct {
+	FB    *Framebuffer
+	State *DrawState
+}
+
+// Pset plots a single pixel, every other primitive ends up here. x and y are
+// in camera space, the fill pattern is anchored to the screen.
+func (c Canvas) Pset(x, y int, col uint8) {
+	x -= c.State.CameraX
+	y -= c.State.CameraY
+	if c.State.FillPattern != 0 && c.State.FillPattern>>(15-((y&3)*4+(x&3)))&1 == 1 {
+		if c.State.FillTransparent {
+			return
+		}
+		col >>= 4
+	}
+	c.plot(x, y, c.State.DrawPal[col&(PaletteSize-1)])
+}
+
+// plot writes a final color at screen coordinates, honoring the clip rect
+func (c Canvas) plot(x, y int, col uint8) {
+	if x < c.State.ClipX0 || x >= c.State.ClipX1 || y < c.State.ClipY0 || y >= c.State.ClipY1 {
+		return
+	}
+	c.FB.Set(x, y, col)
+}
+
+// Bounds is the drawable area in camera space, all inclusive
+func (c Canvas) Bounds() (x0, y0, x1, y1 int) {
+	x0 = max(c.State.ClipX0, 0) + c.State.CameraX
+	y0 = max(c.State.ClipY0, 0) + c.State.CameraY
+	x1 = min(c.State.ClipX1, c.FB.Width) - 1 + c.State.CameraX
+	y1 = min(c.State.ClipY1, c.FB.Height) - 1 + c.State.CameraY
+	return
+}
+
+// Cls clears the whole screen and drops the camera and clip rectangle
+func (c Canvas) Cls(col uint8) {
+	c.FB.Clear(col)
+	c.State.Camera(0, 0)
+	c.State.ResetClip()
+}
@@ -0,0 +1,94 @@
+package gfx
+
+import "testing"
+
+func TestCamera(t *testing.T) {
+	c := newTestCanvas(6, 4)
+	c.State.Camera(2, -1)
+	c.Pset(2, -1, 7)
+	c.RectFill(3, 0, 10, 0, 8)
+	c.Line(-5, 2, 2, 2, 9)
+
+	expected := picture(
+		"7.....",
+		".88888",
+		"......",
+		"9.....",
+	)
+	if got := render(c.FB); got != expected {
+		t.Errorf("camera wrong.\nexpected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestClip(t *testing.T) {
+	c := newTestCanvas(6, 4)
+	c.State.Clip(1, 1, 3, 2)
+	c.RectFill(0, 0, 5, 3, 7)
+	c.Circ(0, 0, 1, 8)
+
+	expected := picture(
+		"......",
+		".777..",
+		".777..",
+		"......",
+	)
+	if got := render(c.FB); got != expected {
+		t.Errorf("clip wrong.\nexpected=\n%s\ngot=\n%s", expected, got)
+	}
+
+	// the clip rect lives in screen space, the camera doesn't move it
+	c.FB.Clear(0)
+	c.State.Camera(-1, 0)
+	c.Rect(0, 0, 2, 3, 9)
+	expected = picture(
+		"......",
+		".9.9..",
+		".9.9..",
+		"......",
+	)
+	if got := render(c.FB); got != expected {
+		t.Errorf("clip with camera wrong.\nexpected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestClipIsClamped(t *testing.T) {
+	state := NewDrawState()
+	tests := []struct {
+		x, y, w, h     int
+		x0, y0, x1, y1 int
+	}{
+		{-10, -10, 20, 20, 0, 0, 10, 10},
+		{100, 120, 100, 100, 100, 120, 128, 128},
+		{10, 10, -5, 4, 10, 10, 10, 14},
+		{200, 200, 5, 5, 128, 128, 128, 128},
+	}
+
+	for i, tt := range tests {
+		state.Clip(tt.x, tt.y, tt.w, tt.h)
+		if state.ClipX0 != tt.x0 || state.ClipY0 != tt.y0 || state.ClipX1 != tt.x1 || state.ClipY1 != tt.y1 {
+			t.Errorf("tests[%d] - clip wrong. expected=(%d,%d,%d,%d), got=(%d,%d,%d,%d)", i,
+				tt.x0, tt.y0, tt.x1, tt.y1, state.ClipX0, state.ClipY0, state.ClipX1, state.ClipY1)
+		}
+	}
+}
+
+func TestClsResetsCameraAndClip(t *testing.T) {
+	c := newTestCanvas(4, 4)
+	c.State.Camera(5, 5)
+	c.State.Clip(1, 1, 1, 1)
+	c.State.Pal(1, 2, PalDraw)
+	c.Cls(3)
+
+	if c.State.CameraX != 0 || c.State.CameraY != 0 {
+		t.Errorf("camera not reset. got=(%d,%d)", c.State.CameraX, c.State.CameraY)
+	}
+	if c.State.ClipX0 != 0 || c.State.ClipX1 != ScreenWidth {
+		t.Errorf("clip not reset. got=(%d,%d)", c.State.ClipX0, c.State.ClipX1)
+	}
+	if c.State.DrawPal[1] != 2 {
+		t.Errorf("cls should keep the palette")
+	}
+	if c.Pget(3, 3) != 3 {
+		t.Errorf("cls color wrong. got=%d", c.Pget(3, 3))
+	}
+}
@@ -0,0 +1,56 @@
+package gfx
+
+// Framebuffer stores one palette index per pixel, row by row
+type Framebuffer struct {
+	Width  int
+	Height int
+	Pix    []uint8
+}
+
+func NewFramebuffer(width, height int) *Framebuffer {
+	return &Framebuffer{
+		Width:  width,
+		Height: height,
+		Pix:    make([]uint8, width*height),
+	}
+}
+
+func (f *Framebuffer) InBounds(x, y int) bool {
+	return x >= 0 && x < f.Width && y >= 0 && y < f.Height
+}
+
+// Get returns 0 outside of the buffer. Pix can be memory poke wrote any
+// byte into, only the low 4 bits are a color.
+func (f *Framebuffer) Get(x, y int) uint8 {
+	if !f.InBounds(x, y) {
+		return 0
+	}
+	return f.Pix[y*f.Width+x] & (PaletteSize - 1)
+}
+
+// Set silently ignores pixels outside of the buffer
+func (f *Framebuffer) Set(x, y int, c uint8) {
+	if !f.InBounds(x, y) {
+		return
+	}
+	f.Pix[y*f.Width+x] = c & (PaletteSize - 1)
+}
+
+func (f *Framebuffer) Clear(c uint8) {
+	c &= PaletteSize - 1
+	for i := range f.Pix {
+		f.Pix[i] = c
+	}
+}
+
+// RGBA converts the indices to colors through the display palette. dst must
+// hold 4 bytes per pixel.
+func (f *Framebuffer) RGBA(dst []byte, pal *Palette, display *[PaletteSize]uint8) {
+	for i, c := range f.Pix {
+		rgba := pal[display[c&(PaletteSize-1)]&(PaletteSize-1)]
+		dst[i*4] = rgba.R
+		dst[i*4+1] = rgba.G
+		dst[i*4+2] = rgba.B
+		dst[i*4+3] = rgba.A
+	}
+}
@@ -0,0 +1,103 @@
+package gfx
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// compares the framebuffer, as shown with the default palette, against
+// testdata/<name>.golden.png
+func checkGolden(t *testing.T, name string, c Canvas) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, c.FB.Width, c.FB.Height))
+	c.FB.RGBA(img.Pix, &DefaultPalette, &c.State.DisplayPal)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encoding %s: %v", name, err)
+	}
+
+	path := filepath.Join("testdata", name+".golden.png")
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("reading golden image (run with -update to create it): %v", err)
+	}
+	defer f.Close()
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+
+	if golden.Bounds() != img.Bounds() {
+		t.Fatalf("%s size wrong. expected=%v, got=%v", name, golden.Bounds(), img.Bounds())
+	}
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			r0, g0, b0, _ := golden.At(x, y).RGBA()
+			r1, g1, b1, _ := img.At(x, y).RGBA()
+			if r0 != r1 || g0 != g1 || b0 != b1 {
+				t.Fatalf("%s differs from golden image at (%d, %d)", name, x, y)
+			}
+		}
+	}
+}
+
+func TestSpritesGolden(t *testing.T) {
+	sheet := NewSpriteSheet()
+	// sprite 1 is a little face, sprite 2 a checker
+	face := []string{
+		"..aaaa..",
+		".aaaaaa.",
+		"aa0aa0aa",
+		"aaaaaaaa",
+		"a8aaaa8a",
+		"aa8888aa",
+		".aaaaaa.",
+		"..aaaa..",
+	}
+	for y, row := range face {
+		for x, ch := range row {
+			if ch != '.' {
+				v := uint8(ch - '0')
+				if ch >= 'a' {
+					v = uint8(ch-'a') + 10
+				}
+				sheet.Pixels.Set(8+x, y, v)
+			}
+		}
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			sheet.Pixels.Set(16+x, y, uint8(1+(x+y)%2*11))
+		}
+	}
+
+	c := newTestCanvas(48, 32)
+	c.Cls(1)
+	c.Spr(sheet, 1, 0, 0, 1, 1, false, false)
+	c.Spr(sheet, 1, 10, 0, 1, 1, true, true)
+	c.Spr(sheet, 1, 20, 0, 2, 1, false, false)
+	c.Sspr(sheet, 8, 0, 8, 8, 0, 10, 16, 16, false, false)
+	c.Sspr(sheet, 8, 0, 8, 8, 40, 10, -8, 22, false, false)
+	c.State.Pal(10, 14, PalDraw)
+	c.Spr(sheet, 1, 18, 12, 1, 1, false, false)
+	c.State.ResetPal()
+	c.State.Clip(30, 14, 6, 6)
+	c.Spr(sheet, 2, 28, 12, 1, 1, false, false)
+
+	checkGolden(t, "sprites", c)
+}
@@ -0,0 +1,45 @@
+package gfx
+
+import "image/color"
+
+const PaletteSize = 16
+
+type Palette [PaletteSize]color.RGBA
+
+// the default 16 colors, index 0 is black and doubles as the clear color
+var DefaultPalette = Palette{
+	{0, 0, 0, 255},       // 0 black
+	{29, 43, 83, 255},    // 1 dark blue
+	{126, 37, 83, 255},   // 2 dark purple
+	{0, 135, 81, 255},    // 3 dark green
+	{171, 82, 54, 255},   // 4 brown
+	{95, 87, 79, 255},    // 5 dark grey
+	{194, 195, 199, 255}, // 6 light grey
+	{255, 241, 232, 255}, // 7 white
+	{255, 0, 77, 255},    // 8 red
+	{255, 163, 0, 255},   // 9 orange
+	{255, 236, 39, 255},  // 10 yellow
+	{0, 228, 54, 255},    // 11 green
+	{41, 173, 255, 255},  // 12 blue
+	{131, 118, 156, 255}, // 13 lavender
+	{255, 119, 168, 255}, // 14 pink
+	{255, 204, 170, 255}, // 15 peach
+}
+
+// Nearest returns the palette index closest to c, used by the old
+// dofi.pset(x, y, r, g, b) form
+func (p *Palette) Nearest(c color.RGBA) uint8 {
+	best := uint8(0)
+	bestDist := -1
+	for i, pc := range p {
+		dr := int(c.R) - int(pc.R)
+		dg := int(c.G) - int(pc.G)
+		db := int(c.B) - int(pc.B)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best = uint8(i)
+			bestDist = dist
+		}
+	}
+	return best
+}
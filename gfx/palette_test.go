@@ -0,0 +1,92 @@
+package gfx
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestNearest(t *testing.T) {
+	tests := []struct {
+		input    color.RGBA
+		expected uint8
+	}{
+		{color.RGBA{0, 0, 0, 255}, 0},
+		{color.RGBA{255, 255, 255, 255}, 7},
+		{color.RGBA{250, 5, 70, 255}, 8},
+		{color.RGBA{40, 170, 250, 255}, 12},
+		{color.RGBA{255, 204, 170, 255}, 15},
+	}
+
+	for i, tt := range tests {
+		got := DefaultPalette.Nearest(tt.input)
+		if got != tt.expected {
+			t.Fatalf("tests[%d] - nearest wrong. expected=%d, got=%d", i, tt.expected, got)
+		}
+	}
+}
+
+func TestDrawPalette(t *testing.T) {
+	fb := NewFramebuffer(4, 4)
+	state := NewDrawState()
+	c := Canvas{FB: fb, State: &state}
+
+	state.Pal(8, 12, PalDraw)
+	c.Pset(1, 1, 8)
+	c.Pset(2, 1, 9)
+	if got := fb.Get(1, 1); got != 12 {
+		t.Errorf("remapped pixel wrong. expected=12, got=%d", got)
+	}
+	if got := fb.Get(2, 1); got != 9 {
+		t.Errorf("untouched pixel wrong. expected=9, got=%d", got)
+	}
+
+	state.ResetPal()
+	c.Pset(1, 1, 8)
+	if got := fb.Get(1, 1); got != 8 {
+		t.Errorf("pixel after reset wrong. expected=8, got=%d", got)
+	}
+
+	// out of bounds is ignored
+	c.Pset(-1, 0, 7)
+	c.Pset(4, 4, 7)
+}
+
+func TestDisplayPalette(t *testing.T) {
+	fb := NewFramebuffer(2, 1)
+	state := NewDrawState()
+	fb.Set(0, 0, 1)
+	fb.Set(1, 0, 2)
+	state.Pal(1, 8, PalDisplay)
+
+	pixels := make([]byte, 2*4)
+	fb.RGBA(pixels, &DefaultPalette, &state.DisplayPal)
+
+	red := DefaultPalette[8]
+	if pixels[0] != red.R || pixels[1] != red.G || pixels[2] != red.B || pixels[3] != red.A {
+		t.Errorf("display remapped pixel wrong. got=%v", pixels[0:4])
+	}
+	purple := DefaultPalette[2]
+	if pixels[4] != purple.R || pixels[5] != purple.G || pixels[6] != purple.B {
+		t.Errorf("plain pixel wrong. got=%v", pixels[4:8])
+	}
+	// the framebuffer itself keeps the original index
+	if fb.Get(0, 0) != 1 {
+		t.Errorf("display palette changed the framebuffer")
+	}
+}
+
+func TestPalt(t *testing.T) {
+	state := NewDrawState()
+	if !state.Transparent[0] || state.Transparent[1] {
+		t.Fatalf("default transparency wrong. got=%v", state.Transparent)
+	}
+	state.Palt(0, false)
+	state.Palt(14, true)
+	if state.Transparent[0] || !state.Transparent[14] {
+		t.Fatalf("palt did not apply. got=%v", state.Transparent)
+	}
+	state.ResetPalt()
+	if !state.Transparent[0] || state.Transparent[14] {
+		t.Fatalf("palt reset wrong. got=%v", state.Transparent)
+	}
+}
@@ -0,0 +1,343 @@
+package gfx
+
+import "math"
+
+// colors passed to the primitives can carry a second color in the high
+// nibble, used for the set bits of the fill pattern
+
+// exactReach is how big circles and ovals get walked pixel by pixel, bigger
+// ones are drawn a row at a time. the walks would loop over millions of
+// offscreen pixels where the lua time limit can't stop them.
+const exactReach = 1 << 12
+
+func (c Canvas) Pget(x, y int) uint8 {
+	return c.FB.Get(x, y)
+}
+
+// horizontal span, clamped so huge shapes don't loop over offscreen pixels
+func (c Canvas) hline(x0, x1, y int, col uint8) {
+	bx0, by0, bx1, by1 := c.Bounds()
+	if y < by0 || y > by1 {
+		return
+	}
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	x0 = max(x0, bx0)
+	x1 = min(x1, bx1)
+	for x := x0; x <= x1; x++ {
+		c.Pset(x, y, col)
+	}
+}
+
+func (c Canvas) vline(x, y0, y1 int, col uint8) {
+	bx0, by0, bx1, by1 := c.Bounds()
+	if x < bx0 || x > bx1 {
+		return
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	y0 = max(y0, by0)
+	y1 = min(y1, by1)
+	for y := y0; y <= y1; y++ {
+		c.Pset(x, y, col)
+	}
+}
+
+func (c Canvas) Line(x0, y0, x1, y1 int, col uint8) {
+	// both ends off the same edge, nothing to draw
+	bx0, by0, bx1, by1 := c.Bounds()
+	if (x0 < bx0 && x1 < bx0) || (y0 < by0 && y1 < by0) ||
+		(x0 > bx1 && x1 > bx1) || (y0 > by1 && y1 > by1) {
+		return
+	}
+
+	if abs(x1-x0) >= abs(y1-y0) {
+		lineSteps(x0, y0, x1, y1, bx0, bx1, func(x, y int) { c.Pset(x, y, col) })
+	} else {
+		lineSteps(y0, x0, y1, x1, by0, by1, func(y, x int) { c.Pset(x, y, col) })
+	}
+}
+
+// lineSteps walks a line along its longer axis m, clipped to lo..hi on it
+// before stepping so huge lines cost no more than the screen. at each step
+// the other axis n is rounded half away from the start, the same pixels a
+// bresenham walk of the whole line picks.
+func lineSteps(m0, n0, m1, n1, lo, hi int, plot func(m, n int)) {
+	dm, dn := abs(m1-m0), n1-n0
+	dir := 1
+	if m1 < m0 {
+		dir = -1
+	}
+	for m := max(min(m0, m1), lo); m <= min(max(m0, m1), hi); m++ {
+		n := n0
+		if dm != 0 {
+			k := (m - m0) * dir // steps from the start
+			off := (2*abs(k*dn) + dm) / (2 * dm)
+			if k*dn < 0 {
+				off = -off
+			}
+			n += off
+		}
+		plot(m, n)
+	}
+}
+
+// overlaps reports whether a box, all inclusive, touches the bounds
+func (c Canvas) overlaps(x0, y0, x1, y1 int) bool {
+	bx0, by0, bx1, by1 := c.Bounds()
+	return x1 >= bx0 && x0 <= bx1 && y1 >= by0 && y0 <= by1
+}
+
+func (c Canvas) Rect(x0, y0, x1, y1 int, col uint8) {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	c.hline(x0, x1, y0, col)
+	if y1 != y0 {
+		c.hline(x0, x1, y1, col)
+	}
+	if y1-y0 > 1 {
+		c.vline(x0, y0+1, y1-1, col)
+		if x1 != x0 {
+			c.vline(x1, y0+1, y1-1, col)
+		}
+	}
+}
+
+func (c Canvas) RectFill(x0, y0, x1, y1 int, col uint8) {
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	_, by0, _, by1 := c.Bounds()
+	y0 = max(y0, by0)
+	y1 = min(y1, by1)
+	for y := y0; y <= y1; y++ {
+		c.hline(x0, x1, y, col)
+	}
+}
+
+// midpoint circle
+func (c Canvas) Circ(cx, cy, r int, col uint8) {
+	if r < 0 || !c.overlaps(cx-r, cy-r, cx+r, cy+r) {
+		return
+	}
+	if r > exactReach {
+		c.ellipseRows(cx-r, cy-r, cx+r, cy+r, col, false)
+		return
+	}
+	x, y := 0, r
+	f := 1 - r
+	for x <= y {
+		c.Pset(cx+x, cy+y, col)
+		c.Pset(cx-x, cy+y, col)
+		c.Pset(cx+x, cy-y, col)
+		c.Pset(cx-x, cy-y, col)
+		c.Pset(cx+y, cy+x, col)
+		c.Pset(cx-y, cy+x, col)
+		c.Pset(cx+y, cy-x, col)
+		c.Pset(cx-y, cy-x, col)
+		x++
+		if f < 0 {
+			f += 2*x + 1
+		} else {
+			y--
+			f += 2*(x-y) + 1
+		}
+	}
+}
+
+// same walk as Circ, but with spans. rows get drawn more than once, which
+// is harmless since spans are position based
+func (c Canvas) CircFill(cx, cy, r int, col uint8) {
+	if r < 0 || !c.overlaps(cx-r, cy-r, cx+r, cy+r) {
+		return
+	}
+	if r > exactReach {
+		c.ellipseRows(cx-r, cy-r, cx+r, cy+r, col, true)
+		return
+	}
+	x, y := 0, r
+	f := 1 - r
+	for x <= y {
+		c.hline(cx-x, cx+x, cy+y, col)
+		c.hline(cx-x, cx+x, cy-y, col)
+		c.hline(cx-y, cx+y, cy+x, col)
+		c.hline(cx-y, cx+y, cy-x, col)
+		x++
+		if f < 0 {
+			f += 2*x + 1
+		} else {
+			y--
+			f += 2*(x-y) + 1
+		}
+	}
+}
+
+func (c Canvas) Oval(x0, y0, x1, y1 int, col uint8) {
+	c.ellipse(x0, y0, x1, y1, col, false)
+}
+
+func (c Canvas) OvalFill(x0, y0, x1, y1 int, col uint8) {
+	c.ellipse(x0, y0, x1, y1, col, true)
+}
+
+// ellipse inscribed in the rectangle, after Alois Zingl's rasterizer
+func (c Canvas) ellipse(x0, y0, x1, y1 int, col uint8, fill bool) {
+	if !c.overlaps(min(x0, x1), min(y0, y1), max(x0, x1), max(y0, y1)) {
+		return
+	}
+	if abs(x1-x0) > 2*exactReach || abs(y1-y0) > 2*exactReach {
+		c.ellipseRows(x0, y0, x1, y1, col, fill)
+		return
+	}
+	a := int64(abs(x1 - x0))
+	b := int64(abs(y1 - y0))
+	b1 := b & 1
+	dx := 4 * (1 - a) * b * b
+	dy := 4 * (b1 + 1) * a * a
+	err := dx + dy + b1*a*a
+
+	if x0 > x1 {
+		x0 = x1
+		x1 += int(a)
+	}
+	if y0 > y1 {
+		y0 = y1
+	}
+	y0 += int(b+1) / 2
+	y1 = y0 - int(b1)
+	a8 := 8 * a * a
+	b8 := 8 * b * b
+
+	plot := func(xa, xb, y int) {
+		if fill {
+			c.hline(xa, xb, y, col)
+		} else {
+			c.Pset(xa, y, col)
+			c.Pset(xb, y, col)
+		}
+	}
+
+	for x0 <= x1 {
+		plot(x0, x1, y0)
+		plot(x0, x1, y1)
+		e2 := 2 * err
+		if e2 <= dy {
+			y0++
+			y1--
+			dy += a8
+			err += dy
+		}
+		if e2 >= dx || 2*err > dy {
+			x0++
+			x1--
+			dx += b8
+			err += dx
+		}
+	}
+
+	// flat ellipses stop too early, finish the tips
+	for int64(y0-y1) <= b {
+		plot(x0-1, x1+1, y0)
+		plot(x0-1, x1+1, y1)
+		y0++
+		y1--
+	}
+}
+
+// ellipseRows draws the ellipse inscribed in a box one row at a time, and
+// only the rows within the bounds
+func (c Canvas) ellipseRows(x0, y0, x1, y1 int, col uint8, fill bool) {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	cx, cy := (float64(x0)+float64(x1))/2, (float64(y0)+float64(y1))/2
+	a, b := (float64(x1)-float64(x0))/2, (float64(y1)-float64(y0))/2
+
+	// half the width of row y, -1 off the ellipse. like the walks it's
+	// measured at the row's edge nearest the middle
+	half := func(y int) float64 {
+		if y < y0 || y > y1 {
+			return -1
+		}
+		if b == 0 {
+			return a
+		}
+		d := max(math.Abs(float64(y)-cy)-0.5, 0) / b
+		return a * math.Sqrt(max(1-d*d, 0))
+	}
+
+	_, by0, _, by1 := c.Bounds()
+	for y := max(y0, by0); y <= min(y1, by1); y++ {
+		h := half(y)
+		left, right := int(math.Round(cx-h)), int(math.Round(cx+h))
+		// the outline runs in to where the narrower row next to it ends,
+		// so flat stretches stay connected
+		inner := min(half(y-1), half(y+1))
+		if fill || inner < 0 {
+			c.hline(left, right, y, col)
+			continue
+		}
+		c.hline(left, max(left, int(math.Round(cx-inner))-1), y, col)
+		c.hline(min(right, int(math.Round(cx+inner))+1), right, y, col)
+	}
+}
+
+func (c Canvas) Tri(x0, y0, x1, y1, x2, y2 int, col uint8) {
+	c.Line(x0, y0, x1, y1, col)
+	c.Line(x1, y1, x2, y2, col)
+	c.Line(x2, y2, x0, y0, col)
+}
+
+// scanline fill, each row covers the pixels between the two edges
+func (c Canvas) TriFill(x0, y0, x1, y1, x2, y2 int, col uint8) {
+	if y0 > y1 {
+		x0, y0, x1, y1 = x1, y1, x0, y0
+	}
+	if y1 > y2 {
+		x1, y1, x2, y2 = x2, y2, x1, y1
+	}
+	if y0 > y1 {
+		x0, y0, x1, y1 = x1, y1, x0, y0
+	}
+
+	edge := func(xa, ya, xb, yb, y int) int {
+		if yb == ya {
+			return xa
+		}
+		return int(math.Round(float64(xa) + float64((xb-xa)*(y-ya))/float64(yb-ya)))
+	}
+
+	_, by0, _, by1 := c.Bounds()
+	start := max(y0, by0)
+	end := min(y2, by1)
+	for y := start; y <= end; y++ {
+		long := edge(x0, y0, x2, y2, y)
+		var short int
+		if y < y1 {
+			short = edge(x0, y0, x1, y1, y)
+		} else {
+			short = edge(x1, y1, x2, y2, y)
+		}
+		c.hline(long, short, y, col)
+	}
+	// flat triangles still get their edges
+	if y0 == y2 {
+		c.hline(min(x0, x1, x2), max(x0, x1, x2), y0, col)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
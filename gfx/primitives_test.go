@@ -0,0 +1,314 @@
+package gfx
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// renders the framebuffer as rows of hex digits, with '.' for color 0
+func render(fb *Framebuffer) string {
+	var sb strings.Builder
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			c := fb.Get(x, y)
+			if c == 0 {
+				sb.WriteByte('.')
+			} else {
+				sb.WriteByte("0123456789abcdef"[c])
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func picture(rows ...string) string {
+	return strings.Join(rows, "\n") + "\n"
+}
+
+func newTestCanvas(w, h int) Canvas {
+	state := NewDrawState()
+	return Canvas{FB: NewFramebuffer(w, h), State: &state}
+}
+
+func TestPrimitives(t *testing.T) {
+	tests := []struct {
+		name     string
+		draw     func(c Canvas)
+		expected string
+	}{
+		{"line", func(c Canvas) { c.Line(0, 0, 5, 2, 7) }, picture(
+			"77....",
+			"..77..",
+			"....77",
+			"......",
+		)},
+		{"line reversed", func(c Canvas) { c.Line(5, 2, 0, 0, 7) }, picture(
+			"77....",
+			"..77..",
+			"....77",
+			"......",
+		)},
+		{"line clipped", func(c Canvas) { c.Line(-2, 1, 8, 1, 8) }, picture(
+			"......",
+			"888888",
+			"......",
+			"......",
+		)},
+		{"rect", func(c Canvas) { c.Rect(1, 0, 4, 3, 9) }, picture(
+			".9999.",
+			".9..9.",
+			".9..9.",
+			".9999.",
+		)},
+		{"rect swapped corners", func(c Canvas) { c.Rect(4, 3, 1, 0, 9) }, picture(
+			".9999.",
+			".9..9.",
+			".9..9.",
+			".9999.",
+		)},
+		{"rectfill", func(c Canvas) { c.RectFill(-3, 1, 2, 9, 3) }, picture(
+			"......",
+			"333...",
+			"333...",
+			"333...",
+		)},
+		{"fill pattern", func(c Canvas) {
+			c.State.FillPattern = 0b1010_0101_1010_0101
+			c.RectFill(0, 0, 5, 3, 0x8c)
+		}, picture(
+			"8c8c8c",
+			"c8c8c8",
+			"8c8c8c",
+			"c8c8c8",
+		)},
+		{"transparent fill pattern", func(c Canvas) {
+			c.State.FillPattern = 0b1111_0000_1111_0000
+			c.State.FillTransparent = true
+			c.RectFill(0, 0, 5, 3, 6)
+		}, picture(
+			"......",
+			"666666",
+			"......",
+			"666666",
+		)},
+		{"tri", func(c Canvas) { c.Tri(0, 0, 3, 0, 0, 3, 5) }, picture(
+			"5555..",
+			"5.5...",
+			"55....",
+			"5.....",
+		)},
+		{"trifill", func(c Canvas) { c.TriFill(0, 0, 3, 0, 0, 3, 5) }, picture(
+			"5555..",
+			"555...",
+			"55....",
+			"5.....",
+		)},
+		{"flat trifill", func(c Canvas) { c.TriFill(4, 1, 0, 1, 2, 1, 5) }, picture(
+			"......",
+			"55555.",
+			"......",
+			"......",
+		)},
+	}
+
+	for _, tt := range tests {
+		c := newTestCanvas(6, 4)
+		tt.draw(c)
+		if got := render(c.FB); got != tt.expected {
+			t.Errorf("%s wrong.\nexpected=\n%s\ngot=\n%s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestCircles(t *testing.T) {
+	tests := []struct {
+		name     string
+		draw     func(c Canvas)
+		expected string
+	}{
+		{"circ", func(c Canvas) { c.Circ(3, 3, 3, 7) }, picture(
+			"..777..",
+			".7...7.",
+			"7.....7",
+			"7.....7",
+			"7.....7",
+			".7...7.",
+			"..777..",
+		)},
+		{"circfill", func(c Canvas) { c.CircFill(3, 3, 3, 7) }, picture(
+			"..777..",
+			".77777.",
+			"7777777",
+			"7777777",
+			"7777777",
+			".77777.",
+			"..777..",
+		)},
+		{"circ radius 0", func(c Canvas) { c.Circ(3, 3, 0, 7) }, picture(
+			".......",
+			".......",
+			".......",
+			"...7...",
+			".......",
+			".......",
+			".......",
+		)},
+		{"oval", func(c Canvas) { c.Oval(0, 1, 6, 5, 8) }, picture(
+			".......",
+			"..888..",
+			".8...8.",
+			"8.....8",
+			".8...8.",
+			"..888..",
+			".......",
+		)},
+		{"ovalfill", func(c Canvas) { c.OvalFill(0, 1, 6, 5, 8) }, picture(
+			".......",
+			"..888..",
+			".88888.",
+			"8888888",
+			".88888.",
+			"..888..",
+			".......",
+		)},
+		{"oval matches circ", func(c Canvas) { c.Oval(0, 0, 6, 6, 7) }, picture(
+			"..777..",
+			".7...7.",
+			"7.....7",
+			"7.....7",
+			"7.....7",
+			".7...7.",
+			"..777..",
+		)},
+	}
+
+	for _, tt := range tests {
+		c := newTestCanvas(7, 7)
+		tt.draw(c)
+		if got := render(c.FB); got != tt.expected {
+			t.Errorf("%s wrong.\nexpected=\n%s\ngot=\n%s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestPget(t *testing.T) {
+	c := newTestCanvas(4, 4)
+	c.State.Pal(3, 11, PalDraw)
+	c.Pset(2, 2, 3)
+	if got := c.Pget(2, 2); got != 11 {
+		t.Errorf("pget wrong. expected=11, got=%d", got)
+	}
+	if got := c.Pget(-1, 9); got != 0 {
+		t.Errorf("pget outside wrong. expected=0, got=%d", got)
+	}
+}
+
+func TestHugeShapesAreClipped(t *testing.T) {
+	c := newTestCanvas(4, 4)
+	c.CircFill(2, 2, 1<<20, 1)
+	c.RectFill(-1<<30, -1<<30, 1<<30, 1<<30, 2)
+	c.OvalFill(-1000, -1000, 1000, 1000, 3)
+	c.TriFill(-1<<20, -1<<20, 1<<20, 0, 0, 1<<20, 4)
+	if got := c.Pget(0, 0); got != 4 {
+		t.Errorf("last shape should cover the corner. got=%d", got)
+	}
+}
+
+func TestHugeCoordinates(t *testing.T) {
+	tests := []struct {
+		name  string
+		draw  func(c Canvas)
+		drawn [][2]int // pixels that must be set
+		clear [][2]int // and ones that must not
+	}{
+		{"circfill", func(c Canvas) { c.CircFill(64, 64, 1e8, 1) },
+			[][2]int{{0, 0}, {127, 127}, {64, 64}}, nil},
+		{"circ around the screen", func(c Canvas) { c.Circ(64, 64, 1e8, 1) },
+			nil, [][2]int{{0, 0}, {64, 64}, {127, 127}}},
+		{"circ top on screen", func(c Canvas) { c.Circ(64, 1e8+10, 1e8, 1) },
+			[][2]int{{64, 10}, {0, 10}, {127, 10}}, [][2]int{{64, 9}, {64, 11}}},
+		{"circfill far away", func(c Canvas) { c.CircFill(-1e9, -1e9, 1e8, 1) },
+			nil, [][2]int{{0, 0}}},
+		{"ovalfill", func(c Canvas) { c.OvalFill(-3e8, -3e8, 3e8, 3e8, 1) },
+			[][2]int{{0, 0}, {127, 127}}, nil},
+		{"oval bottom on screen", func(c Canvas) { c.Oval(-3e8, -3e8, 3e8, 100, 1) },
+			[][2]int{{64, 100}}, [][2]int{{64, 99}, {64, 64}}},
+		// crosses from row 0 to row 1 at x 0
+		{"flat line", func(c Canvas) { c.Line(-3e8, 0, 3e8, 1, 1) },
+			[][2]int{{1, 1}, {127, 1}}, [][2]int{{1, 0}, {127, 0}}},
+		{"diagonal line", func(c Canvas) { c.Line(-1e8, -1e8, 1e8, 1e8, 1) },
+			[][2]int{{0, 0}, {37, 37}, {127, 127}}, [][2]int{{1, 0}, {0, 1}}},
+		{"line off screen", func(c Canvas) { c.Line(-3e8, 200, 3e8, 300, 1) },
+			nil, [][2]int{{0, 127}, {127, 127}}},
+	}
+
+	for i, tt := range tests {
+		c := newTestCanvas(128, 128)
+		start := time.Now()
+		tt.draw(c)
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Fatalf("tests[%d] - %s took too long. got=%v", i, tt.name, elapsed)
+		}
+		for _, p := range tt.drawn {
+			if c.Pget(p[0], p[1]) != 1 {
+				t.Fatalf("tests[%d] - %s didn't draw %v", i, tt.name, p)
+			}
+		}
+		for _, p := range tt.clear {
+			if c.Pget(p[0], p[1]) != 0 {
+				t.Fatalf("tests[%d] - %s drew %v", i, tt.name, p)
+			}
+		}
+	}
+}
+
+// walkLine is the plain bresenham walk Line has to match
+func walkLine(fb *Framebuffer, x0, y0, x1, y1 int, col uint8) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		if x0 >= 0 && x0 < fb.Width && y0 >= 0 && y0 < fb.Height {
+			fb.Set(x0, y0, col)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func TestLineMatchesWalk(t *testing.T) {
+	// lines in and around a small screen, from a fixed sequence
+	seed := uint32(1)
+	next := func() int {
+		seed = seed*1664525 + 1013904223
+		return int(seed>>16)%80 - 24
+	}
+	for i := 0; i < 2000; i++ {
+		x0, y0, x1, y1 := next(), next(), next(), next()
+		c := newTestCanvas(32, 32)
+		c.Line(x0, y0, x1, y1, 7)
+		expected := NewFramebuffer(32, 32)
+		walkLine(expected, x0, y0, x1, y1, 7)
+		if got, want := render(c.FB), render(expected); got != want {
+			t.Fatalf("tests[%d] - line(%d,%d,%d,%d) wrong. expected=\n%s\ngot=\n%s", i, x0, y0, x1, y1, want, got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package gfx
+
+const (
+	SpriteSize    = 8
+	SheetWidth    = 128
+	SheetHeight   = 128
+	SpritesPerRow = SheetWidth / SpriteSize
+	SpriteCount   = SpritesPerRow * (SheetHeight / SpriteSize)
+	SpriteFlags   = 8
+)
+
+// SpriteSheet is a 128x128 sheet of 8x8 sprites, each with 8 flag bits
+type SpriteSheet struct {
+	Pixels *Framebuffer
+	Flags  []uint8
+}
+
+func NewSpriteSheet() *SpriteSheet {
+	return &SpriteSheet{
+		Pixels: NewFramebuffer(SheetWidth, SheetHeight),
+		Flags:  make([]uint8, SpriteCount),
+	}
+}
+
+// SpriteOrigin is the top left pixel of sprite n on the sheet
+func SpriteOrigin(n int) (x, y int) {
+	n &= SpriteCount - 1
+	return n % SpritesPerRow * SpriteSize, n / SpritesPerRow * SpriteSize
+}
+
+// SpriteAt is the sprite under a sheet pixel
+func SpriteAt(x, y int) int {
+	return y/SpriteSize*SpritesPerRow + x/SpriteSize
+}
+
+func (s *SpriteSheet) Flag(n, f int) bool {
+	if n < 0 || n >= SpriteCount || f < 0 || f >= SpriteFlags {
+		return false
+	}
+	return s.Flags[n]>>f&1 == 1
+}
+
+func (s *SpriteSheet) SetFlag(n, f int, on bool) {
+	if n < 0 || n >= SpriteCount || f < 0 || f >= SpriteFlags {
+		return
+	}
+	if on {
+		s.Flags[n] |= 1 << f
+	} else {
+		s.Flags[n] &^= 1 << f
+	}
+}
+
+// FloodFill replaces the 4-connected area of the color at x, y with col,
+// without leaving the x0, y0 to x1, y1 rectangle (x1, y1 exclusive)
+func FloodFill(fb *Framebuffer, x, y int, col uint8, x0, y0, x1, y1 int) {
+	x0, y0 = max(x0, 0), max(y0, 0)
+	x1, y1 = min(x1, fb.Width), min(y1, fb.Height)
+	if x < x0 || x >= x1 || y < y0 || y >= y1 {
+		return
+	}
+	col &= PaletteSize - 1
+	target := fb.Get(x, y)
+	if target == col {
+		return
+	}
+
+	stack := [][2]int{{x, y}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		px, py := p[0], p[1]
+		if px < x0 || px >= x1 || py < y0 || py >= y1 || fb.Get(px, py) != target {
+			continue
+		}
+		fb.Set(px, py, col)
+		stack = append(stack, [2]int{px + 1, py}, [2]int{px - 1, py}, [2]int{px, py + 1}, [2]int{px, py - 1})
+	}
+}
+
+// Spr draws sprite n at x, y. w and h are in sprites and may be fractional,
+// so 0.5 draws the top left 4x4 pixels.
+func (c Canvas) Spr(sheet *SpriteSheet, n int, x, y int, w, h float64, flipX, flipY bool) {
+	sx, sy := SpriteOrigin(n)
+	pw := int(w * SpriteSize)
+	ph := int(h * SpriteSize)
+	c.Sspr(sheet, sx, sy, pw, ph, x, y, pw, ph, flipX, flipY)
+}
+
+// Sspr stretches the sw*sh sheet rectangle at sx, sy onto the dw*dh
+// rectangle at dx, dy. negative destination sizes flip the image.
+func (c Canvas) Sspr(sheet *SpriteSheet, sx, sy, sw, sh, dx, dy, dw, dh int, flipX, flipY bool) {
+	c.Blit(sheet.Pixels, sx, sy, sw, sh, dx, dy, dw, dh, flipX, flipY)
+}
+
+// Blit copies a scaled rectangle of src, skipping transparent colors and
+// going through the draw palette, camera and clip rect. fill patterns don't
+// apply to blits.
+func (c Canvas) Blit(src *Framebuffer, sx, sy, sw, sh, dx, dy, dw, dh int, flipX, flipY bool) {
+	if sw <= 0 || sh <= 0 || dw == 0 || dh == 0 {
+		return
+	}
+	if dw < 0 {
+		dx += dw
+		dw = -dw
+		flipX = !flipX
+	}
+	if dh < 0 {
+		dy += dh
+		dh = -dh
+		flipY = !flipY
+	}
+	dx -= c.State.CameraX
+	dy -= c.State.CameraY
+
+	x0 := max(0, c.State.ClipX0-dx)
+	x1 := min(dw, c.State.ClipX1-dx)
+	y0 := max(0, c.State.ClipY0-dy)
+	y1 := min(dh, c.State.ClipY1-dy)
+	for py := y0; py < y1; py++ {
+		v := py * sh / dh
+		if flipY {
+			v = sh - 1 - v
+		}
+		for px := x0; px < x1; px++ {
+			u := px * sw / dw
+			if flipX {
+				u = sw - 1 - u
+			}
+			col := src.Get(sx+u, sy+v) & (PaletteSize - 1)
+			if c.State.Transparent[col] {
+				continue
+			}
+			c.FB.Set(dx+px, dy+py, c.State.DrawPal[col])
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package gfx
+
+import "testing"
+
+func TestSpriteOrigin(t *testing.T) {
+	tests := []struct {
+		n    int
+		x, y int
+	}{
+		{0, 0, 0},
+		{1, 8, 0},
+		{15, 120, 0},
+		{16, 0, 8},
+		{255, 120, 120},
+		{256, 0, 0},
+	}
+
+	for i, tt := range tests {
+		x, y := SpriteOrigin(tt.n)
+		if x != tt.x || y != tt.y {
+			t.Errorf("tests[%d] - origin of %d wrong. expected=(%d,%d), got=(%d,%d)", i, tt.n, tt.x, tt.y, x, y)
+		}
+		if tt.n < SpriteCount && SpriteAt(x+3, y+7) != tt.n {
+			t.Errorf("tests[%d] - SpriteAt wrong. expected=%d, got=%d", i, tt.n, SpriteAt(x+3, y+7))
+		}
+	}
+}
+
+func TestSpriteFlags(t *testing.T) {
+	s := NewSpriteSheet()
+	s.SetFlag(3, 0, true)
+	s.SetFlag(3, 7, true)
+	s.SetFlag(3, 8, true)
+	s.SetFlag(-1, 0, true)
+
+	if s.Flags[3] != 0x81 {
+		t.Fatalf("flags wrong. expected=0x81, got=%#x", s.Flags[3])
+	}
+	if !s.Flag(3, 7) || s.Flag(3, 1) || s.Flag(3, 8) {
+		t.Fatalf("Flag() wrong for %08b", s.Flags[3])
+	}
+	s.SetFlag(3, 0, false)
+	if s.Flags[3] != 0x80 {
+		t.Fatalf("clearing flag wrong. expected=0x80, got=%#x", s.Flags[3])
+	}
+}
+
+func TestFloodFill(t *testing.T) {
+	c := newTestCanvas(6, 4)
+	c.Rect(0, 0, 3, 3, 5)
+	c.Pset(5, 0, 5)
+
+	FloodFill(c.FB, 1, 1, 9, 0, 0, 6, 4)
+	FloodFill(c.FB, 4, 3, 2, 0, 0, 5, 4)
+
+	expected := picture(
+		"555525",
+		"59952.",
+		"59952.",
+		"55552.",
+	)
+	if got := render(c.FB); got != expected {
+		t.Errorf("flood fill wrong.\nexpected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+// a 3x2 sprite with a transparent hole, used by the blit tests
+func newTestSheet() *SpriteSheet {
+	s := NewSpriteSheet()
+	x, y := SpriteOrigin(1)
+	rows := []string{
+		"123",
+		"4.6",
+	}
+	for j, row := range rows {
+		for i, ch := range row {
+			if ch != '.' {
+				s.Pixels.Set(x+i, y+j, uint8(ch-'0'))
+			}
+		}
+	}
+	return s
+}
+
+func TestSpr(t *testing.T) {
+	sheet := newTestSheet()
+	tests := []struct {
+		name     string
+		draw     func(c Canvas)
+		expected string
+	}{
+		{"spr", func(c Canvas) { c.Spr(sheet, 1, 1, 1, 1, 1, false, false) }, picture(
+			"eeeeee",
+			"e123ee",
+			"e4e6ee",
+			"eeeeee",
+		)},
+		{"spr flipped", func(c Canvas) { c.Spr(sheet, 1, -5, -6, 1, 1, true, true) }, picture(
+			"6e4eee",
+			"321eee",
+			"eeeeee",
+			"eeeeee",
+		)},
+		{"spr half size", func(c Canvas) { c.Spr(sheet, 1, 0, 0, 0.25, 0.25, false, false) }, picture(
+			"12eeee",
+			"4eeeee",
+			"eeeeee",
+			"eeeeee",
+		)},
+		{"spr palette and transparency", func(c Canvas) {
+			c.State.Palt(0, false)
+			c.State.Palt(2, true)
+			c.State.Pal(6, 9, PalDraw)
+			c.Spr(sheet, 1, 0, 0, 0.5, 0.25, false, false)
+		}, picture(
+			"1e3.ee",
+			"4.9.ee",
+			"eeeeee",
+			"eeeeee",
+		)},
+		{"spr camera and clip", func(c Canvas) {
+			c.State.Camera(-2, 0)
+			c.State.Clip(0, 0, 4, 1)
+			c.Spr(sheet, 1, 0, 0, 1, 1, false, false)
+		}, picture(
+			"ee12ee",
+			"eeeeee",
+			"eeeeee",
+			"eeeeee",
+		)},
+		{"sspr stretched", func(c Canvas) {
+			x, y := SpriteOrigin(1)
+			c.Sspr(sheet, x, y, 3, 2, 0, 0, 6, 4, false, false)
+		}, picture(
+			"112233",
+			"112233",
+			"44ee66",
+			"44ee66",
+		)},
+		{"sspr negative size flips", func(c Canvas) {
+			x, y := SpriteOrigin(1)
+			c.Sspr(sheet, x, y, 3, 1, 3, 0, -3, 1, false, false)
+		}, picture(
+			"321eee",
+			"eeeeee",
+			"eeeeee",
+			"eeeeee",
+		)},
+	}
+
+	for _, tt := range tests {
+		c := newTestCanvas(6, 4)
+		c.FB.Clear(14)
+		tt.draw(c)
+		if got := render(c.FB); got != tt.expected {
+			t.Errorf("%s wrong.\nexpected=\n%s\ngot=\n%s", tt.name, tt.expected, got)
+		}
+	}
+}
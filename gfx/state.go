@@ -0,0 +1,167 @@
+package gfx
+
+const (
+	PalDraw    = 0 // remaps colors as they are drawn
+	PalDisplay = 1 // remaps colors when the framebuffer is shown
+)
+
+const DefaultColor = 7
+
+const (
+	ScreenWidth  = 128
+	ScreenHeight = 128
+)
+
+// DrawState is everything that affects how drawing calls end up in the
+// framebuffer
+type DrawState struct {
+	Color       uint8
+	DrawPal     [PaletteSize]uint8
+	DisplayPal  [PaletteSize]uint8
+	Transparent [PaletteSize]bool
+
+	// 4x4 pattern, bit 15 is the top left pixel. set bits use the high
+	// nibble of the color, or are skipped when FillTransparent is set
+	FillPattern     uint16
+	FillTransparent bool
+
+	// subtracted from every coordinate before drawing
+	CameraX int
+	CameraY int
+
+	// screen space clip rectangle, x1 and y1 are exclusive
+	ClipX0 int
+	ClipY0 int
+	ClipX1 int
+	ClipY1 int
+}
+
+func NewDrawState() DrawState {
+	var s DrawState
+	s.Reset()
+	return s
+}
+
+func (s *DrawState) Reset() {
+	s.Color = DefaultColor
+	s.ResetPal()
+	s.ResetPalt()
+	s.FillPattern = 0
+	s.FillTransparent = false
+	s.CameraX, s.CameraY = 0, 0
+	s.ResetClip()
+}
+
+func (s *DrawState) ResetPal() {
+	for i := range s.DrawPal {
+		s.DrawPal[i] = uint8(i)
+		s.DisplayPal[i] = uint8(i)
+	}
+}
+
+// Pal maps c0 to c1 in the draw or display palette
+func (s *DrawState) Pal(c0, c1 uint8, p int) {
+	c0 &= PaletteSize - 1
+	c1 &= PaletteSize - 1
+	if p == PalDisplay {
+		s.DisplayPal[c0] = c1
+	} else {
+		s.DrawPal[c0] = c1
+	}
+}
+
+// by default only color 0 is transparent when blitting sprites
+func (s *DrawState) ResetPalt() {
+	for i := range s.Transparent {
+		s.Transparent[i] = i == 0
+	}
+}
+
+func (s *DrawState) Palt(c uint8, transparent bool) {
+	s.Transparent[c&(PaletteSize-1)] = transparent
+}
+
+func (s *DrawState) Camera(x, y int) {
+	s.CameraX, s.CameraY = x, y
+}
+
+func (s *DrawState) ResetClip() {
+	s.ClipX0, s.ClipY0 = 0, 0
+	s.ClipX1, s.ClipY1 = ScreenWidth, ScreenHeight
+}
+
+// Clip limits drawing to the w*h rectangle at x, y, intersected with the
+// screen
+func (s *DrawState) Clip(x, y, w, h int) {
+	s.ClipX0 = min(max(x, 0), ScreenWidth)
+	s.ClipY0 = min(max(y, 0), ScreenHeight)
+	s.ClipX1 = min(max(x+max(w, 0), s.ClipX0), ScreenWidth)
+	s.ClipY1 = min(max(y+max(h, 0), s.ClipY0), ScreenHeight)
+}
+
+// draw state as it appears in memory:
+//
+//	0x00-0x0f  draw palette
+//	0x10-0x1f  display palette
+//	0x20-0x21  transparent colors, bit i is color i
+//	0x22       pen color
+//	0x23       fill pattern transparency, 0 or 1
+//	0x24-0x25  fill pattern
+//	0x26-0x29  camera x and y, signed
+//	0x2a-0x2d  clip x0, y0, x1 and y1
+//
+// 16 bit values are little endian
+const StateBytes = 0x2e
+
+// Encode writes the state into b, which must hold StateBytes
+func (s *DrawState) Encode(b []byte) {
+	copy(b[0x00:0x10], s.DrawPal[:])
+	copy(b[0x10:0x20], s.DisplayPal[:])
+	var transparent uint16
+	for i, t := range s.Transparent {
+		if t {
+			transparent |= 1 << i
+		}
+	}
+	putUint16(b[0x20:], transparent)
+	b[0x22] = s.Color
+	b[0x23] = 0
+	if s.FillTransparent {
+		b[0x23] = 1
+	}
+	putUint16(b[0x24:], s.FillPattern)
+	putUint16(b[0x26:], uint16(int16(s.CameraX)))
+	putUint16(b[0x28:], uint16(int16(s.CameraY)))
+	b[0x2a] = uint8(s.ClipX0)
+	b[0x2b] = uint8(s.ClipY0)
+	b[0x2c] = uint8(s.ClipX1)
+	b[0x2d] = uint8(s.ClipY1)
+}
+
+// Decode reads the state back from b, keeping every field in range
+func (s *DrawState) Decode(b []byte) {
+	for i := 0; i < PaletteSize; i++ {
+		s.DrawPal[i] = b[i] & (PaletteSize - 1)
+		s.DisplayPal[i] = b[0x10+i] & (PaletteSize - 1)
+	}
+	transparent := getUint16(b[0x20:])
+	for i := range s.Transparent {
+		s.Transparent[i] = transparent>>i&1 == 1
+	}
+	s.Color = b[0x22]
+	s.FillTransparent = b[0x23] != 0
+	s.FillPattern = getUint16(b[0x24:])
+	s.CameraX = int(int16(getUint16(b[0x26:])))
+	s.CameraY = int(int16(getUint16(b[0x28:])))
+	x0, y0 := int(b[0x2a]), int(b[0x2b])
+	s.Clip(x0, y0, int(b[0x2c])-x0, int(b[0x2d])-y0)
+}
+
+func putUint16(b []byte, v uint16) {
+	b[0] = uint8(v)
+	b[1] = uint8(v >> 8)
+}
+
+func getUint16(b []byte) uint16 {
+	return uint16(b[0]) | uint16(b[1])<<8
+}
@@ -0,0 +1,46 @@
+package gfx
+
+import "testing"
+
+func TestStateEncoding(t *testing.T) {
+	s := NewDrawState()
+	s.Color = 12
+	s.Pal(3, 9, PalDraw)
+	s.Pal(1, 14, PalDisplay)
+	s.Palt(0, false)
+	s.Palt(5, true)
+	s.FillPattern = 0xa5a5
+	s.FillTransparent = true
+	s.Camera(-20, 300)
+	s.Clip(4, 8, 100, 200)
+
+	b := make([]byte, StateBytes)
+	s.Encode(b)
+	var got DrawState
+	got.Decode(b)
+	if got != s {
+		t.Fatalf("state wrong after a round trip.\nexpected=%+v\ngot=%+v", s, got)
+	}
+
+	// bytes poked from lua can be anything, decoding keeps them in range
+	for i := range b {
+		b[i] = 0xff
+	}
+	got.Decode(b)
+	tests := []struct {
+		name     string
+		got      int
+		expected int
+	}{
+		{"draw pal", int(got.DrawPal[0]), PaletteSize - 1},
+		{"display pal", int(got.DisplayPal[2]), PaletteSize - 1},
+		{"camera x", got.CameraX, -1},
+		{"clip x0", got.ClipX0, ScreenWidth},
+		{"clip x1", got.ClipX1, ScreenWidth},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong. expected=%d, got=%d", i, tt.name, tt.expected, tt.got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package gfx
+
+const (
+	MapWidth  = 128
+	MapHeight = 64
+)
+
+// TileMap holds one sprite index per cell, row by row
+type TileMap struct {
+	Width  int
+	Height int
+	Cells  []uint8
+}
+
+func NewTileMap() *TileMap {
+	return &TileMap{
+		Width:  MapWidth,
+		Height: MapHeight,
+		Cells:  make([]uint8, MapWidth*MapHeight),
+	}
+}
+
+// Get returns 0 outside of the map
+func (m *TileMap) Get(x, y int) uint8 {
+	if x < 0 || x >= m.Width || y < 0 || y >= m.Height {
+		return 0
+	}
+	return m.Cells[y*m.Width+x]
+}
+
+func (m *TileMap) Set(x, y int, v uint8) {
+	if x < 0 || x >= m.Width || y < 0 || y >= m.Height {
+		return
+	}
+	m.Cells[y*m.Width+x] = v
+}
+
+// Matches reports whether sprite n is drawn for a layer mask: every bit of
+// the mask must be set in the sprite's flags. a zero mask matches everything.
+func (s *SpriteSheet) Matches(n uint8, layer uint8) bool {
+	return layer == 0 || s.Flags[n]&layer == layer
+}
+
+// Map draws cw*ch cells starting at cell cx, cy with the top left at sx, sy.
+// empty cells (sprite 0) are skipped.
+func (c Canvas) Map(m *TileMap, sheet *SpriteSheet, cx, cy, sx, sy, cw, ch int, layer uint8) {
+	// skip the rows and columns that can't reach the clip rect
+	x0, y0, x1, y1 := c.Bounds()
+	for j := max(0, (y0-sy)/SpriteSize-1); j < ch; j++ {
+		py := sy + j*SpriteSize
+		if py > y1 {
+			break
+		}
+		for i := max(0, (x0-sx)/SpriteSize-1); i < cw; i++ {
+			px := sx + i*SpriteSize
+			if px > x1 {
+				break
+			}
+			n := m.Get(cx+i, cy+j)
+			if n == 0 || !sheet.Matches(n, layer) {
+				continue
+			}
+			c.Spr(sheet, int(n), px, py, 1, 1, false, false)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package gfx
+
+import "testing"
+
+func TestTileMapBounds(t *testing.T) {
+	m := NewTileMap()
+	m.Set(127, 63, 9)
+	m.Set(128, 0, 9)
+	m.Set(-1, 5, 9)
+
+	if got := m.Get(127, 63); got != 9 {
+		t.Errorf("mget wrong. expected=9, got=%d", got)
+	}
+	if got := m.Get(128, 0); got != 0 {
+		t.Errorf("mget outside wrong. expected=0, got=%d", got)
+	}
+	for i, v := range m.Cells[:len(m.Cells)-1] {
+		if v != 0 {
+			t.Fatalf("out of bounds mset wrote cell %d", i)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	sheet := NewSpriteSheet()
+	// sprite 1 is solid 3s with flag 0, sprite 2 solid 5s with flags 0 and 1
+	c := Canvas{FB: sheet.Pixels, State: &DrawState{}}
+	*c.State = NewDrawState()
+	c.RectFill(8, 0, 15, 7, 3)
+	c.RectFill(16, 0, 23, 7, 5)
+	sheet.SetFlag(1, 0, true)
+	sheet.SetFlag(2, 0, true)
+	sheet.SetFlag(2, 1, true)
+
+	m := NewTileMap()
+	m.Set(0, 0, 1)
+	m.Set(1, 0, 2)
+	m.Set(1, 1, 1)
+
+	tests := []struct {
+		name     string
+		draw     func(c Canvas)
+		expected string
+	}{
+		{"whole map", func(c Canvas) { c.Map(m, sheet, 0, 0, 0, 0, 2, 2, 0) }, picture(
+			"3333333355555555",
+			"3333333355555555",
+			"3333333355555555",
+			"3333333355555555",
+			"3333333355555555",
+			"3333333355555555",
+			"3333333355555555",
+			"3333333355555555",
+			"eeeeeeee33333333",
+			"eeeeeeee33333333",
+		)},
+		{"layer mask", func(c Canvas) { c.Map(m, sheet, 0, 0, 0, 0, 2, 2, 0b10) }, picture(
+			"eeeeeeee55555555",
+			"eeeeeeee55555555",
+			"eeeeeeee55555555",
+			"eeeeeeee55555555",
+			"eeeeeeee55555555",
+			"eeeeeeee55555555",
+			"eeeeeeee55555555",
+			"eeeeeeee55555555",
+			"eeeeeeeeeeeeeeee",
+			"eeeeeeeeeeeeeeee",
+		)},
+		{"offset", func(c Canvas) { c.Map(m, sheet, 1, 0, 4, -6, 1, 2, 0) }, picture(
+			"eeee55555555eeee",
+			"eeee55555555eeee",
+			"eeee33333333eeee",
+			"eeee33333333eeee",
+			"eeee33333333eeee",
+			"eeee33333333eeee",
+			"eeee33333333eeee",
+			"eeee33333333eeee",
+			"eeee33333333eeee",
+			"eeee33333333eeee",
+		)},
+		{"camera", func(c Canvas) {
+			c.State.Camera(12, 0)
+			c.Map(m, sheet, 0, 0, 0, 0, 2, 1, 0)
+		}, picture(
+			"5555eeeeeeeeeeee",
+			"5555eeeeeeeeeeee",
+			"5555eeeeeeeeeeee",
+			"5555eeeeeeeeeeee",
+			"5555eeeeeeeeeeee",
+			"5555eeeeeeeeeeee",
+			"5555eeeeeeeeeeee",
+			"5555eeeeeeeeeeee",
+			"eeeeeeeeeeeeeeee",
+			"eeeeeeeeeeeeeeee",
+		)},
+	}
+
+	for _, tt := range tests {
+		c := newTestCanvas(16, 10)
+		c.FB.Clear(14)
+		tt.draw(c)
+		if got := render(c.FB); got != tt.expected {
+			t.Errorf("%s wrong.\nexpected=\n%s\ngot=\n%s", tt.name, tt.expected, got)
+		}
+	}
+}
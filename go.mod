@@ -7,15 +7,18 @@ require (
 	golang.org/x/image v0.28.0 // indirect
 )
 
-require github.com/aarzilli/golua v0.0.0-20250217091409-248753f411c4
+require (
+	github.com/aarzilli/golua v0.0.0-20250217091409-248753f411c4
+	github.com/yuin/gopher-lua v1.1.1
+)
 
 require (
 	github.com/ebitengine/gomobile v0.0.0-20250329061421-6d0a8e981e4c // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
+	github.com/ebitengine/oto/v3 v3.3.3 // indirect
 	github.com/ebitengine/purego v0.8.4 // indirect
 	github.com/go-text/typesetting v0.3.0 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
-	github.com/yuin/gopher-lua v1.1.1 // indirect
 	golang.org/x/sync v0.15.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
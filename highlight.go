@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/mrdapoyo/dofi/syntax"
+)
+
+// drawHighlighted draws line[start:end], one wrapped row of a code editor
+// line, at the left of dst with every token in its theme color
+func (g *Game) drawHighlighted(dst *ebiten.Image, line string, start, end int, tokens []syntax.Token) {
+	advance := g.Screen.FontWidth + 1
+	for _, t := range tokens {
+		s, e := max(t.Start, start), min(t.End, end)
+		if s >= e {
+			continue
+		}
+		drawLabel(dst, (s-start)*advance, 1, line[s:e], g.Theme[t.Kind])
+	}
+}
@@ -0,0 +1,118 @@
+package input
+
+// virtual controllers, fed once per game frame with whatever buttons the
+// keyboard, gamepads or tests are holding
+
+const (
+	Left = iota
+	Right
+	Up
+	Down
+	O
+	X
+	Start
+	Select
+
+	Buttons = 8
+	Players = 4
+
+	// like pico-8 at 30fps: btnp repeats after 15 frames, then every 4
+	DefaultRepeatDelay    = 15
+	DefaultRepeatInterval = 4
+)
+
+// Mask is one bit per button, bit i is button i
+type Mask uint8
+
+func (m Mask) Has(i int) bool {
+	return i >= 0 && i < Buttons && m>>i&1 == 1
+}
+
+type Controller struct {
+	RepeatDelay    int
+	RepeatInterval int
+
+	held     [Players]Mask
+	frames   [Players][Buttons]int // frames each button has been down, 0 when up
+	injected [Players]Mask
+}
+
+func NewController() *Controller {
+	return &Controller{
+		RepeatDelay:    DefaultRepeatDelay,
+		RepeatInterval: DefaultRepeatInterval,
+	}
+}
+
+// Update starts a new frame with the buttons each player is holding.
+// injected buttons are added on top.
+func (c *Controller) Update(pressed [Players]Mask) {
+	for p := 0; p < Players; p++ {
+		c.held[p] = pressed[p] | c.injected[p]
+		for i := 0; i < Buttons; i++ {
+			if c.held[p].Has(i) {
+				c.frames[p][i]++
+			} else {
+				c.frames[p][i] = 0
+			}
+		}
+	}
+}
+
+// Inject holds or releases a button as if it was pressed, from the next
+// Update on
+func (c *Controller) Inject(p, i int, down bool) {
+	if p < 0 || p >= Players || i < 0 || i >= Buttons {
+		return
+	}
+	if down {
+		c.injected[p] |= 1 << i
+	} else {
+		c.injected[p] &^= 1 << i
+	}
+}
+
+// ClearInjected releases every injected button
+func (c *Controller) ClearInjected() {
+	c.injected = [Players]Mask{}
+}
+
+// Btn reports whether button i of player p is down
+func (c *Controller) Btn(i, p int) bool {
+	if p < 0 || p >= Players {
+		return false
+	}
+	return c.held[p].Has(i)
+}
+
+// Btnp reports whether button i of player p was pressed this frame, or is
+// repeating after being held
+func (c *Controller) Btnp(i, p int) bool {
+	if p < 0 || p >= Players || i < 0 || i >= Buttons {
+		return false
+	}
+	f := c.frames[p][i]
+	if f == 1 {
+		return true
+	}
+	return c.RepeatDelay > 0 && f > c.RepeatDelay && (f-c.RepeatDelay-1)%max(c.RepeatInterval, 1) == 0
+}
+
+// Held is every button player p is holding
+func (c *Controller) Held(p int) Mask {
+	if p < 0 || p >= Players {
+		return 0
+	}
+	return c.held[p]
+}
+
+// Pressed is every button btnp would report for player p
+func (c *Controller) Pressed(p int) Mask {
+	var m Mask
+	for i := 0; i < Buttons; i++ {
+		if c.Btnp(i, p) {
+			m |= 1 << i
+		}
+	}
+	return m
+}
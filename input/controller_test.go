@@ -0,0 +1,116 @@
+package input
+
+import "testing"
+
+func press(p int, buttons ...int) [Players]Mask {
+	var pressed [Players]Mask
+	for _, b := range buttons {
+		pressed[p] |= 1 << b
+	}
+	return pressed
+}
+
+func TestBtn(t *testing.T) {
+	c := NewController()
+	c.Update(press(1, Left, X))
+
+	tests := []struct {
+		button   int
+		player   int
+		expected bool
+	}{
+		{Left, 1, true},
+		{X, 1, true},
+		{Right, 1, false},
+		{Left, 0, false},
+		{Left, 4, false},
+		{Buttons, 1, false},
+	}
+
+	for i, tt := range tests {
+		if got := c.Btn(tt.button, tt.player); got != tt.expected {
+			t.Fatalf("tests[%d] - btn(%d,%d) wrong. expected=%t, got=%t", i, tt.button, tt.player, tt.expected, got)
+		}
+	}
+	if got := c.Held(1); got != 1<<Left|1<<X {
+		t.Fatalf("held mask wrong. got=%08b", got)
+	}
+}
+
+func TestBtnpRepeat(t *testing.T) {
+	c := NewController()
+	var fired []int
+	for frame := 1; frame <= 30; frame++ {
+		c.Update(press(0, O))
+		if c.Btnp(O, 0) {
+			fired = append(fired, frame)
+		}
+	}
+
+	expected := []int{1, 16, 20, 24, 28}
+	if len(fired) != len(expected) {
+		t.Fatalf("btnp frames wrong. expected=%v, got=%v", expected, fired)
+	}
+	for i := range expected {
+		if fired[i] != expected[i] {
+			t.Fatalf("btnp frames wrong. expected=%v, got=%v", expected, fired)
+		}
+	}
+
+	// releasing and pressing again fires right away
+	c.Update(press(0))
+	if c.Btnp(O, 0) || c.Btn(O, 0) {
+		t.Fatalf("button still down after release")
+	}
+	c.Update(press(0, O))
+	if !c.Btnp(O, 0) {
+		t.Fatalf("btnp not fired on a new press")
+	}
+}
+
+func TestBtnpNoRepeat(t *testing.T) {
+	c := NewController()
+	c.RepeatDelay = 0
+	count := 0
+	for frame := 0; frame < 60; frame++ {
+		c.Update(press(2, Up))
+		if c.Btnp(Up, 2) {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("btnp fired %d times, expected once", count)
+	}
+}
+
+func TestInject(t *testing.T) {
+	c := NewController()
+	c.Inject(3, Down, true)
+	c.Inject(3, Start, true)
+	c.Inject(9, Down, true) // ignored
+
+	c.Update(press(3))
+	if !c.Btn(Down, 3) || !c.Btnp(Start, 3) {
+		t.Fatalf("injected buttons not down. got=%08b", c.Held(3))
+	}
+	if got := c.Pressed(3); got != 1<<Down|1<<Start {
+		t.Fatalf("pressed mask wrong. got=%08b", got)
+	}
+
+	c.Update(press(3))
+	if c.Btnp(Down, 3) {
+		t.Fatalf("held injected button repeated too early")
+	}
+
+	c.Inject(3, Down, false)
+	c.Update(press(3))
+	if c.Btn(Down, 3) || !c.Btn(Start, 3) {
+		t.Fatalf("release wrong. got=%08b", c.Held(3))
+	}
+
+	c.ClearInjected()
+	c.Update(press(3))
+	if c.Held(3) != 0 {
+		t.Fatalf("buttons still held after clearing. got=%08b", c.Held(3))
+	}
+}
@@ -0,0 +1,71 @@
+package input
+
+// mouse and keyboard state for devkit scripts, captured once per frame
+
+const (
+	MouseLeft = 1 << iota
+	MouseRight
+	MouseMiddle
+)
+
+type Mouse struct {
+	X, Y    int
+	Buttons uint8 // MouseLeft | MouseRight | MouseMiddle
+	Wheel   int   // wheel steps since the last frame, up is positive
+
+	prev uint8
+}
+
+func (m *Mouse) Update(x, y int, buttons uint8, wheel int) {
+	m.prev = m.Buttons
+	m.X, m.Y = x, y
+	m.Buttons = buttons
+	m.Wheel = wheel
+}
+
+// Pressed reports whether any of buttons went down this frame
+func (m *Mouse) Pressed(buttons uint8) bool {
+	return m.Buttons&^m.prev&buttons != 0
+}
+
+// Keyboard tracks keys by code, the codes are up to the caller
+type Keyboard struct {
+	held  map[int]bool
+	prev  map[int]bool
+	typed []rune
+}
+
+// Update starts a new frame with the keys down and the characters typed
+// since the last one
+func (k *Keyboard) Update(held []int, typed []rune) {
+	k.prev = k.held
+	k.held = make(map[int]bool, len(held))
+	for _, key := range held {
+		k.held[key] = true
+	}
+	k.typed = append(k.typed[:0], typed...)
+}
+
+func (k *Keyboard) Down(key int) bool {
+	return k.held[key]
+}
+
+// Pressed reports whether key went down this frame
+func (k *Keyboard) Pressed(key int) bool {
+	return k.held[key] && !k.prev[key]
+}
+
+// NextChar takes the next character typed this frame
+func (k *Keyboard) NextChar() (rune, bool) {
+	if len(k.typed) == 0 {
+		return 0, false
+	}
+	r := k.typed[0]
+	k.typed = k.typed[1:]
+	return r, true
+}
+
+// HasChars reports whether there are typed characters left
+func (k *Keyboard) HasChars() bool {
+	return len(k.typed) > 0
+}
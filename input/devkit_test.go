@@ -0,0 +1,59 @@
+package input
+
+import "testing"
+
+func TestMouse(t *testing.T) {
+	var m Mouse
+	tests := []struct {
+		buttons uint8
+		wheel   int
+		pressed uint8
+	}{
+		{0, 0, 0},
+		{MouseLeft, 1, MouseLeft},
+		{MouseLeft | MouseRight, 0, MouseRight},
+		{MouseRight, -2, 0},
+		{0, 0, 0},
+	}
+
+	for i, tt := range tests {
+		m.Update(i, i*2, tt.buttons, tt.wheel)
+		if m.X != i || m.Y != i*2 || m.Wheel != tt.wheel {
+			t.Fatalf("tests[%d] - mouse wrong. got=%+v", i, m)
+		}
+		for _, b := range []uint8{MouseLeft, MouseRight, MouseMiddle} {
+			if got := m.Pressed(b); got != (tt.pressed&b != 0) {
+				t.Fatalf("tests[%d] - pressed(%d) wrong. expected=%t, got=%t", i, b, tt.pressed&b != 0, got)
+			}
+		}
+	}
+}
+
+func TestKeyboard(t *testing.T) {
+	var k Keyboard
+	k.Update([]int{4, 7}, []rune("hi"))
+	if !k.Down(4) || !k.Pressed(4) || k.Down(5) {
+		t.Fatalf("keys wrong after first frame")
+	}
+
+	var typed []rune
+	for k.HasChars() {
+		r, _ := k.NextChar()
+		typed = append(typed, r)
+	}
+	if string(typed) != "hi" {
+		t.Fatalf("typed wrong. expected=%q, got=%q", "hi", string(typed))
+	}
+	if _, ok := k.NextChar(); ok {
+		t.Fatalf("characters left after reading them all")
+	}
+
+	k.Update([]int{4}, nil)
+	if !k.Down(4) || k.Pressed(4) || k.Down(7) {
+		t.Fatalf("keys wrong after second frame")
+	}
+	k.Update([]int{7}, nil)
+	if !k.Pressed(7) || k.Down(4) {
+		t.Fatalf("keys wrong after third frame")
+	}
+}
@@ -0,0 +1,116 @@
+package loop
+
+import "time"
+
+// TickRate is how often the host calls Update, ebiten's fixed timestep
+const TickRate = 60
+
+// MaxCatchUp is the most frames one tick runs. after a longer stall, like
+// a hidden window, the rest is dropped instead of running all at once.
+const MaxCatchUp = 4
+
+// Timer paces a cartridge's frames by the time that really passed between
+// host ticks and measures how long they take. a frame is one _update and
+// usually one _draw.
+type Timer struct {
+	FPS   int // 30 for _update, 60 for _update60
+	Now   func() time.Time
+	Skips int // draws dropped because a frame ran over
+
+	// KeepDraws never drops a draw, so recordings see every frame
+	KeepDraws bool
+
+	last       time.Time     // the last tick, zero before the first
+	due        time.Duration // time not yet run as frames, may be a bit below 0
+	updateCost time.Duration
+	drawCost   time.Duration
+	lastCost   time.Duration // update and draw of the last finished frame
+	skipped    bool
+	draws      []time.Time // when the frames of the last second were drawn
+}
+
+func New(fps int) *Timer {
+	return &Timer{FPS: fps, Now: time.Now}
+}
+
+// FrameTime is the budget of one frame
+func (t *Timer) FrameTime() time.Duration {
+	return time.Second / time.Duration(max(t.FPS, 1))
+}
+
+// Tick runs on every host tick and reports how many frames are due, at
+// most MaxCatchUp. the first tick runs one frame.
+func (t *Timer) Tick() int {
+	now := t.Now()
+	frame := t.FrameTime()
+	if t.last.IsZero() {
+		t.last = now
+		return 1
+	}
+	t.due += now.Sub(t.last)
+	t.last = now
+	// a frame a little early still counts, so ticks that jitter around
+	// the frame time don't run none and then two
+	frames := int((t.due + frame/4) / frame)
+	if frames > MaxCatchUp {
+		t.due = 0
+		return MaxCatchUp
+	}
+	t.due -= time.Duration(frames) * frame
+	return frames
+}
+
+// Updated records how long _update took and reports whether this frame
+// should be drawn. when the last frame ran over its budget the draw is
+// skipped to catch up, but never twice in a row.
+func (t *Timer) Updated(cost time.Duration) bool {
+	t.updateCost = cost
+	if t.lastCost > t.FrameTime() && !t.skipped && !t.KeepDraws {
+		t.skipped = true
+		t.Skips++
+		t.lastCost = cost
+		return false
+	}
+	t.skipped = false
+	return true
+}
+
+// Drew records how long _draw took
+func (t *Timer) Drew(cost time.Duration) {
+	t.drawCost = cost
+	t.lastCost = t.updateCost + cost
+	now := t.Now()
+	t.draws = append(t.draws, now)
+	old := 0
+	for old < len(t.draws) && now.Sub(t.draws[old]) >= time.Second {
+		old++
+	}
+	t.draws = t.draws[old:]
+}
+
+// UpdateTime is how long the last _update took
+func (t *Timer) UpdateTime() time.Duration {
+	return t.updateCost
+}
+
+// DrawTime is how long the last _draw that ran took
+func (t *Timer) DrawTime() time.Duration {
+	return t.drawCost
+}
+
+// CPU is how much of its budget the last frame used, 1 is all of it
+func (t *Timer) CPU() float64 {
+	return float64(t.lastCost) / float64(t.FrameTime())
+}
+
+// ActualFPS is how many frames were drawn in the last second
+func (t *Timer) ActualFPS() int {
+	now := t.Now()
+	n := 0
+	for _, d := range t.draws {
+		if now.Sub(d) < time.Second {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,150 @@
+package loop
+
+import (
+	"testing"
+	"time"
+)
+
+// a clock that only moves when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestTimer(fps int) (*Timer, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	t := New(fps)
+	t.Now = clock.Now
+	return t, clock
+}
+
+func TestTick(t *testing.T) {
+	tests := []struct {
+		fps    int
+		ticks  int // host ticks in one second
+		jitter time.Duration
+		frames int
+	}{
+		{30, 60, 0, 30},
+		{60, 60, 0, 60},
+		{15, 60, 0, 15},
+		{60, 60, time.Millisecond, 60},
+		{30, 60, 2 * time.Millisecond, 30},
+		{60, 30, 0, 60}, // a slow host runs two frames a tick
+		{30, 120, 0, 30},
+	}
+
+	for i, tt := range tests {
+		timer, clock := newTestTimer(tt.fps)
+		frames := timer.Tick()
+		for tick := 1; tick <= tt.ticks; tick++ {
+			// the clock goes back and forth by jitter around the tick
+			clock.now = time.Unix(0, 0).Add(time.Second * time.Duration(tick) / time.Duration(tt.ticks))
+			if tick%2 == 1 {
+				clock.now = clock.now.Add(tt.jitter)
+			}
+			n := timer.Tick()
+			if n > 2 {
+				t.Fatalf("tests[%d] - tick %d ran %d frames", i, tick, n)
+			}
+			frames += n
+		}
+		// the first tick's frame is the one at time 0
+		if frames != tt.frames+1 {
+			t.Fatalf("tests[%d] - frames per second wrong. expected=%d, got=%d", i, tt.frames+1, frames)
+		}
+	}
+}
+
+func TestTickCatchUp(t *testing.T) {
+	timer, clock := newTestTimer(30)
+	timer.Tick()
+
+	clock.now = clock.now.Add(time.Second)
+	if got := timer.Tick(); got != MaxCatchUp {
+		t.Fatalf("frames after a stall wrong. expected=%d, got=%d", MaxCatchUp, got)
+	}
+	// the rest of the stall is dropped
+	clock.now = clock.now.Add(time.Second / 60)
+	if got := timer.Tick(); got != 0 {
+		t.Fatalf("frames after catching up wrong. expected=0, got=%d", got)
+	}
+	clock.now = clock.now.Add(time.Second / 60)
+	if got := timer.Tick(); got != 1 {
+		t.Fatalf("frames after catching up wrong. expected=1, got=%d", got)
+	}
+}
+
+func TestSkipDraw(t *testing.T) {
+	timer, _ := newTestTimer(30)
+	budget := timer.FrameTime()
+
+	tests := []struct {
+		update time.Duration
+		draw   time.Duration
+		drawn  bool
+	}{
+		{budget / 4, budget / 4, true},
+		{budget, budget, true}, // runs over, the next draw is dropped
+		{budget, 0, false},     // skipped
+		{budget, budget, true}, // never skipped twice in a row
+		{budget / 4, 0, false}, // but that one ran over too
+		{budget / 4, budget / 4, true},
+		{budget / 4, budget / 4, true},
+	}
+
+	for i, tt := range tests {
+		drawn := timer.Updated(tt.update)
+		if drawn != tt.drawn {
+			t.Fatalf("tests[%d] - draw wrong. expected=%t, got=%t", i, tt.drawn, drawn)
+		}
+		if drawn {
+			timer.Drew(tt.draw)
+		}
+	}
+	if timer.Skips != 2 {
+		t.Fatalf("skips wrong. expected=2, got=%d", timer.Skips)
+	}
+
+	timer.KeepDraws = true
+	timer.Updated(budget)
+	timer.Drew(budget)
+	if !timer.Updated(budget) {
+		t.Fatalf("draw dropped with KeepDraws set")
+	}
+}
+
+func TestCPU(t *testing.T) {
+	timer, _ := newTestTimer(60)
+	budget := timer.FrameTime()
+
+	timer.Updated(budget / 4)
+	timer.Drew(budget / 4)
+	if got := timer.CPU(); got < 0.49 || got > 0.51 {
+		t.Fatalf("cpu wrong. expected=0.5, got=%v", got)
+	}
+	if timer.UpdateTime() != budget/4 || timer.DrawTime() != budget/4 {
+		t.Fatalf("times wrong. got=%v, %v", timer.UpdateTime(), timer.DrawTime())
+	}
+}
+
+func TestActualFPS(t *testing.T) {
+	timer, clock := newTestTimer(30)
+	// draw 20 frames over the first second, then stop
+	for i := 0; i < 20; i++ {
+		clock.now = clock.now.Add(time.Second / 20)
+		timer.Updated(0)
+		timer.Drew(0)
+	}
+	if got := timer.ActualFPS(); got != 20 {
+		t.Fatalf("fps wrong. expected=20, got=%d", got)
+	}
+
+	clock.now = clock.now.Add(time.Second / 2)
+	if got := timer.ActualFPS(); got != 10 {
+		t.Fatalf("fps after a pause wrong. expected=10, got=%d", got)
+	}
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"image/color"
+	"math"
 	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 
 	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/gfx"
 )
 
 func (g *Game) setupLuaAPI() {
@@ -16,35 +19,77 @@ func (g *Game) setupLuaAPI() {
 
 	// cls function - clear screen
 	g.LuaVM.SetGlobal("cls", g.LuaVM.NewFunction(func(L *lua.LState) int {
-		g.Screen.Buffer = [128][128]color.RGBA{}
+		g.Canvas().Cls(uint8(L.OptInt(1, 0)))
 		g.ClearLines()
 		return 0
 	}))
 
 	g.LuaVM.SetField(dofiTable, "cls", g.LuaVM.NewFunction(func(L *lua.LState) int {
-		g.Screen.Buffer = [128][128]color.RGBA{}
+		g.Canvas().Cls(uint8(L.OptInt(1, 0)))
 		g.ClearLines()
 		return 0
 	}))
 
-	// pset function - set pixel
+	// pset function - set pixel, either to a palette color or to the
+	// closest palette color of r, g, b
 	g.LuaVM.SetField(dofiTable, "pset", g.LuaVM.NewFunction(func(L *lua.LState) int {
-		x := int(L.CheckNumber(1))
-		y := int(L.CheckNumber(2))
-		r := uint8(L.CheckNumber(3))
-		green := uint8(L.CheckNumber(4))
-		b := uint8(L.CheckNumber(5))
+		x := luaInt(L, 1)
+		y := luaInt(L, 2)
+		if L.GetTop() >= 5 {
+			r := uint8(L.CheckNumber(3))
+			green := uint8(L.CheckNumber(4))
+			b := uint8(L.CheckNumber(5))
+			g.DrawPixel(x, y, g.Screen.Palette.Nearest(color.RGBA{r, green, b, 255}))
+			return 0
+		}
+
+		g.DrawPixel(x, y, g.penColor(L, 3))
+		return 0
+	}))
+
+	// color function - set the default color for drawing calls
+	g.LuaVM.SetField(dofiTable, "color", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		previous := g.DrawState.Color
+		g.DrawState.Color = uint8(L.OptInt(1, gfx.DefaultColor))
+		L.Push(lua.LNumber(previous))
+		return 1
+	}))
 
-		g.DrawPixel(x, y, color.RGBA{r, green, b, 255})
+	// pal function - pal(c0, c1, p) remaps a color, pal() resets both palettes
+	g.LuaVM.SetField(dofiTable, "pal", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			g.DrawState.ResetPal()
+			return 0
+		}
+		c0 := uint8(L.CheckInt(1))
+		c1 := uint8(L.CheckInt(2))
+		g.DrawState.Pal(c0, c1, L.OptInt(3, gfx.PalDraw))
+		return 0
+	}))
+
+	// palt function - palt(c, t) sets transparency, palt() resets it
+	g.LuaVM.SetField(dofiTable, "palt", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			g.DrawState.ResetPalt()
+			return 0
+		}
+		g.DrawState.Palt(uint8(L.CheckInt(1)), L.OptBool(2, true))
 		return 0
 	}))
 
+	g.setupDrawAPI(dofiTable)
+	g.setupSoundAPI(dofiTable)
+	g.setupInputAPI(dofiTable)
+	g.setupSystemAPI(dofiTable)
+	g.setupMemoryAPI(dofiTable)
+	g.setupMathAPI(dofiTable)
+
 	g.LuaVM.SetGlobal("print", g.LuaVM.NewFunction(func(L *lua.LState) int {
 		top := L.GetTop()
 		if top == 0 {
 			return 0
 		}
-		
+
 		if L.GetTop() >= 1 && L.CheckAny(1).Type() == lua.LTString {
 			var parts []string
 			for i := 1; i <= top; i++ {
@@ -53,24 +98,32 @@ func (g *Game) setupLuaAPI() {
 			g.AppendLine(strings.Join(parts, " "), false)
 			return 0
 		}
-		
+
 		x := int(L.OptNumber(1, 0))
 		y := int(L.OptNumber(2, 0))
 		var parts []string
 		for i := 3; i <= top; i++ {
 			parts = append(parts, L.ToString(i))
 		}
-		g.DrawText(x, y, strings.Join(parts, " "), color.RGBA{255, 255, 255, 255})
+		g.DrawText(x, y, strings.Join(parts, " "), g.DrawState.Color)
 		g.AppendLine(strings.Join(parts, " "), false)
 		return 0
 	}))
+
+	g.countCalls(dofiTable)
 }
 
 func (g *Game) RunLuaScript(script string) error {
 	defer func() {
 		g.ScriptRunning = false
 	}()
-	err := g.LuaVM.DoString(script)
+	// every run starts with a fresh vm, camera, clip and palette
+	g.ResetLuaVM()
+	g.DrawState.Reset()
+	g.DrawStack = nil
+	g.StopSound()
+	g.Memory.SaveROM()
+	err := g.Sandbox.Do(script)
 	return err
 }
 
@@ -79,30 +132,43 @@ func (g *Game) ClearLines() {
 	g.Input.CurrentInputString = ""
 }
 
-func (g *Game) DrawPixel(x, y int, c color.RGBA) {
-	if x >= 0 && x < 128 && y >= 0 && y < 128 {
-		g.Screen.Buffer[y][x] = c
-	}
-	if x < 0 || x >= 128 || y < 0 || y >= 128 {
-		g.AppendLine("Error: Pixel out of bounds", true)
-		return
+func (g *Game) Canvas() gfx.Canvas {
+	return gfx.Canvas{FB: g.Screen.Buffer, State: &g.DrawState}
+}
+
+// reads an optional color argument, falling back to the pen color. the high
+// nibble is kept for fill patterns
+func (g *Game) penColor(L *lua.LState, n int) uint8 {
+	if L.GetTop() >= n && L.Get(n) != lua.LNil {
+		g.DrawState.Color = uint8(L.CheckInt(n))
 	}
-	g.Screen.Buffer[y][x] = c
+	return g.DrawState.Color
 }
 
-func (g *Game) DrawText(x, y int, value string, c color.RGBA) {
+// coordinates are floored like pico-8 does, so -0.5 lands on -1
+func luaInt(L *lua.LState, n int) int {
+	return int(math.Floor(float64(L.CheckNumber(n))))
+}
+
+func luaOptInt(L *lua.LState, n int, d int) int {
+	return int(math.Floor(float64(L.OptNumber(n, lua.LNumber(d)))))
+}
+
+func (g *Game) DrawPixel(x, y int, c uint8) {
+	g.Canvas().Pset(x, y, c)
+}
+
+func (g *Game) DrawText(x, y int, value string, c uint8) {
 	var op = &text.DrawOptions{}
-	op.ColorScale.Scale(float32(c.R)/255, float32(c.G)/255, float32(c.B)/255, float32(c.A)/255)
 	op.GeoM.Translate(float64(x), float64(y))
 	image := ebiten.NewImage(g.Screen.Width, g.Screen.Height)
 	text.Draw(image, value, TextFace, op)
 	buffer := make([]byte, 4*g.Screen.Width*g.Screen.Height)
 	image.ReadPixels(buffer)
+	// anything the font touched becomes color c
 	for i := 0; i < len(buffer); i += 4 {
-		r := buffer[i]
-		green := buffer[i+1]
-		b := buffer[i+2]
-		a := buffer[i+3]
-		g.Screen.Buffer[y+i/4/128][x+i/4%128] = color.RGBA{r, green, b, a}
+		if buffer[i+3] > 127 {
+			g.DrawPixel(i/4%g.Screen.Width, i/4/g.Screen.Width, c)
+		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"math"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/gfx"
+)
+
+const MaxDrawStack = 32
+
+// drawing primitives, all of them take an optional color as last argument
+func (g *Game) setupDrawAPI(dofiTable *lua.LTable) {
+	// pget function - get the palette index at x, y
+	g.LuaVM.SetField(dofiTable, "pget", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(g.Canvas().Pget(luaInt(L, 1), luaInt(L, 2))))
+		return 1
+	}))
+
+	// line function - line(x0, y0, x1, y1, c)
+	g.LuaVM.SetField(dofiTable, "line", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().Line(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3), luaInt(L, 4), g.penColor(L, 5))
+		return 0
+	}))
+
+	// rect function - rect(x0, y0, x1, y1, c)
+	g.LuaVM.SetField(dofiTable, "rect", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().Rect(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3), luaInt(L, 4), g.penColor(L, 5))
+		return 0
+	}))
+
+	g.LuaVM.SetField(dofiTable, "rectfill", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().RectFill(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3), luaInt(L, 4), g.penColor(L, 5))
+		return 0
+	}))
+
+	// circ function - circ(x, y, r, c)
+	g.LuaVM.SetField(dofiTable, "circ", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().Circ(luaInt(L, 1), luaInt(L, 2), luaOptInt(L, 3, 4), g.penColor(L, 4))
+		return 0
+	}))
+
+	g.LuaVM.SetField(dofiTable, "circfill", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().CircFill(luaInt(L, 1), luaInt(L, 2), luaOptInt(L, 3, 4), g.penColor(L, 4))
+		return 0
+	}))
+
+	// oval function - oval(x0, y0, x1, y1, c), the oval fits the rectangle
+	g.LuaVM.SetField(dofiTable, "oval", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().Oval(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3), luaInt(L, 4), g.penColor(L, 5))
+		return 0
+	}))
+
+	g.LuaVM.SetField(dofiTable, "ovalfill", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().OvalFill(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3), luaInt(L, 4), g.penColor(L, 5))
+		return 0
+	}))
+
+	// tri function - tri(x0, y0, x1, y1, x2, y2, c)
+	g.LuaVM.SetField(dofiTable, "tri", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().Tri(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3), luaInt(L, 4), luaInt(L, 5), luaInt(L, 6), g.penColor(L, 7))
+		return 0
+	}))
+
+	g.LuaVM.SetField(dofiTable, "trifill", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().TriFill(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3), luaInt(L, 4), luaInt(L, 5), luaInt(L, 6), g.penColor(L, 7))
+		return 0
+	}))
+
+	// fillp function - fillp(pattern, transparent). like pico-8, a .5 in
+	// the pattern also makes the set bits transparent. fillp() resets.
+	g.LuaVM.SetField(dofiTable, "fillp", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		previous := float64(g.DrawState.FillPattern)
+		if g.DrawState.FillTransparent {
+			previous += 0.5
+		}
+
+		pattern := float64(L.OptNumber(1, 0))
+		whole, frac := math.Modf(pattern)
+		g.DrawState.FillPattern = uint16(int64(whole))
+		g.DrawState.FillTransparent = frac != 0 || L.OptBool(2, false)
+
+		L.Push(lua.LNumber(previous))
+		return 1
+	}))
+
+	// camera function - camera(x, y) offsets all drawing, camera() resets it.
+	// returns the previous offset
+	g.LuaVM.SetField(dofiTable, "camera", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(g.DrawState.CameraX))
+		L.Push(lua.LNumber(g.DrawState.CameraY))
+		g.DrawState.Camera(luaOptInt(L, 1, 0), luaOptInt(L, 2, 0))
+		return 2
+	}))
+
+	// clip function - clip(x, y, w, h) limits drawing to a screen rectangle,
+	// clip() resets it
+	g.LuaVM.SetField(dofiTable, "clip", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			g.DrawState.ResetClip()
+			return 0
+		}
+		g.DrawState.Clip(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3), luaInt(L, 4))
+		return 0
+	}))
+
+	// pushstate/popstate functions - save and restore camera, clip, palettes,
+	// color and fill pattern, e.g. around a hud
+	g.LuaVM.SetField(dofiTable, "pushstate", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if len(g.DrawStack) >= MaxDrawStack {
+			L.RaiseError("draw state stack overflow (max %d)", MaxDrawStack)
+		}
+		g.DrawStack = append(g.DrawStack, g.DrawState)
+		return 0
+	}))
+
+	g.LuaVM.SetField(dofiTable, "popstate", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if len(g.DrawStack) == 0 {
+			L.RaiseError("popstate without pushstate")
+		}
+		g.DrawState = g.DrawStack[len(g.DrawStack)-1]
+		g.DrawStack = g.DrawStack[:len(g.DrawStack)-1]
+		return 0
+	}))
+
+	// spr function - spr(n, x, y, w, h, flip_x, flip_y), w and h in sprites
+	g.LuaVM.SetField(dofiTable, "spr", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().Spr(g.Sprites, luaInt(L, 1), luaOptInt(L, 2, 0), luaOptInt(L, 3, 0),
+			float64(L.OptNumber(4, 1)), float64(L.OptNumber(5, 1)),
+			L.OptBool(6, false), L.OptBool(7, false))
+		return 0
+	}))
+
+	// sspr function - sspr(sx, sy, sw, sh, dx, dy, dw, dh, flip_x, flip_y)
+	// stretches a rectangle of the sprite sheet
+	g.LuaVM.SetField(dofiTable, "sspr", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		sw := luaInt(L, 3)
+		sh := luaInt(L, 4)
+		g.Canvas().Sspr(g.Sprites, luaInt(L, 1), luaInt(L, 2), sw, sh,
+			luaInt(L, 5), luaInt(L, 6), luaOptInt(L, 7, sw), luaOptInt(L, 8, sh),
+			L.OptBool(9, false), L.OptBool(10, false))
+		return 0
+	}))
+
+	// fget function - fget(n, f) returns one flag, fget(n) all of them as a
+	// byte
+	g.LuaVM.SetField(dofiTable, "fget", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		n := luaInt(L, 1)
+		if L.GetTop() >= 2 {
+			L.Push(lua.LBool(g.Sprites.Flag(n, luaInt(L, 2))))
+			return 1
+		}
+		if n < 0 || n >= gfx.SpriteCount {
+			L.Push(lua.LNumber(0))
+			return 1
+		}
+		L.Push(lua.LNumber(g.Sprites.Flags[n]))
+		return 1
+	}))
+
+	// fset function - fset(n, f, v) sets one flag, fset(n, v) all of them
+	g.LuaVM.SetField(dofiTable, "fset", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		n := luaInt(L, 1)
+		if L.GetTop() >= 3 {
+			g.Sprites.SetFlag(n, luaInt(L, 2), L.ToBool(3))
+			return 0
+		}
+		if n >= 0 && n < gfx.SpriteCount {
+			g.Sprites.Flags[n] = uint8(luaInt(L, 2))
+		}
+		return 0
+	}))
+
+	// map function - map(cx, cy, sx, sy, cw, ch, layer) draws cw*ch cells
+	// starting at cell cx, cy. with a layer, only sprites with all of those
+	// flag bits are drawn
+	g.LuaVM.SetField(dofiTable, "map", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Canvas().Map(g.Map, g.Sprites,
+			luaOptInt(L, 1, 0), luaOptInt(L, 2, 0), luaOptInt(L, 3, 0), luaOptInt(L, 4, 0),
+			luaOptInt(L, 5, gfx.MapWidth), luaOptInt(L, 6, gfx.MapHeight), uint8(luaOptInt(L, 7, 0)))
+		return 0
+	}))
+
+	// mget function - mget(x, y) returns the sprite in a map cell
+	g.LuaVM.SetField(dofiTable, "mget", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(g.Map.Get(luaInt(L, 1), luaInt(L, 2))))
+		return 1
+	}))
+
+	// mset function - mset(x, y, n) puts sprite n in a map cell
+	g.LuaVM.SetField(dofiTable, "mset", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Map.Set(luaInt(L, 1), luaInt(L, 2), uint8(luaInt(L, 3)))
+		return 0
+	}))
+}
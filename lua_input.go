@@ -0,0 +1,96 @@
+package main
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/input"
+)
+
+func (g *Game) setupInputAPI(dofiTable *lua.LTable) {
+	// btn function - btn(i, p) is whether button i of player p is down.
+	// btn() returns players 0 and 1 as a bitfield
+	g.LuaVM.SetField(dofiTable, "btn", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			L.Push(lua.LNumber(int(g.Buttons.Held(0)) | int(g.Buttons.Held(1))<<8))
+			return 1
+		}
+		L.Push(lua.LBool(g.Buttons.Btn(luaInt(L, 1), luaOptInt(L, 2, 0))))
+		return 1
+	}))
+
+	// btnp function - like btn, but only on the frame a button goes down and
+	// then repeating while it's held
+	g.LuaVM.SetField(dofiTable, "btnp", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			L.Push(lua.LNumber(int(g.Buttons.Pressed(0)) | int(g.Buttons.Pressed(1))<<8))
+			return 1
+		}
+		L.Push(lua.LBool(g.Buttons.Btnp(luaInt(L, 1), luaOptInt(L, 2, 0))))
+		return 1
+	}))
+
+	// devkit function - devkit(on) lets the script read the mouse and
+	// keyboard, returns whether it was on
+	g.LuaVM.SetField(dofiTable, "devkit", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		previous := g.Devkit.Enabled
+		if L.GetTop() >= 1 {
+			g.Devkit.Enabled = L.ToBool(1)
+		}
+		L.Push(lua.LBool(previous))
+		return 1
+	}))
+
+	// mouse function - returns x, y, buttons (1 left, 2 right, 4 middle) and
+	// wheel steps since the last frame, all 0 unless devkit is on
+	g.LuaVM.SetField(dofiTable, "mouse", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		m := g.Devkit.Mouse
+		if !g.Devkit.Enabled {
+			m = input.Mouse{}
+		}
+		L.Push(lua.LNumber(m.X))
+		L.Push(lua.LNumber(m.Y))
+		L.Push(lua.LNumber(m.Buttons))
+		L.Push(lua.LNumber(m.Wheel))
+		return 4
+	}))
+
+	// key function - key(name) is whether a key is down, key() takes the
+	// next character typed this frame or returns nil
+	g.LuaVM.SetField(dofiTable, "key", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			// with the devkit off the typed characters stay where they are
+			if !g.Devkit.Enabled {
+				L.Push(lua.LNil)
+				return 1
+			}
+			if r, ok := g.Devkit.Keys.NextChar(); ok {
+				L.Push(lua.LString(string(r)))
+			} else {
+				L.Push(lua.LNil)
+			}
+			return 1
+		}
+		L.Push(lua.LBool(g.Devkit.Enabled && g.Devkit.Keys.Down(luaKey(L, 1))))
+		return 1
+	}))
+
+	// keyp function - keyp(name) is whether a key went down this frame,
+	// keyp() is whether there are typed characters left
+	g.LuaVM.SetField(dofiTable, "keyp", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 {
+			L.Push(lua.LBool(g.Devkit.Enabled && g.Devkit.Keys.HasChars()))
+			return 1
+		}
+		L.Push(lua.LBool(g.Devkit.Enabled && g.Devkit.Keys.Pressed(luaKey(L, 1))))
+		return 1
+	}))
+}
+
+func luaKey(L *lua.LState, n int) int {
+	name := L.CheckString(n)
+	k, ok := keyByName(name)
+	if !ok {
+		L.ArgError(n, "unknown key "+name)
+	}
+	return int(k)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/fixed"
+)
+
+// FixedMeta is the cartridge meta key that turns on fixed point math, set to
+// "fixed" by the fixed command
+const FixedMeta = "numbers"
+
+// FixedCart reports whether the cartridge asks for 16.16 fixed point math
+func (g *Game) FixedCart() bool {
+	return g.Cart != nil && g.Cart.Meta[FixedMeta] == "fixed"
+}
+
+func luaFix(L *lua.LState, n int, def float64) fixed.Fix {
+	return fixed.FromFloat(float64(L.OptNumber(n, lua.LNumber(def))))
+}
+
+func pushFix(L *lua.LState, f fixed.Fix) {
+	L.Push(lua.LNumber(f.Float()))
+}
+
+func (g *Game) setupMathAPI(dofiTable *lua.LTable) {
+	// in fixed point mode the sandbox does the cartridge's arithmetic in
+	// 16.16, and these functions wrap their arguments to 16.16 and work on
+	// the bits, so the results are the same everywhere and match pico-8.
+	unary := map[string]struct {
+		float func(float64) float64
+		fix   func(fixed.Fix) fixed.Fix
+	}{
+		"flr":  {math.Floor, fixed.Flr},
+		"ceil": {math.Ceil, fixed.Ceil},
+		"abs":  {math.Abs, fixed.Abs},
+		"sgn": {func(x float64) float64 {
+			if x < 0 {
+				return -1
+			}
+			return 1
+		}, fixed.Sgn},
+		"sqrt": {func(x float64) float64 {
+			if x <= 0 {
+				return 0
+			}
+			return math.Sqrt(x)
+		}, fixed.Sqrt},
+		// angles are in turns and sin is upside down, for a y axis that
+		// points down
+		"sin": {func(x float64) float64 { return -math.Sin(x * 2 * math.Pi) }, fixed.Sin},
+		"cos": {func(x float64) float64 { return math.Cos(x * 2 * math.Pi) }, fixed.Cos},
+	}
+	for name, fn := range unary {
+		g.LuaVM.SetField(dofiTable, name, g.LuaVM.NewFunction(func(L *lua.LState) int {
+			if g.Play.Fixed {
+				pushFix(L, fn.fix(luaFix(L, 1, 0)))
+			} else {
+				L.Push(lua.LNumber(fn.float(float64(L.OptNumber(1, 0)))))
+			}
+			return 1
+		}))
+	}
+
+	// atan2 function - atan2(dx, dy) is the angle in turns, 0 to 1
+	g.LuaVM.SetField(dofiTable, "atan2", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if g.Play.Fixed {
+			pushFix(L, fixed.Atan2(luaFix(L, 1, 0), luaFix(L, 2, 0)))
+			return 1
+		}
+		dx, dy := float64(L.OptNumber(1, 0)), float64(L.OptNumber(2, 0))
+		if dx == 0 && dy == 0 {
+			L.Push(lua.LNumber(0.25))
+			return 1
+		}
+		a := math.Atan2(-dy, dx) / (2 * math.Pi)
+		if a < 0 {
+			a++
+		}
+		L.Push(lua.LNumber(a))
+		return 1
+	}))
+
+	// rnd function - rnd(x) is a random number from 0 up to x, rnd(t) a
+	// random element of table t
+	g.LuaVM.SetField(dofiTable, "rnd", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if t, ok := L.Get(1).(*lua.LTable); ok {
+			n := t.Len()
+			if n == 0 {
+				L.Push(lua.LNil)
+				return 1
+			}
+			L.Push(t.RawGetInt(int(g.Rand.Next()%uint32(n)) + 1))
+			return 1
+		}
+		if g.Play.Fixed {
+			pushFix(L, g.Rand.Rnd(luaFix(L, 1, 1)))
+			return 1
+		}
+		L.Push(lua.LNumber(g.Rand.Float() * float64(L.OptNumber(1, 1))))
+		return 1
+	}))
+
+	// srand function - srand(x) seeds rnd, the same seed gives the same
+	// numbers
+	g.LuaVM.SetField(dofiTable, "srand", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Rand.Seed(luaFix(L, 1, 0))
+		return 0
+	}))
+
+	// bitwise functions work on the 16.16 bits in either mode
+	binary := map[string]func(a, b fixed.Fix) fixed.Fix{
+		"band": fixed.Band,
+		"bor":  fixed.Bor,
+		"bxor": fixed.Bxor,
+	}
+	for name, fn := range binary {
+		g.LuaVM.SetField(dofiTable, name, g.LuaVM.NewFunction(func(L *lua.LState) int {
+			pushFix(L, fn(luaFix(L, 1, 0), luaFix(L, 2, 0)))
+			return 1
+		}))
+	}
+	g.LuaVM.SetField(dofiTable, "shl", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		pushFix(L, fixed.Shl(luaFix(L, 1, 0), luaOptInt(L, 2, 0)))
+		return 1
+	}))
+	g.LuaVM.SetField(dofiTable, "shr", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		pushFix(L, fixed.Shr(luaFix(L, 1, 0), luaOptInt(L, 2, 0)))
+		return 1
+	}))
+
+	// lua's own math.random would use a generator nothing seeds per run, so
+	// it draws from Rand too and a recorded run replays the same
+	if mathTable, ok := g.LuaVM.GetGlobal(lua.MathLibName).(*lua.LTable); ok {
+		g.LuaVM.SetField(mathTable, "random", g.LuaVM.NewFunction(g.mathRandom))
+		g.LuaVM.SetField(mathTable, "randomseed", g.LuaVM.NewFunction(func(L *lua.LState) int {
+			g.Rand.Seed(fixed.Fix(L.CheckInt64(1)))
+			return 0
+		}))
+	}
+}
+
+// mathRandom is math.random: a float in [0,1), or an integer in [1,m] or
+// [m,n]
+func (g *Game) mathRandom(L *lua.LState) int {
+	if L.GetTop() == 0 {
+		L.Push(lua.LNumber(g.Rand.Float()))
+		return 1
+	}
+	lo, hi := int64(1), L.CheckInt64(1)
+	if L.GetTop() >= 2 {
+		lo, hi = hi, L.CheckInt64(2)
+	}
+	if lo > hi {
+		L.ArgError(L.GetTop(), "interval is empty")
+	}
+	r := uint64(g.Rand.Next())<<32 | uint64(g.Rand.Next())
+	if n := uint64(hi-lo) + 1; n != 0 {
+		r %= n
+	}
+	L.Push(lua.LNumber(lo + int64(r)))
+	return 1
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/mem"
+)
+
+func (g *Game) setupMemoryAPI(dofiTable *lua.LTable) {
+	// peek function - peek(addr) reads a byte, see mem for the layout
+	g.LuaVM.SetField(dofiTable, "peek", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(g.Memory.Peek(luaInt(L, 1))))
+		return 1
+	}))
+
+	// peek2 and peek4 function - read signed 16 and 32 bit values
+	g.LuaVM.SetField(dofiTable, "peek2", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(g.Memory.Peek2(luaInt(L, 1))))
+		return 1
+	}))
+	g.LuaVM.SetField(dofiTable, "peek4", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(g.Memory.Peek4(luaInt(L, 1))))
+		return 1
+	}))
+
+	// poke function - poke(addr, v) writes the low byte of v
+	g.LuaVM.SetField(dofiTable, "poke", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Memory.Poke(luaInt(L, 1), uint8(luaOptInt(L, 2, 0)))
+		return 0
+	}))
+
+	// poke2 and poke4 function - write 16 and 32 bit values
+	g.LuaVM.SetField(dofiTable, "poke2", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Memory.Poke2(luaInt(L, 1), int16(luaOptInt(L, 2, 0)))
+		return 0
+	}))
+	g.LuaVM.SetField(dofiTable, "poke4", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Memory.Poke4(luaInt(L, 1), int32(luaOptInt(L, 2, 0)))
+		return 0
+	}))
+
+	// memcpy function - memcpy(dst, src, n) copies n bytes
+	g.LuaVM.SetField(dofiTable, "memcpy", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if err := g.Memory.Memcpy(luaInt(L, 1), luaInt(L, 2), luaInt(L, 3)); err != nil {
+			L.RaiseError("%v", err)
+		}
+		return 0
+	}))
+
+	// memset function - memset(dst, v, n) fills n bytes with v
+	g.LuaVM.SetField(dofiTable, "memset", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if err := g.Memory.Memset(luaInt(L, 1), uint8(luaInt(L, 2)), luaInt(L, 3)); err != nil {
+			L.RaiseError("%v", err)
+		}
+		return 0
+	}))
+
+	// reload function - reload(dst, src, n) copies cartridge data back into
+	// memory, reload() restores all of it
+	g.LuaVM.SetField(dofiTable, "reload", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		dst := luaOptInt(L, 1, 0)
+		src := luaOptInt(L, 2, 0)
+		n := luaOptInt(L, 3, mem.CartSize)
+		if err := g.Memory.Reload(dst, src, n); err != nil {
+			L.RaiseError("%v", err)
+		}
+		return 0
+	}))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	lua "github.com/yuin/gopher-lua"
+)
+
+func (g *Game) setupSoundAPI(dofiTable *lua.LTable) {
+	// sfx function - sfx(n, channel) plays sfx n, -1 stops and -2 releases
+	// a looping sfx
+	g.LuaVM.SetField(dofiTable, "sfx", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Synth.PlaySFX(luaInt(L, 1), luaOptInt(L, 2, -1))
+		return 0
+	}))
+
+	// music function - music(n, fade) plays from pattern n, -1 stops. fade
+	// is in milliseconds
+	g.LuaVM.SetField(dofiTable, "music", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		g.Synth.PlayMusic(luaOptInt(L, 1, 0), luaOptInt(L, 2, 0))
+		return 0
+	}))
+}
+
+// StopSound silences every channel, including the music
+func (g *Game) StopSound() {
+	g.Synth.PlayMusic(-1, 0)
+	g.Synth.PlaySFX(-1, -1)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/savedata"
+)
+
+func (g *Game) setupSystemAPI(dofiTable *lua.LTable) {
+	// stat function - stat(0) is the kb the cart's lua values took, measured
+	// a few times a second, stat(1) how much of the frame the last update
+	// and draw used, stat(7) the frames drawn in the last second and stat(8)
+	// the frame rate the cartridge runs at.
+	// stat(10) to stat(12) are the last update, draw and framebuffer upload
+	// in ms and stat(13) the api calls of the last frame, like the overlay
+	g.LuaVM.SetField(dofiTable, "stat", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		switch luaInt(L, 1) {
+		case 0:
+			L.Push(lua.LNumber(float64(g.Perf.Memory) / 1024))
+		case 1:
+			L.Push(lua.LNumber(g.Loop.CPU()))
+		case 7:
+			L.Push(lua.LNumber(g.Loop.ActualFPS()))
+		case 8:
+			L.Push(lua.LNumber(g.Loop.FPS))
+		case 10:
+			L.Push(lua.LNumber(ms(g.Loop.UpdateTime())))
+		case 11:
+			L.Push(lua.LNumber(ms(g.Loop.DrawTime())))
+		case 12:
+			L.Push(lua.LNumber(ms(g.Perf.Upload)))
+		case 13:
+			L.Push(lua.LNumber(g.Perf.Calls))
+		default:
+			L.Push(lua.LNumber(0))
+		}
+		return 1
+	}))
+
+	// cartdata function - cartdata(id) opens the cartridge's save data,
+	// returns whether anything was saved under id before
+	g.LuaVM.SetField(dofiTable, "cartdata", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		id := L.CheckString(1)
+		if g.CartData != nil {
+			if g.CartData.ID != id {
+				L.RaiseError("cartdata already opened as %q", g.CartData.ID)
+			}
+			L.Push(lua.LBool(g.CartData.Loaded))
+			return 1
+		}
+		store, err := savedata.Open(g.saveBackend(), id)
+		if err != nil {
+			L.RaiseError("%v", err)
+		}
+		g.CartData = store
+		L.Push(lua.LBool(store.Loaded))
+		return 1
+	}))
+
+	// dget function - dget(i) reads slot i of the save data
+	g.LuaVM.SetField(dofiTable, "dget", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if g.CartData == nil {
+			L.RaiseError("dget called before cartdata")
+		}
+		L.Push(lua.LNumber(g.CartData.Get(luaInt(L, 1))))
+		return 1
+	}))
+
+	// dset function - dset(i, v) changes slot i, saved at the end of the frame
+	g.LuaVM.SetField(dofiTable, "dset", g.LuaVM.NewFunction(func(L *lua.LState) int {
+		if g.CartData == nil {
+			L.RaiseError("dset called before cartdata")
+		}
+		g.CartData.Set(luaInt(L, 1), float64(L.CheckNumber(2)))
+		return 0
+	}))
+}
+
+// saveBackend is where cartdata goes, memory only if the platform has
+// nowhere to keep it
+func (g *Game) saveBackend() savedata.Backend {
+	if g.SaveBackend == nil {
+		backend, err := savedata.DefaultBackend()
+		if err != nil {
+			log.Println("cartdata will not persist:", err)
+			backend = savedata.Memory{}
+		}
+		g.SaveBackend = backend
+	}
+	return g.SaveBackend
+}
+
+// FlushCartData writes save data that changed, now
+func (g *Game) FlushCartData() {
+	if g.CartData == nil {
+		return
+	}
+	failed := g.CartData.Failed
+	g.logCartData(failed, g.CartData.Flush())
+}
+
+// FlushCartDataDue writes save data that changed a while ago, a cart
+// changing it every frame is saved about once a second
+func (g *Game) FlushCartDataDue() {
+	if g.CartData == nil {
+		return
+	}
+	failed := g.CartData.Failed
+	g.logCartData(failed, g.CartData.FlushDue())
+}
+
+// logCartData logs a failed save, once until saving works again
+func (g *Game) logCartData(failed bool, err error) {
+	if err != nil && !failed {
+		log.Println("cartdata not saved:", err)
+	}
+}
@@ -3,27 +3,76 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
+	"image"
 	"image/color"
 	_ "image/png"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 
 	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/cart"
+	"github.com/mrdapoyo/dofi/clipboard"
+	"github.com/mrdapoyo/dofi/edit"
+	"github.com/mrdapoyo/dofi/fixed"
+	"github.com/mrdapoyo/dofi/gfx"
+	"github.com/mrdapoyo/dofi/input"
+	"github.com/mrdapoyo/dofi/loop"
+	"github.com/mrdapoyo/dofi/mem"
+	"github.com/mrdapoyo/dofi/replay"
+	"github.com/mrdapoyo/dofi/sandbox"
+	"github.com/mrdapoyo/dofi/savedata"
+	"github.com/mrdapoyo/dofi/syntax"
+	"github.com/mrdapoyo/dofi/synth"
 )
 
 type Game struct {
 	Screen        ScreenSpecs
 	LuaVM         *lua.LState
+	Sandbox       *sandbox.Sandbox
 	Navbar        Navbar
 	Input         Input
 	LinearBuffer  []LinearBuffer
 	ScriptRunning bool
+	Cart          *cart.Cart
+	DrawState     gfx.DrawState
+	DrawStack     []gfx.DrawState
+	Sprites       *gfx.SpriteSheet
+	SpriteEditor  SpriteEditor
+	Map           *gfx.TileMap
+	TileEditor    TileEditor
+	Sound         *synth.Bank
+	Synth         *synth.Synth
+	AudioPlayer   *audio.Player
+	MusicEditor   MusicEditor
+	Play          PlaySession
+	Crash         *CrashReport
+	Buttons       *input.Controller
+	buttonLatch   [input.Players]input.Mask
+	Devkit        Devkit
+	Loop          *loop.Timer
+	Perf          Perf
+	Memory        *mem.Memory     // backs the screen, sprites, map and sound
+	CartData      *savedata.Store // opened by cartdata(), nil until then
+	SaveBackend   savedata.Backend
+	Rand          *fixed.Rand // rnd and srand, reseeded for every run
+	Seed          fixed.Fix   // what Rand was seeded with when the run started
+	Replay        *ReplaySession
+	Theme         syntax.Theme // code editor colors
+	LineNumbers   bool         // code editor gutter
+	Clipboard     clipboard.Clipboard
+	paste         *pendingPaste
 }
 
 type ScreenSpecs = struct {
@@ -33,7 +82,8 @@ type ScreenSpecs = struct {
 	Font            string
 	FontSize        int
 	FontWidth       int
-	Buffer          [128][128]color.RGBA
+	Buffer          *gfx.Framebuffer
+	Palette         gfx.Palette
 	ImageBuffer     []*ebiten.Image
 	BgColor         color.RGBA
 	BgTextColor     color.RGBA
@@ -44,6 +94,7 @@ type ScreenSpecs = struct {
 type Navbar = struct {
 	Tabs         []Tab
 	CurrentTab   int
+	PreviousTab  int
 	CliEnabled   bool
 	NavbarColor  color.RGBA
 	TabColor     color.RGBA
@@ -76,11 +127,13 @@ type Input = struct {
 }
 
 type CodeEditor struct {
-	Content []string
-	Line    int
-	Column  int
-	ScrollY int
+	edit.Buffer
+	ScrollY int // first row on screen, wrapped lines take several
 	Saved   bool
+	Syntax  syntax.Highlighter
+
+	shown     edit.Pos // cursor the view last scrolled to
+	scrolling bool     // dragging the scrollbar
 }
 
 //go:embed donut.lua
@@ -117,6 +170,7 @@ var (
 	CodeEditorIndex   = 0
 	CursorBlinkFrames = 0
 	CursorBlinkRate   = 30
+	SampleRate        = 44100
 	LuaExamples       = map[string]string{
 		"donut": string(donutLua),
 		"print": `print("hello world")`,
@@ -133,7 +187,42 @@ func (g *Game) HandleCommand(command string) {
 		g.AppendLine("Available commands:", false)
 		g.AppendLine("help - Show this help message", false)
 		g.AppendLine("cls - Clear the screen", false)
-		g.AppendLine("dofi.pset(x,y,r,g,b) - Set pixel at (x, y) to color (r, g, b)", false)
+		g.AppendLine("dofi.pset(x,y,c) - Set pixel at (x, y) to palette color c", false)
+		g.AppendLine("dofi.line/rect/circ/oval/tri(...,c) - Draw shapes, add fill for solid ones", false)
+		g.AppendLine("dofi.fillp(p) - Set a 4x4 fill pattern for shapes", false)
+		g.AppendLine("dofi.spr(n,x,y,w,h,fx,fy) - Draw sprite n from the sheet", false)
+		g.AppendLine("dofi.fget(n,f) / dofi.fset(n,f,v) - Sprite flags", false)
+		g.AppendLine("dofi.map(cx,cy,sx,sy,cw,ch,layer) - Draw part of the tile map", false)
+		g.AppendLine("dofi.mget(x,y) / dofi.mset(x,y,n) - Read and write map cells", false)
+		g.AppendLine("dofi.camera(x,y) / dofi.clip(x,y,w,h) - Offset and limit drawing", false)
+		g.AppendLine("dofi.pal(c0,c1,p) - Draw c0 as c1 (p=1 remaps the display)", false)
+		g.AppendLine("dofi.palt(c,t) - Make color c transparent for sprites", false)
+		g.AppendLine("dofi.btn(i,p) / dofi.btnp(i,p) - Buttons 0-7 of player p", false)
+		g.AppendLine("dofi.devkit(on) - Let scripts use mouse(), key(k) and keyp(k)", false)
+		g.AppendLine("dofi.stat(n) - 0: memory kb, 1: cpu use, 7: actual fps, 8: target fps", false)
+		g.AppendLine("  10/11/12: update/draw/upload ms, 13: api calls per frame", false)
+		g.AppendLine("dofi.peek(a)/poke(a,v), peek2/poke2, peek4/poke4 - Read and write memory", false)
+		g.AppendLine("dofi.memcpy(dst,src,n)/memset(dst,v,n)/reload(dst,src,n) - Copy and fill memory", false)
+		g.AppendLine("  0x0000 sprites 0x4000 map 0x6000 flags 0x6100 sfx 0x7200 music", false)
+		g.AppendLine("  0x7300 draw state 0x7400 user ram 0xc000 screen", false)
+		g.AppendLine("dofi.cartdata(id) - Open save data, dget(i)/dset(i,v) use its 64 slots", false)
+		g.AppendLine("dofi.sfx(n,ch) - Play sfx n, -1 stops it", false)
+		g.AppendLine("dofi.music(n,fade) - Play music from pattern n, -1 stops it", false)
+		g.AppendLine("dofi.flr/ceil/abs/sgn/sqrt(x), sin/cos(turns), atan2(dx,dy) - Math", false)
+		g.AppendLine("dofi.rnd(x)/srand(x), band/bor/bxor(a,b), shl/shr(x,n) - Random and bits", false)
+		g.AppendLine("run - Run the code editor's cartridge, escape stops it", false)
+		g.AppendLine("ctrl+p while running - Toggle the performance overlay", false)
+		g.AppendLine("code tab: shift selects, ctrl+arrows move by word, tab/shift+tab indent", false)
+		g.AppendLine("  ctrl+z/ctrl+y undo and redo, kept per file while switching tabs", false)
+		g.AppendLine("  the wheel or the bar on the right scrolls", false)
+		g.AppendLine("ctrl+c/ctrl+x/ctrl+v - Copy, cut and paste in the code tab and the cli", false)
+		g.AppendLine("record <file> - Run the cartridge and record its buttons to <file>", false)
+		g.AppendLine("replay <file> - Run the cartridge with recorded buttons, checking every frame", false)
+		g.AppendLine("fixed on|off - Use 16.16 fixed point for the cartridge's math", false)
+		g.AppendLine("numbers on|off - Show line numbers in the code editor", false)
+		g.AppendLine("save <name> - Save the cartridge to <name>.dofi", false)
+		g.AppendLine("load <name> - Load the cartridge from <name>.dofi or a .png", false)
+		g.AppendLine("export <name>.png - Save the cartridge as a png image", false)
 		g.AppendLine(command, true)
 		return
 	}
@@ -144,6 +233,12 @@ func (g *Game) HandleCommand(command string) {
 			if err := g.RunLuaScript(exampleLua); err != nil {
 				g.AppendLine("Error running example: "+err.Error(), false)
 			} else {
+				g.Play = PlaySession{
+					ReturnTab: g.Navbar.CurrentTab,
+					ReturnCli: true,
+					Source:    strings.Split(exampleLua, "\n"),
+				}
+				g.StartLoop()
 				g.ScriptRunning = true
 				g.AppendLine("Running example: "+exampleName, false)
 			}
@@ -153,36 +248,163 @@ func (g *Game) HandleCommand(command string) {
 		g.AppendLine("", true)
 		return
 	}
+
+	if strings.HasPrefix(command, "record ") {
+		path := strings.TrimSpace(strings.TrimPrefix(command, "record "))
+		if err := g.RecordCart(path); err != nil {
+			g.FailCart("", err)
+		}
+		return
+	}
+
+	if strings.HasPrefix(command, "replay ") {
+		path := strings.TrimSpace(strings.TrimPrefix(command, "replay "))
+		recording, err := replay.Load(path)
+		if err != nil {
+			g.AppendLine("Error loading replay: "+err.Error(), false)
+			g.AppendLine("", true)
+			return
+		}
+		if err := g.ReplayCart(path, recording); err != nil {
+			var mismatch *ReplayMismatch
+			if !errors.As(err, &mismatch) {
+				g.FailCart("", err)
+				return
+			}
+			g.leaveCart()
+			g.AppendLine("Error replaying: "+err.Error(), false)
+			g.AppendLine("", true)
+		}
+		return
+	}
+
+	if command == "fixed" || strings.HasPrefix(command, "fixed ") {
+		switch strings.TrimSpace(strings.TrimPrefix(command, "fixed")) {
+		case "on":
+			g.Cart.Meta[FixedMeta] = "fixed"
+		case "off":
+			delete(g.Cart.Meta, FixedMeta)
+		}
+		if g.FixedCart() {
+			g.AppendLine("Fixed point math is on", false)
+		} else {
+			g.AppendLine("Fixed point math is off", false)
+		}
+		g.AppendLine("", true)
+		return
+	}
+
+	if command == "numbers" || strings.HasPrefix(command, "numbers ") {
+		switch strings.TrimSpace(strings.TrimPrefix(command, "numbers")) {
+		case "on":
+			g.LineNumbers = true
+		case "off":
+			g.LineNumbers = false
+		}
+		if g.LineNumbers {
+			g.AppendLine("Line numbers are on", false)
+		} else {
+			g.AppendLine("Line numbers are off", false)
+		}
+		g.AppendLine("", true)
+		return
+	}
+
+	if command == "run" {
+		if err := g.StartCart(g.Navbar.CurrentTab, true); err != nil {
+			g.FailCart("", err)
+		}
+		return
+	}
+
+	if strings.HasPrefix(command, "save ") {
+		name := strings.TrimSpace(strings.TrimPrefix(command, "save "))
+		if err := g.SaveCart(name); err != nil {
+			g.AppendLine("Error saving cartridge: "+err.Error(), false)
+		} else {
+			g.AppendLine("Saved "+cartPath(name), false)
+		}
+		g.AppendLine("", true)
+		return
+	}
+
+	if strings.HasPrefix(command, "export ") {
+		name := strings.TrimSpace(strings.TrimPrefix(command, "export "))
+		if path, err := g.ExportCart(name); err != nil {
+			g.AppendLine("Error exporting cartridge: "+err.Error(), false)
+		} else {
+			g.AppendLine("Exported "+path, false)
+		}
+		g.AppendLine("", true)
+		return
+	}
+
+	if strings.HasPrefix(command, "load ") {
+		name := strings.TrimSpace(strings.TrimPrefix(command, "load "))
+		if err := g.LoadCart(name); err != nil {
+			g.AppendLine("Error loading cartridge: "+err.Error(), false)
+		} else {
+			g.AppendLine("Loaded "+cartPath(name), false)
+		}
+		g.AppendLine("", true)
+		return
+	}
 }
 
 func (g *Game) Update() (err error) {
+	// last tick's edits and pokes to the sfx and music reach the audio
+	g.Synth.Sync()
+
+	if g.Crash != nil {
+		g.UpdateCrash()
+		return nil
+	}
+
 	if g.ScriptRunning {
 		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
-			g.ScriptRunning = false
-			g.AppendLine("Script stopped.", false)
-			g.AppendLine("", true)
+			g.StopCart()
 			return nil
 		}
-		if updateFn := g.LuaVM.GetGlobal("_update"); updateFn != lua.LNil {
-			if err := g.LuaVM.CallByParam(lua.P{
-				Fn:      updateFn,
-				NRet:    0,
-				Protect: true,
-			}); err != nil {
-				g.AppendLine("Lua error in _update: "+err.Error(), false)
-			}
+		ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+		if ctrl && inpututil.IsKeyJustPressed(ebiten.KeyP) {
+			g.Perf.Visible = !g.Perf.Visible
 		}
+		g.UpdatePlay()
 		return nil
 	}
 
 	if !g.Navbar.CliEnabled {
 		g.Input.MouseX, g.Input.MouseY = ebiten.CursorPosition()
 		g.Input.IsMouseDown = ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+
+		// clicking a navbar icon switches tabs
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && g.Input.MouseY < g.Navbar.NavbarHeight {
+			for i, tab := range g.Navbar.Tabs {
+				if tab.Enabled && image.Pt(g.Input.MouseX, g.Input.MouseY).In(g.TabBounds(i)) && i != g.Navbar.CurrentTab {
+					g.Navbar.PreviousTab = g.Navbar.CurrentTab
+					g.Navbar.CurrentTab = i
+				}
+			}
+		}
+
+		if tab := g.Navbar.Tabs[g.Navbar.CurrentTab]; tab.Function != nil {
+			if err := tab.Function(g); err != nil {
+				g.AppendLine("Error in "+tab.Name+" tab: "+err.Error(), false)
+			}
+		}
 	}
 
+	g.UpdatePaste()
+
 	var inputChars []rune
 	inputChars = ebiten.AppendInputChars(inputChars[:0])
 
+	// browsers report ctrl+v as a typed v
+	if ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta); ctrl && g.Navbar.CliEnabled {
+		g.UpdateCliClipboard()
+		inputChars = nil
+	}
+
 	for _, r := range inputChars {
 		switch r {
 		case '\r', '\n':
@@ -193,16 +415,6 @@ func (g *Game) Update() (err error) {
 		default:
 			if g.Navbar.CliEnabled {
 				g.Input.CurrentInputString += string(r)
-			} else {
-				if editor, exists := CodeEditors[CodeEditorIndex]; exists {
-					if editor.Line < len(editor.Content) {
-						line := editor.Content[editor.Line]
-						if editor.Column <= len(line) {
-							editor.Content[editor.Line] = line[:editor.Column] + string(r) + line[editor.Column:]
-							editor.Column++
-						}
-					}
-				}
 			}
 		}
 	}
@@ -213,12 +425,6 @@ func (g *Game) Update() (err error) {
 			g.Input.Keys = []ebiten.Key{}
 			g.HandleCommand(g.Input.CurrentInputString)
 			g.Input.CurrentInputString = ""
-		} else {
-			if editor, exists := CodeEditors[CodeEditorIndex]; exists {
-				editor.Content = append(editor.Content, "")
-				editor.Line++
-				editor.Column = 0
-			}
 		}
 	}
 
@@ -228,23 +434,13 @@ func (g *Game) Update() (err error) {
 			if len(g.Input.CurrentInputString) > 0 {
 				g.Input.CurrentInputString = g.Input.CurrentInputString[:len(g.Input.CurrentInputString)-1]
 			}
-		} else {
-			if editor, exists := CodeEditors[CodeEditorIndex]; exists {
-				if editor.Line < len(editor.Content) && editor.Column > 0 {
-					line := editor.Content[editor.Line]
-					editor.Content[editor.Line] = line[:editor.Column-1] + line[editor.Column:]
-					editor.Column--
-				} else if editor.Line > 0 {
-					prevLine := editor.Content[editor.Line-1]
-					editor.Column = len(prevLine)
-					if editor.Line < len(editor.Content)-1 {
-						editor.Content = append(editor.Content[:editor.Line], editor.Content[editor.Line+1:]...)
-					} else {
-						editor.Content = editor.Content[:editor.Line]
-					}
-					editor.Line--
-				}
-			}
+		}
+	}
+
+	if !g.Navbar.CliEnabled && g.CurrentTabName() == "code" {
+		if editor, exists := CodeEditors[CodeEditorIndex]; exists {
+			g.UpdateCodeEditor(editor, inputChars)
+			g.ScrollCodeEditor(editor)
 		}
 	}
 
@@ -258,32 +454,6 @@ func (g *Game) Update() (err error) {
 		g.ModifyLine(len(g.LinearBuffer)-1, g.Input.CurrentInputString)
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
-		if !g.Navbar.CliEnabled {
-			if editor, exists := CodeEditors[CodeEditorIndex]; exists {
-				if editor.Column > 0 {
-					editor.Column--
-				} else if editor.Line > 0 {
-					editor.Line--
-					editor.Column = len(editor.Content[editor.Line])
-				}
-			}
-		}
-	}
-
-	if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
-		if !g.Navbar.CliEnabled {
-			if editor, exists := CodeEditors[CodeEditorIndex]; exists {
-				if editor.Column < len(editor.Content[editor.Line]) {
-					editor.Column++
-				} else if editor.Line < len(editor.Content)-1 {
-					editor.Line++
-					editor.Column = 0
-				}
-			}
-		}
-	}
-
 	CursorBlinkFrames++
 	if CursorBlinkFrames > 60 {
 		CursorBlinkFrames = 0
@@ -302,22 +472,25 @@ func (g *Game) Update() (err error) {
 func (g *Game) Draw(screen *ebiten.Image) {
 	screen.Clear()
 
-	bufferImg := ebiten.NewImage(len(g.Screen.Buffer[0]), len(g.Screen.Buffer))
-
-	pixels := make([]byte, len(g.Screen.Buffer)*len(g.Screen.Buffer[0])*4)
-	for y := 0; y < len(g.Screen.Buffer); y++ {
-		for x := 0; x < len(g.Screen.Buffer[y]); x++ {
-			pixel := g.Screen.Buffer[y][x]
-			idx := (y*len(g.Screen.Buffer[0]) + x) * 4
-			pixels[idx] = pixel.R
-			pixels[idx+1] = pixel.G
-			pixels[idx+2] = pixel.B
-			pixels[idx+3] = pixel.A
-		}
+	if g.ScriptRunning {
+		g.DrawPlay()
 	}
 
+	if g.Crash != nil {
+		g.DrawCrash(screen)
+		return
+	}
+
+	uploadStart := time.Now()
+	bufferImg := ebiten.NewImage(g.Screen.Buffer.Width, g.Screen.Buffer.Height)
+
+	// the framebuffer only holds palette indices, colors happen here
+	pixels := make([]byte, g.Screen.Buffer.Width*g.Screen.Buffer.Height*4)
+	g.Screen.Buffer.RGBA(pixels, &g.Screen.Palette, &g.DrawState.DisplayPal)
+
 	bufferImg.WritePixels(pixels)
 	screen.DrawImage(bufferImg, &ebiten.DrawImageOptions{})
+	g.Perf.Upload = time.Since(uploadStart)
 
 	if !g.ScriptRunning {
 		if g.Navbar.CliEnabled {
@@ -355,26 +528,14 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			navbarImg.Fill(g.Navbar.NavbarColor)
 			screen.DrawImage(navbarImg, nil)
 
-			totalTabWidth := 0
-			enabledTabs := 0
-			for _, tab := range g.Navbar.Tabs {
-				if tab.Enabled {
-					enabledTabs++
-					totalTabWidth += tab.Icon.Bounds().Dx() + 2
-				}
-			}
-			if enabledTabs > 0 {
-				totalTabWidth += (enabledTabs - 1) * 2 // spacing between tabs
-			}
-
-			xPosition := g.Screen.Width - totalTabWidth - 1
-			for _, tab := range g.Navbar.Tabs {
+			for i, tab := range g.Navbar.Tabs {
 				if !tab.Enabled {
 					continue
 				}
 
-				iconWidth := tab.Icon.Bounds().Dx() + 2
-				iconHeight := tab.Icon.Bounds().Dy() + 2
+				bounds := g.TabBounds(i)
+				iconWidth := bounds.Dx()
+				iconHeight := bounds.Dy()
 
 				tabImg := ebiten.NewImage(iconWidth, iconHeight)
 				tabImg.Fill(g.Navbar.TabColor)
@@ -384,28 +545,29 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				tabImg.DrawImage(tab.Icon, iconOP)
 
 				tabOp := &ebiten.DrawImageOptions{}
-				tabOp.GeoM.Translate(float64(xPosition), float64((navbarHeight-iconHeight)/2))
+				tabOp.GeoM.Translate(float64(bounds.Min.X), float64(bounds.Min.Y))
+				if i != g.Navbar.CurrentTab {
+					tabOp.ColorScale.Scale(0.7, 0.7, 0.7, 1)
+				}
 				screen.DrawImage(tabImg, tabOp)
-
-				xPosition += iconWidth + 2
 			}
 
 			var contentImage = ebiten.NewImage(g.Screen.Width, g.Screen.Height-navbarHeight)
 			contentImage.Fill(g.Screen.BgColor)
 
-			if g.Navbar.Tabs[g.Navbar.CurrentTab].Name == "code" {
+			if g.CurrentTabName() == "code" {
 				if editor, exists := CodeEditors[g.Navbar.CurrentTab]; exists {
 					g.CodeEditor(contentImage, editor, navbarHeight)
 				} else {
-					CodeEditors[g.Navbar.CurrentTab] = &CodeEditor{
-						Content: []string{""},
-						Line:    0,
-						Column:  0,
-						ScrollY: 0,
-						Saved:   false,
-					}
+					CodeEditors[g.Navbar.CurrentTab] = NewCodeEditor(nil)
 					g.CodeEditor(screen, CodeEditors[g.Navbar.CurrentTab], navbarHeight)
 				}
+			} else if g.CurrentTabName() == "draw" {
+				g.DrawSpriteEditor(contentImage)
+			} else if g.CurrentTabName() == "tile" {
+				g.DrawTileEditor(contentImage)
+			} else if g.CurrentTabName() == "music" {
+				g.DrawMusicEditor(contentImage)
 			}
 			var contentImageOp = &ebiten.DrawImageOptions{}
 			contentImageOp.GeoM.Translate(0, float64(navbarHeight))
@@ -414,18 +576,57 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			g.DrawMouse(screen)
 		}
 	} else {
-		if drawFn := g.LuaVM.GetGlobal("_draw"); drawFn != lua.LNil {
-			if err := g.LuaVM.CallByParam(lua.P{
-				Fn:      drawFn,
-				NRet:    0,
-				Protect: true,
-			}); err != nil {
-				log.Println("Lua error in _draw:", err)
-				g.AppendLine("Lua error in _draw: "+err.Error(), false)
-			}
-			screen.DrawImage(bufferImg, nil)
+		if g.Devkit.Enabled {
+			g.DrawMouse(screen)
+		}
+		if g.Perf.Visible {
+			g.DrawPerf(screen)
+		}
+	}
+}
+
+// TabBounds is where tab i sits in the navbar, tabs are right aligned
+func (g *Game) TabBounds(i int) image.Rectangle {
+	totalTabWidth := 0
+	enabledTabs := 0
+	for _, tab := range g.Navbar.Tabs {
+		if tab.Enabled {
+			enabledTabs++
+			totalTabWidth += tab.Icon.Bounds().Dx() + 2
 		}
 	}
+	if enabledTabs > 0 {
+		totalTabWidth += (enabledTabs - 1) * 2 // spacing between tabs
+	}
+
+	xPosition := g.Screen.Width - totalTabWidth - 1
+	for j, tab := range g.Navbar.Tabs {
+		if !tab.Enabled {
+			continue
+		}
+		iconWidth := tab.Icon.Bounds().Dx() + 2
+		iconHeight := tab.Icon.Bounds().Dy() + 2
+		if j == i {
+			y := (g.Navbar.NavbarHeight - iconHeight) / 2
+			return image.Rect(xPosition, y, xPosition+iconWidth, y+iconHeight)
+		}
+		xPosition += iconWidth + 2
+	}
+	return image.Rectangle{}
+}
+
+// TabIndex finds a tab by name, -1 if there's none
+func (g *Game) TabIndex(name string) int {
+	for i, tab := range g.Navbar.Tabs {
+		if tab.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (g *Game) CurrentTabName() string {
+	return g.Navbar.Tabs[g.Navbar.CurrentTab].Name
 }
 
 func (g *Game) DrawMouse(screen *ebiten.Image) {
@@ -441,65 +642,80 @@ func (g *Game) CodeEditor(screen *ebiten.Image, editor *CodeEditor, navbarHeight
 	screen.Clear()
 	screen.Fill(g.Screen.CliBgColor)
 
+	editor.Clamp()
 	lineHeight := g.Screen.FontSize + 2
-	availableHeight := g.Screen.Height - navbarHeight
-	maxVisibleLines := availableHeight / lineHeight
-
-	startLine := 0
-	if len(g.LinearBuffer) > maxVisibleLines {
-		startLine = len(g.LinearBuffer) - maxVisibleLines
-	}
-
-	var y = 0
-
-	for i := startLine; i < len(CodeEditors[CodeEditorIndex].Content) && i < startLine+maxVisibleLines; i++ {
-		line := CodeEditors[CodeEditorIndex].Content[i]
-		wrappedLines := g.wrapText(line, g.Screen.Width)
-		for _, wrappedLine := range wrappedLines {
-			img := ebiten.NewImage(g.Screen.Width, lineHeight)
+	layout := g.codeLayout(editor, navbarHeight)
+	advance := g.Screen.FontWidth + 1
+
+	editor.Syntax.Update(editor.Content)
+	selStart, selEnd, selecting := editor.Selection()
+
+	// the first line with a row on screen, y can start above the screen
+	first := sort.Search(len(editor.Content), func(i int) bool { return layout.starts[i+1] > editor.ScrollY })
+	y := layout.starts[min(first, len(editor.Content))] - editor.ScrollY
+
+	for i := first; i < len(editor.Content) && y < layout.rows; i++ {
+		line := editor.Content[i]
+		wrappedLines := g.wrapText(line, layout.width)
+		offset := 0
+		for j, wrappedLine := range wrappedLines {
+			if y < 0 {
+				offset += len(wrappedLine)
+				y++
+				continue
+			}
+			img := ebiten.NewImage(layout.width, lineHeight)
 
 			if i%2 == 0 {
 				img.Fill(color.RGBA{g.Screen.CliBgColor.R - 10, g.Screen.CliBgColor.G - 10, g.Screen.CliBgColor.B - 10, g.Screen.CliBgColor.A})
 			} else {
 				img.Fill(color.RGBA{g.Screen.CliBgColor.R + 20, g.Screen.CliBgColor.G + 20, g.Screen.CliBgColor.B + 20, g.Screen.CliBgColor.A})
 			}
-			textOP := &text.DrawOptions{}
-			textOP.GeoM.Translate(0, 1)
-			textOP.ColorScale.ScaleWithColor(g.Screen.CliColor)
-			text.Draw(img, wrappedLine, TextFace, textOP)
+			if selecting && i >= selStart.Line && i <= selEnd.Line {
+				from, to := offset, offset+len(wrappedLine)
+				if to == len(line) && i < selEnd.Line {
+					// the selected newline, one cell past the end
+					to++
+				}
+				if i == selStart.Line {
+					from = max(from, selStart.Column)
+				}
+				if i == selEnd.Line {
+					to = min(to, selEnd.Column)
+				}
+				if from < to {
+					fillRect(img, image.Rect((from-offset)*advance, 0, (to-offset)*advance, lineHeight), g.Screen.Palette[1])
+				}
+			}
+			g.drawHighlighted(img, line, offset, offset+len(wrappedLine), editor.Syntax.Tokens(i))
+			offset += len(wrappedLine)
 
 			screenOP := &ebiten.DrawImageOptions{}
-			screenOP.GeoM.Translate(0, float64(y*lineHeight))
+			screenOP.GeoM.Translate(float64(layout.gutter), float64(y*lineHeight))
 			screen.DrawImage(img, screenOP)
+
+			// wrapped rows leave the gutter empty, so a number is always a new line
+			if layout.gutter > 0 && j == 0 {
+				number := strconv.Itoa(i + 1)
+				numberColor := g.Screen.Palette[5]
+				if i == editor.Line {
+					numberColor = g.Screen.Palette[7]
+				}
+				drawLabel(screen, layout.gutter-2-len(number)*advance, y*lineHeight+1, number, numberColor)
+			}
 			y++
 		}
 	}
 
-	cursorVisualLine := 0
-	cursorVisualColumn := editor.Column
-
-	for i := startLine; i < editor.Line && i < len(editor.Content); i++ {
-		wrappedLines := g.wrapText(editor.Content[i], g.Screen.Width)
-		cursorVisualLine += len(wrappedLines)
-	}
-
-	if editor.Line < len(editor.Content) {
-		currentLineWrapped := g.wrapText(editor.Content[editor.Line], g.Screen.Width)
-		charsProcessed := 0
-		for wrapIndex, wrappedSegment := range currentLineWrapped {
-			if editor.Column <= charsProcessed+len(wrappedSegment) {
-				cursorVisualLine += wrapIndex
-				cursorVisualColumn = editor.Column - charsProcessed
-				break
-			}
-			charsProcessed += len(wrappedSegment)
-		}
+	if bar := layout.scrollbar(editor.ScrollY, screen.Bounds()); !bar.Empty() {
+		fillRect(screen, bar, g.Screen.Palette[6])
 	}
 
 	// only draw the cursor if it's on the screen
-	if cursorVisualLine < maxVisibleLines && cursorVisualLine >= 0 {
-
-		cursorX := cursorVisualColumn * (g.Screen.FontWidth + 1)
+	cursorVisualLine := g.cursorRow(editor, layout) - editor.ScrollY
+	if cursorVisualLine < layout.rows && cursorVisualLine >= 0 {
+		_, cursorVisualColumn := g.wrappedColumn(editor.Content[editor.Line], editor.Column, layout.width)
+		cursorX := layout.gutter + cursorVisualColumn*advance
 		if cursorX <= g.Screen.Width {
 			cursorImg := ebiten.NewImage(1, lineHeight-2)
 			cursorImg.Fill(color.White)
@@ -522,6 +738,8 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeigh
 }
 
 func MakeGame() *Game {
+	memory := mem.New()
+
 	var screen = ScreenSpecs{
 		Width:           128,
 		Height:          128,
@@ -529,6 +747,8 @@ func MakeGame() *Game {
 		Font:            "resources/cg-pixel-4x5-mono.otf",
 		FontSize:        5,
 		FontWidth:       4,
+		Buffer:          memory.Screen(),
+		Palette:         gfx.DefaultPalette,
 		BgColor:         color.RGBA{255, 169, 133, 255},
 		CliBgColor:      color.RGBA{70, 82, 113, 255},
 		CliColor:        color.RGBA{255, 255, 255, 255},
@@ -538,10 +758,10 @@ func MakeGame() *Game {
 	var navbar = Navbar{
 		Tabs: []Tab{
 			{Name: "code", Enabled: true, IconPath: "resources/icons/code.png"},
-			{Name: "draw", Enabled: true, IconPath: "resources/icons/brush.png"},
-			{Name: "tile", Enabled: true, IconPath: "resources/icons/tile.png"},
-			{Name: "play", Enabled: true, IconPath: "resources/icons/play.png"},
-			{Name: "music", Enabled: true, IconPath: "resources/icons/music.png"},
+			{Name: "draw", Enabled: true, IconPath: "resources/icons/brush.png", Function: (*Game).UpdateSpriteEditor},
+			{Name: "tile", Enabled: true, IconPath: "resources/icons/tile.png", Function: (*Game).UpdateTileEditor},
+			{Name: "play", Enabled: true, IconPath: "resources/icons/play.png", Function: (*Game).UpdatePlayTab},
+			{Name: "music", Enabled: true, IconPath: "resources/icons/music.png", Function: (*Game).UpdateMusicEditor},
 		},
 		CurrentTab:   0,
 		NavbarColor:  color.RGBA{204, 116, 83, 255},
@@ -570,9 +790,21 @@ func MakeGame() *Game {
 	}
 
 	var game = Game{
-		Navbar: navbar,
-		Screen: screen,
-		LuaVM:  lua.NewState(),
+		Navbar:       navbar,
+		Screen:       screen,
+		Cart:         cart.New(),
+		DrawState:    gfx.NewDrawState(),
+		Sprites:      memory.SpriteSheet(),
+		SpriteEditor: NewSpriteEditor(),
+		Map:          memory.TileMap(),
+		TileEditor:   NewTileEditor(),
+		Sound:        memory.Bank(),
+		Memory:       memory,
+		Rand:         fixed.NewRand(0),
+		Theme:        syntax.DefaultTheme,
+		Buttons:      input.NewController(),
+		Loop:         loop.New(30),
+		MusicEditor:  NewMusicEditor(),
 		Input: Input{
 			CurrentInputString: "",
 			MouseX:             0,
@@ -594,9 +826,20 @@ func MakeGame() *Game {
 		log.Fatal("Error loading mouse shadow:", err)
 	}
 
+	game.Memory.State = &game.DrawState
+
+	game.Synth = synth.New(game.Sound, SampleRate)
+	game.AudioPlayer, err = audio.NewContext(SampleRate).NewPlayer(game.Synth)
+	if err != nil {
+		log.Fatal("Error starting audio:", err)
+	}
+	// the synth never runs out, keep the buffer small so sounds start quickly
+	game.AudioPlayer.SetBufferSize(50 * time.Millisecond)
+	game.AudioPlayer.Play()
+
 	game.Input.Mouse = mouse
 	game.Input.MouseShadow = mouseShadow
-	game.setupLuaAPI()
+	game.ResetLuaVM()
 	game.AppendLine("", true)
 
 	TextFaceSource, err = text.NewGoTextFaceSource(bytes.NewReader(fontBytes))
@@ -613,12 +856,16 @@ func MakeGame() *Game {
 
 func main() {
 	game := MakeGame()
-	defer game.LuaVM.Close()
+	// the vm is replaced on every run, close whichever one is current
+	defer func() { game.Sandbox.Close() }()
 
 	ebiten.SetWindowSize(game.Screen.Width*game.Screen.UpscalingFactor, game.Screen.Height*game.Screen.UpscalingFactor)
 	ebiten.SetWindowTitle("Dofi! :3")
-	ebiten.SetTPS(60)
-	if err := ebiten.RunGame(game); err != nil {
+	ebiten.SetTPS(loop.TickRate)
+	err := ebiten.RunGame(game)
+	// closing the window can beat a cartdata change that's still waiting
+	game.FlushCartData()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
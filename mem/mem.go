@@ -0,0 +1,187 @@
+package mem
+
+import (
+	"fmt"
+
+	"github.com/mrdapoyo/dofi/gfx"
+	"github.com/mrdapoyo/dofi/synth"
+)
+
+// the dofi address space is 64KB. graphics use one byte per pixel, so the
+// sprite sheet and the screen take 16KB each:
+//
+//	0x0000-0x3fff  sprite sheet, 128x128
+//	0x4000-0x5fff  map, 128x64 sprite indices
+//	0x6000-0x60ff  sprite flags
+//	0x6100-0x71ff  sfx, 64 of 68 bytes
+//	0x7200-0x72ff  music, 64 patterns of 4 bytes
+//	0x7300-0x73ff  draw state, see gfx.DrawState.Encode
+//	0x7400-0xbfff  user ram
+//	0xc000-0xffff  screen, 128x128
+//
+// everything below the draw state is cartridge data, reload copies it back
+// from the cartridge. 16 and 32 bit values are little endian.
+
+const (
+	Sprites = 0x0000
+	Map     = Sprites + gfx.SheetWidth*gfx.SheetHeight
+	Flags   = Map + gfx.MapWidth*gfx.MapHeight
+	SFX     = Flags + gfx.SpriteCount
+	Music   = SFX + synth.SFXCount*synth.SFXSize
+	State   = Music + synth.PatternCount*synth.PatternSize
+	User    = State + 0x100
+	Screen  = 0xc000
+	Size    = 0x10000
+
+	CartSize = State // the part of memory a cartridge stores
+)
+
+// Memory is the byte array every asset lives in. the sprite sheet, map,
+// sound bank and screen handed out by Memory are views into RAM, so pokes
+// show up in them right away.
+type Memory struct {
+	RAM   []byte
+	ROM   []byte         // cartridge data as the run started, for reload
+	State *gfx.DrawState // kept in sync with the draw state bytes
+}
+
+func New() *Memory {
+	return &Memory{
+		RAM: make([]byte, Size),
+		ROM: make([]byte, CartSize),
+	}
+}
+
+func (m *Memory) Screen() *gfx.Framebuffer {
+	return &gfx.Framebuffer{
+		Width:  gfx.ScreenWidth,
+		Height: gfx.ScreenHeight,
+		Pix:    m.RAM[Screen:Size],
+	}
+}
+
+func (m *Memory) SpriteSheet() *gfx.SpriteSheet {
+	return &gfx.SpriteSheet{
+		Pixels: &gfx.Framebuffer{Width: gfx.SheetWidth, Height: gfx.SheetHeight, Pix: m.RAM[Sprites:Map]},
+		Flags:  m.RAM[Flags:SFX],
+	}
+}
+
+func (m *Memory) TileMap() *gfx.TileMap {
+	return &gfx.TileMap{Width: gfx.MapWidth, Height: gfx.MapHeight, Cells: m.RAM[Map:Flags]}
+}
+
+func (m *Memory) Bank() *synth.Bank {
+	return &synth.Bank{SFX: m.RAM[SFX:Music], Music: m.RAM[Music:State]}
+}
+
+// SaveROM remembers the cartridge data in RAM as what reload goes back to
+func (m *Memory) SaveROM() {
+	copy(m.ROM, m.RAM[:CartSize])
+}
+
+// the draw state lives in a go struct, its bytes are written out before
+// they're read and read back after they're written
+func touchesState(addr, n int) bool {
+	return addr < State+gfx.StateBytes && addr+n > State
+}
+
+func (m *Memory) loadState(addr, n int) {
+	if m.State != nil && touchesState(addr, n) {
+		m.State.Encode(m.RAM[State:])
+	}
+}
+
+func (m *Memory) storeState(addr, n int) {
+	if m.State != nil && touchesState(addr, n) {
+		m.State.Decode(m.RAM[State:])
+	}
+}
+
+func inRange(addr, n int) bool {
+	return addr >= 0 && n >= 0 && addr+n <= Size
+}
+
+// Peek is the byte at addr, 0 outside of memory
+func (m *Memory) Peek(addr int) uint8 {
+	if !inRange(addr, 1) {
+		return 0
+	}
+	m.loadState(addr, 1)
+	return m.RAM[addr]
+}
+
+// Peek2 is the signed 16 bit value at addr
+func (m *Memory) Peek2(addr int) int16 {
+	return int16(uint16(m.Peek(addr)) | uint16(m.Peek(addr+1))<<8)
+}
+
+// Peek4 is the signed 32 bit value at addr
+func (m *Memory) Peek4(addr int) int32 {
+	return int32(uint32(uint16(m.Peek2(addr))) | uint32(uint16(m.Peek2(addr+2)))<<16)
+}
+
+// Poke sets the byte at addr, writes outside of memory are dropped
+func (m *Memory) Poke(addr int, v uint8) {
+	if !inRange(addr, 1) {
+		return
+	}
+	m.loadState(addr, 1)
+	m.RAM[addr] = v
+	m.storeState(addr, 1)
+}
+
+func (m *Memory) Poke2(addr int, v int16) {
+	m.Poke(addr, uint8(v))
+	m.Poke(addr+1, uint8(uint16(v)>>8))
+}
+
+func (m *Memory) Poke4(addr int, v int32) {
+	m.Poke2(addr, int16(v))
+	m.Poke2(addr+2, int16(uint32(v)>>16))
+}
+
+// Memcpy copies n bytes from src to dst, the ranges may overlap
+func (m *Memory) Memcpy(dst, src, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if !inRange(dst, n) || !inRange(src, n) {
+		return fmt.Errorf("memcpy out of range: 0x%x <- 0x%x, %d bytes", dst, src, n)
+	}
+	m.loadState(src, n)
+	m.loadState(dst, n)
+	copy(m.RAM[dst:dst+n], m.RAM[src:src+n])
+	m.storeState(dst, n)
+	return nil
+}
+
+// Memset fills n bytes from dst with v
+func (m *Memory) Memset(dst int, v uint8, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if !inRange(dst, n) {
+		return fmt.Errorf("memset out of range: 0x%x, %d bytes", dst, n)
+	}
+	m.loadState(dst, n)
+	for i := dst; i < dst+n; i++ {
+		m.RAM[i] = v
+	}
+	m.storeState(dst, n)
+	return nil
+}
+
+// Reload copies n bytes of cartridge data from src in ROM to dst in RAM
+func (m *Memory) Reload(dst, src, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if !inRange(dst, n) || src < 0 || src+n > CartSize {
+		return fmt.Errorf("reload out of range: 0x%x <- 0x%x, %d bytes", dst, src, n)
+	}
+	m.loadState(dst, n)
+	copy(m.RAM[dst:dst+n], m.ROM[src:src+n])
+	m.storeState(dst, n)
+	return nil
+}
@@ -0,0 +1,191 @@
+package mem
+
+import (
+	"testing"
+
+	"github.com/mrdapoyo/dofi/gfx"
+	"github.com/mrdapoyo/dofi/synth"
+)
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      int
+		expected int
+	}{
+		{"map", Map, 0x4000},
+		{"flags", Flags, 0x6000},
+		{"sfx", SFX, 0x6100},
+		{"music", Music, 0x7200},
+		{"state", State, 0x7300},
+		{"user", User, 0x7400},
+		{"screen", Screen + gfx.ScreenWidth*gfx.ScreenHeight, Size},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Fatalf("tests[%d] - %s address wrong. expected=0x%x, got=0x%x", i, tt.name, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestViews(t *testing.T) {
+	m := New()
+	screen := m.Screen()
+	sheet := m.SpriteSheet()
+	tiles := m.TileMap()
+	bank := m.Bank()
+
+	m.Poke(Screen+3*gfx.ScreenWidth+2, 9)
+	if got := screen.Get(2, 3); got != 9 {
+		t.Fatalf("screen pixel wrong. expected=9, got=%d", got)
+	}
+	sheet.Pixels.Set(1, 0, 12)
+	if got := m.Peek(Sprites + 1); got != 12 {
+		t.Fatalf("sprite pixel wrong. expected=12, got=%d", got)
+	}
+	sheet.SetFlag(2, 0, true)
+	if got := m.Peek(Flags + 2); got != 1 {
+		t.Fatalf("sprite flags wrong. expected=1, got=%d", got)
+	}
+	m.Poke(Map+gfx.MapWidth+5, 33)
+	if got := tiles.Get(5, 1); got != 33 {
+		t.Fatalf("map cell wrong. expected=33, got=%d", got)
+	}
+	bank.SetSpeed(1, 20)
+	if got := m.Peek(SFX + synth.SFXSize + synth.NoteCount*2); got != 20 {
+		t.Fatalf("sfx speed wrong. expected=20, got=%d", got)
+	}
+}
+
+func TestPeekPoke(t *testing.T) {
+	m := New()
+	m.Poke4(User, -2)
+	m.Poke2(User+4, 0x1234)
+
+	tests := []struct {
+		name     string
+		got      int
+		expected int
+	}{
+		{"peek", int(m.Peek(User)), 0xfe},
+		{"peek2", int(m.Peek2(User)), -2},
+		{"peek4", int(m.Peek4(User)), -2},
+		{"low byte", int(m.Peek(User + 4)), 0x34},
+		{"high byte", int(m.Peek(User + 5)), 0x12},
+		{"below memory", int(m.Peek(-1)), 0},
+		{"above memory", int(m.Peek(Size)), 0},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Fatalf("tests[%d] - %s wrong. expected=%d, got=%d", i, tt.name, tt.expected, tt.got)
+		}
+	}
+	m.Poke(Size, 1) // dropped
+}
+
+func TestMemcpyMemset(t *testing.T) {
+	m := New()
+	for i := 0; i < 8; i++ {
+		m.Poke(User+i, uint8(i+1))
+	}
+	// overlapping copy to the right
+	if err := m.Memcpy(User+2, User, 6); err != nil {
+		t.Fatalf("memcpy failed: %v", err)
+	}
+	expected := []uint8{1, 2, 1, 2, 3, 4, 5, 6}
+	for i, e := range expected {
+		if got := m.Peek(User + i); got != e {
+			t.Fatalf("memcpy byte %d wrong. expected=%d, got=%d", i, e, got)
+		}
+	}
+
+	if err := m.Memset(Screen, 7, gfx.ScreenWidth); err != nil {
+		t.Fatalf("memset failed: %v", err)
+	}
+	if got := m.Screen().Get(gfx.ScreenWidth-1, 0); got != 7 {
+		t.Fatalf("memset wrong. expected=7, got=%d", got)
+	}
+
+	if err := m.Memcpy(Size-1, 0, 2); err == nil {
+		t.Fatalf("expected an error copying past the end")
+	}
+	if err := m.Memset(-1, 0, 2); err == nil {
+		t.Fatalf("expected an error filling before the start")
+	}
+}
+
+func TestDrawState(t *testing.T) {
+	m := New()
+	state := gfx.NewDrawState()
+	m.State = &state
+
+	state.Color = 11
+	if got := m.Peek(State + 0x22); got != 11 {
+		t.Fatalf("pen color wrong. expected=11, got=%d", got)
+	}
+	m.Poke(State+3, 8) // draw palette, 3 -> 8
+	if state.DrawPal[3] != 8 {
+		t.Fatalf("draw palette wrong. expected=8, got=%d", state.DrawPal[3])
+	}
+	m.Poke2(State+0x26, -16) // camera x
+	if state.CameraX != -16 {
+		t.Fatalf("camera wrong. expected=-16, got=%d", state.CameraX)
+	}
+	// user ram is plain bytes, nothing else changes
+	m.Poke(User, 5)
+	if state.CameraX != -16 || state.Color != 11 {
+		t.Fatalf("draw state changed by a user ram poke")
+	}
+}
+
+func TestPokedColorsAreMasked(t *testing.T) {
+	m := New()
+	state := gfx.NewDrawState()
+	m.State = &state
+	screen := m.Screen()
+	c := gfx.Canvas{FB: screen, State: &state}
+
+	// only the low 4 bits of a pixel byte are its color
+	m.Poke(Sprites, 200)
+	m.Poke(Sprites+1, 0xff)
+	m.Poke(Screen+5, 0x30)
+	c.Spr(m.SpriteSheet(), 0, 0, 0, 1, 1, false, false)
+
+	tests := []struct {
+		x, y     int
+		expected uint8
+	}{
+		{0, 0, 200 & 15},
+		{1, 0, 15},
+		{5, 0, 0},
+	}
+	for i, tt := range tests {
+		if got := screen.Get(tt.x, tt.y); got != tt.expected {
+			t.Fatalf("tests[%d] - color at (%d,%d) wrong. expected=%d, got=%d", i, tt.x, tt.y, tt.expected, got)
+		}
+	}
+	pixels := make([]byte, len(screen.Pix)*4)
+	screen.RGBA(pixels, &gfx.DefaultPalette, &state.DisplayPal)
+}
+
+func TestReload(t *testing.T) {
+	m := New()
+	m.Poke(Sprites, 3)
+	m.Poke(Map, 4)
+	m.SaveROM()
+
+	m.Memset(0, 0, CartSize)
+	if err := m.Reload(Map, Map, 1); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if m.Peek(Map) != 4 || m.Peek(Sprites) != 0 {
+		t.Fatalf("partial reload wrong. got=%d, %d", m.Peek(Map), m.Peek(Sprites))
+	}
+	// cartridge data can be loaded anywhere, like user ram
+	if err := m.Reload(User, Sprites, 1); err != nil || m.Peek(User) != 3 {
+		t.Fatalf("reload into user ram wrong. err=%v, got=%d", err, m.Peek(User))
+	}
+	if err := m.Reload(0, User, 1); err == nil {
+		t.Fatalf("expected an error reloading past the cartridge data")
+	}
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/mrdapoyo/dofi/synth"
+)
+
+type MusicMode int
+
+const (
+	MusicSFX MusicMode = iota
+	MusicPattern
+)
+
+const MaxMusicUndo = 64
+
+// layout of the music tab, in content coordinates
+var (
+	musicModeRect  = image.Rect(2, 1, 22, 9)
+	musicIndexRect = image.Rect(26, 1, 44, 9)
+
+	// sfx mode
+	musicSpeedRect     = image.Rect(48, 1, 74, 9)
+	musicLoopStartRect = image.Rect(78, 1, 96, 9)
+	musicLoopEndRect   = image.Rect(100, 1, 118, 9)
+	musicPitchRect     = image.Rect(0, 11, 128, 75)
+	musicVolumeRect    = image.Rect(0, 77, 128, 91)
+	musicWaveRect      = image.Rect(2, 94, 66, 102)
+	musicEffectRect    = image.Rect(2, 104, 66, 112)
+
+	// pattern mode
+	musicFlagsRect    = image.Rect(2, 58, 98, 66)
+	musicPatternsRect = image.Rect(0, 70, 128, 82)
+)
+
+const (
+	musicNoteWidth     = 4 // pitch graph column per note
+	musicChannelWidth  = 30
+	musicPatternWidth  = 4
+	musicPatternHeight = 6
+)
+
+var noteNames = []string{"c", "c#", "d", "d#", "e", "f", "f#", "g", "g#", "a", "a#", "b"}
+
+func noteName(pitch uint8) string {
+	return fmt.Sprintf("%s%d", noteNames[pitch%12], pitch/12)
+}
+
+type MusicEditor struct {
+	Mode     MusicMode
+	SFX      int
+	Pattern  int
+	Waveform uint8 // used for new notes
+	Effect   uint8
+
+	drag       image.Rectangle // graph being dragged on, empty when not dragging
+	dragButton ebiten.MouseButton
+	undo, redo []musicSnapshot
+}
+
+type musicSnapshot struct {
+	sfx   []byte
+	music []byte
+}
+
+func NewMusicEditor() MusicEditor {
+	return MusicEditor{Waveform: synth.WaveSquare}
+}
+
+func (e *MusicEditor) snapshot(bank *synth.Bank) musicSnapshot {
+	return musicSnapshot{
+		sfx:   append([]byte(nil), bank.SFX...),
+		music: append([]byte(nil), bank.Music...),
+	}
+}
+
+func (e *MusicEditor) restore(bank *synth.Bank, s musicSnapshot) {
+	copy(bank.SFX, s.sfx)
+	copy(bank.Music, s.music)
+}
+
+func (e *MusicEditor) PushUndo(bank *synth.Bank) {
+	e.undo = append(e.undo, e.snapshot(bank))
+	if len(e.undo) > MaxMusicUndo {
+		e.undo = e.undo[1:]
+	}
+	e.redo = nil
+}
+
+func (e *MusicEditor) Undo(bank *synth.Bank) {
+	if len(e.undo) == 0 {
+		return
+	}
+	e.redo = append(e.redo, e.snapshot(bank))
+	e.restore(bank, e.undo[len(e.undo)-1])
+	e.undo = e.undo[:len(e.undo)-1]
+}
+
+func (e *MusicEditor) Redo(bank *synth.Bank) {
+	if len(e.redo) == 0 {
+		return
+	}
+	e.undo = append(e.undo, e.snapshot(bank))
+	e.restore(bank, e.redo[len(e.redo)-1])
+	e.redo = e.redo[:len(e.redo)-1]
+}
+
+func wrapIndex(n, count int) int {
+	return (n%count + count) % count
+}
+
+// step moves to another sfx or pattern, depending on the mode
+func (e *MusicEditor) step(d int) {
+	if e.Mode == MusicSFX {
+		e.SFX = wrapIndex(e.SFX+d, synth.SFXCount)
+	} else {
+		e.Pattern = wrapIndex(e.Pattern+d, synth.PatternCount)
+	}
+}
+
+// TogglePlay plays what's being edited, or stops it if it's playing
+func (e *MusicEditor) TogglePlay(s *synth.Synth) {
+	if e.Mode == MusicPattern {
+		if s.Pattern() >= 0 {
+			s.PlayMusic(-1, 0)
+		} else {
+			s.PlayMusic(e.Pattern, 0)
+		}
+		return
+	}
+	for ch := 0; ch < synth.Channels; ch++ {
+		if sfx, _ := s.Playing(ch); sfx == e.SFX {
+			s.PlaySFX(-1, ch)
+			return
+		}
+	}
+	s.PlaySFX(e.SFX, -1)
+}
+
+// number fields go up on left click or wheel up and down on right click or
+// wheel down, shift steps by 8
+func fieldStep(mouse image.Point, r image.Rectangle) int {
+	if !mouse.In(r) {
+		return 0
+	}
+	step := 1
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		step = 8
+	}
+	_, wheel := ebiten.Wheel()
+	switch {
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || wheel > 0:
+		return step
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) || wheel < 0:
+		return -step
+	}
+	return 0
+}
+
+// paint edits the note under x on the graph being dragged
+func (e *MusicEditor) paint(bank *synth.Bank, x, y int) {
+	r := e.drag
+	i := min(max((x-r.Min.X)/musicNoteWidth, 0), synth.NoteCount-1)
+	note := bank.Note(e.SFX, i)
+	erase := e.dragButton == ebiten.MouseButtonRight
+
+	switch r {
+	case musicPitchRect:
+		if erase {
+			note.Volume = 0
+			break
+		}
+		note.Pitch = uint8(min(max(r.Max.Y-1-y, 0), 63))
+		note.Waveform = e.Waveform
+		note.Effect = e.Effect
+		if note.Volume == 0 {
+			note.Volume = 5
+		}
+	case musicVolumeRect:
+		note.Volume = uint8(min(max((r.Max.Y-y+1)/2, 0), 7))
+		if erase {
+			note.Volume = 0
+		}
+	}
+	bank.SetNote(e.SFX, i, note)
+}
+
+func musicChannelRect(c int) image.Rectangle {
+	x := 2 + c*(musicChannelWidth+1)
+	return image.Rect(x, 12, x+musicChannelWidth, 54)
+}
+
+// parts of a channel column in the pattern editor
+func musicChannelParts(c int) (toggle, field, preview image.Rectangle) {
+	r := musicChannelRect(c)
+	toggle = image.Rect(r.Min.X, r.Min.Y, r.Min.X+8, r.Min.Y+8)
+	field = image.Rect(r.Min.X+10, r.Min.Y, r.Max.X, r.Min.Y+8)
+	preview = image.Rect(r.Min.X, r.Min.Y+10, r.Max.X, r.Max.Y)
+	return
+}
+
+func musicFlagRect(i int) image.Rectangle {
+	w := musicFlagsRect.Dx() / 3
+	return image.Rect(musicFlagsRect.Min.X+i*w, musicFlagsRect.Min.Y, musicFlagsRect.Min.X+(i+1)*w-2, musicFlagsRect.Max.Y)
+}
+
+func musicPatternCell(n int) image.Rectangle {
+	perRow := musicPatternsRect.Dx() / musicPatternWidth
+	x := musicPatternsRect.Min.X + n%perRow*musicPatternWidth
+	y := musicPatternsRect.Min.Y + n/perRow*musicPatternHeight
+	return image.Rect(x, y, x+musicPatternWidth, y+musicPatternHeight)
+}
+
+func (g *Game) UpdateMusicEditor() error {
+	e := &g.MusicEditor
+	bank := g.Sound
+	mx, my := g.contentMouse()
+	mouse := image.Pt(mx, my)
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+
+	if ctrl {
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyZ):
+			e.Undo(bank)
+		case inpututil.IsKeyJustPressed(ebiten.KeyY):
+			e.Redo(bank)
+		}
+	} else {
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyTab):
+			e.Mode = 1 - e.Mode
+		case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+			e.TogglePlay(g.Synth)
+		case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+			e.step(-1)
+		case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+			e.step(1)
+		}
+	}
+
+	if e.drag != (image.Rectangle{}) {
+		if ebiten.IsMouseButtonPressed(e.dragButton) {
+			e.paint(bank, mx, my)
+		} else {
+			e.drag = image.Rectangle{}
+		}
+		return nil
+	}
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && mouse.In(musicModeRect) {
+		e.Mode = 1 - e.Mode
+		return nil
+	}
+	if d := fieldStep(mouse, musicIndexRect); d != 0 {
+		e.step(d)
+		return nil
+	}
+
+	if e.Mode == MusicSFX {
+		g.updateSFXEditor(mouse)
+	} else {
+		g.updatePatternEditor(mouse)
+	}
+	return nil
+}
+
+func (g *Game) updateSFXEditor(mouse image.Point) {
+	e := &g.MusicEditor
+	bank := g.Sound
+
+	if d := fieldStep(mouse, musicSpeedRect); d != 0 {
+		e.PushUndo(bank)
+		bank.SetSpeed(e.SFX, bank.Speed(e.SFX)+d)
+	}
+	start, end := bank.Loop(e.SFX)
+	if d := fieldStep(mouse, musicLoopStartRect); d != 0 {
+		e.PushUndo(bank)
+		bank.SetLoop(e.SFX, start+d, end)
+	}
+	if d := fieldStep(mouse, musicLoopEndRect); d != 0 {
+		e.PushUndo(bank)
+		bank.SetLoop(e.SFX, start, end+d)
+	}
+
+	left := inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+	right := inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight)
+	if !left && !right {
+		return
+	}
+
+	for _, r := range []image.Rectangle{musicPitchRect, musicVolumeRect} {
+		if mouse.In(r) {
+			e.PushUndo(bank)
+			e.drag = r
+			e.dragButton = ebiten.MouseButtonLeft
+			if right {
+				e.dragButton = ebiten.MouseButtonRight
+			}
+			e.paint(bank, mouse.X, mouse.Y)
+			return
+		}
+	}
+
+	if !left {
+		return
+	}
+	switch {
+	case mouse.In(musicWaveRect):
+		e.Waveform = uint8((mouse.X - musicWaveRect.Min.X) / 8)
+	case mouse.In(musicEffectRect):
+		e.Effect = uint8((mouse.X - musicEffectRect.Min.X) / 8)
+	}
+}
+
+func (g *Game) updatePatternEditor(mouse image.Point) {
+	e := &g.MusicEditor
+	bank := g.Sound
+	left := inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft)
+	p := bank.Pattern(e.Pattern)
+	changed := false
+
+	for c := 0; c < synth.Channels; c++ {
+		toggle, field, preview := musicChannelParts(c)
+		switch {
+		case left && mouse.In(toggle):
+			p.Enabled[c] = !p.Enabled[c]
+			changed = true
+		case mouse.In(field):
+			if d := fieldStep(mouse, field); d != 0 {
+				p.SFX[c] = uint8(wrapIndex(int(p.SFX[c])+d, synth.SFXCount))
+				p.Enabled[c] = true
+				changed = true
+			}
+		case left && mouse.In(preview):
+			// jump to the sfx to edit it
+			e.SFX = int(p.SFX[c])
+			e.Mode = MusicSFX
+		}
+	}
+
+	if left {
+		flags := []*bool{&p.LoopStart, &p.LoopEnd, &p.Stop}
+		for i, flag := range flags {
+			if mouse.In(musicFlagRect(i)) {
+				*flag = !*flag
+				changed = true
+			}
+		}
+		for n := 0; n < synth.PatternCount; n++ {
+			if mouse.In(musicPatternCell(n)) {
+				e.Pattern = n
+			}
+		}
+	}
+
+	if changed {
+		e.PushUndo(bank)
+		bank.SetPattern(e.Pattern, p)
+	}
+}
+
+// patternUsed reports whether a pattern plays anything
+func patternUsed(bank *synth.Bank, n int) bool {
+	p := bank.Pattern(n)
+	for c := 0; c < synth.Channels; c++ {
+		if p.Enabled[c] && !bank.IsEmpty(int(p.SFX[c])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) waveColor(w uint8) color.RGBA {
+	return g.Screen.Palette[8+w&7]
+}
+
+func (g *Game) drawButton(screen *ebiten.Image, r image.Rectangle, label string, active bool) {
+	if active {
+		fillRect(screen, r, g.Navbar.TabColor)
+	} else {
+		fillRect(screen, r, g.Screen.Palette[5])
+	}
+	drawLabel(screen, r.Min.X+2, r.Min.Y+2, label, g.Screen.Palette[7])
+}
+
+func (g *Game) drawField(screen *ebiten.Image, r image.Rectangle, label string) {
+	strokeRect(screen, r, g.Screen.Palette[5])
+	drawLabel(screen, r.Min.X+2, r.Min.Y+2, label, g.Screen.Palette[7])
+}
+
+func (g *Game) DrawMusicEditor(screen *ebiten.Image) {
+	e := &g.MusicEditor
+	screen.Fill(g.Screen.CliBgColor)
+
+	if e.Mode == MusicSFX {
+		g.drawButton(screen, musicModeRect, "sfx", true)
+		g.drawField(screen, musicIndexRect, fmt.Sprintf("#%02d", e.SFX))
+		g.drawSFXEditor(screen)
+	} else {
+		g.drawButton(screen, musicModeRect, "pat", true)
+		g.drawField(screen, musicIndexRect, fmt.Sprintf("#%02d", e.Pattern))
+		g.drawPatternEditor(screen)
+	}
+}
+
+func (g *Game) drawSFXEditor(screen *ebiten.Image) {
+	e := &g.MusicEditor
+	bank := g.Sound
+	white := g.Screen.Palette[7]
+	grey := g.Screen.Palette[5]
+	black := g.Screen.Palette[0]
+
+	start, end := bank.Loop(e.SFX)
+	g.drawField(screen, musicSpeedRect, fmt.Sprintf("spd%d", bank.Speed(e.SFX)))
+	g.drawField(screen, musicLoopStartRect, fmt.Sprintf("l%02d", start))
+	g.drawField(screen, musicLoopEndRect, fmt.Sprintf("e%02d", end))
+
+	fillRect(screen, musicPitchRect, black)
+	fillRect(screen, musicVolumeRect, black)
+
+	// loop points
+	if end > start {
+		orange := g.Screen.Palette[9]
+		x0 := musicPitchRect.Min.X + start*musicNoteWidth
+		x1 := musicPitchRect.Min.X + end*musicNoteWidth - 1
+		fillRect(screen, image.Rect(x0, musicPitchRect.Min.Y, x0+1, musicPitchRect.Max.Y), orange)
+		fillRect(screen, image.Rect(x1, musicPitchRect.Min.Y, x1+1, musicPitchRect.Max.Y), orange)
+	}
+
+	for i := 0; i < synth.NoteCount; i++ {
+		note := bank.Note(e.SFX, i)
+		x := musicPitchRect.Min.X + i*musicNoteWidth
+		if note.Volume == 0 {
+			continue
+		}
+		y := musicPitchRect.Max.Y - 1 - int(note.Pitch)
+		fillRect(screen, image.Rect(x, y, x+musicNoteWidth-1, musicPitchRect.Max.Y), g.waveColor(note.Waveform))
+		fillRect(screen, image.Rect(x, y, x+musicNoteWidth-1, y+1), white)
+
+		vy := musicVolumeRect.Max.Y - int(note.Volume)*2
+		fillRect(screen, image.Rect(x, vy, x+musicNoteWidth-1, musicVolumeRect.Max.Y), g.Screen.Palette[12])
+	}
+
+	// playing notes
+	for ch := 0; ch < synth.Channels; ch++ {
+		if sfx, note := g.Synth.Playing(ch); sfx == e.SFX {
+			x := musicPitchRect.Min.X + note*musicNoteWidth
+			strokeRect(screen, image.Rect(x-1, musicPitchRect.Min.Y, x+musicNoteWidth, musicPitchRect.Max.Y), grey)
+		}
+	}
+
+	// waveforms and effects
+	for i := 0; i < 8; i++ {
+		r := image.Rect(0, 0, 7, 8).Add(musicWaveRect.Min).Add(image.Pt(i*8, 0))
+		fillRect(screen, r, g.waveColor(uint8(i)))
+		if uint8(i) == e.Waveform {
+			strokeRect(screen, r, white)
+		}
+
+		r = image.Rect(0, 0, 7, 8).Add(musicEffectRect.Min).Add(image.Pt(i*8, 0))
+		g.drawButton(screen, r, fmt.Sprint(i), uint8(i) == e.Effect)
+	}
+
+	mx, my := g.contentMouse()
+	if mouse := image.Pt(mx, my); mouse.In(musicPitchRect) || mouse.In(musicVolumeRect) {
+		i := (mx - musicPitchRect.Min.X) / musicNoteWidth
+		note := bank.Note(e.SFX, i)
+		drawLabel(screen, 70, 95, fmt.Sprintf("%02d %s", i, noteName(note.Pitch)), white)
+		drawLabel(screen, 70, 105, fmt.Sprintf("w%d v%d e%d", note.Waveform, note.Volume, note.Effect), white)
+	} else {
+		drawLabel(screen, 70, 95, "space: play", white)
+	}
+}
+
+func (g *Game) drawPatternEditor(screen *ebiten.Image) {
+	e := &g.MusicEditor
+	bank := g.Sound
+	white := g.Screen.Palette[7]
+	grey := g.Screen.Palette[5]
+	black := g.Screen.Palette[0]
+	p := bank.Pattern(e.Pattern)
+	playing := g.Synth.Pattern()
+
+	for c := 0; c < synth.Channels; c++ {
+		toggle, field, preview := musicChannelParts(c)
+		if p.Enabled[c] {
+			fillRect(screen, toggle, g.Screen.Palette[11])
+		} else {
+			fillRect(screen, toggle, grey)
+		}
+		g.drawField(screen, field, fmt.Sprintf("#%02d", p.SFX[c]))
+
+		if !p.Enabled[c] {
+			fillRect(screen, preview, grey)
+			continue
+		}
+		fillRect(screen, preview, black)
+		n := int(p.SFX[c])
+		for i := 0; i < synth.NoteCount; i++ {
+			note := bank.Note(n, i)
+			if note.Volume == 0 {
+				continue
+			}
+			x := preview.Min.X + i*preview.Dx()/synth.NoteCount
+			y := preview.Max.Y - 1 - int(note.Pitch)*preview.Dy()/64
+			fillRect(screen, image.Rect(x, y, x+1, y+1), g.waveColor(note.Waveform))
+		}
+		if playing == e.Pattern {
+			if _, note := g.Synth.Playing(c); note >= 0 {
+				x := preview.Min.X + note*preview.Dx()/synth.NoteCount
+				fillRect(screen, image.Rect(x, preview.Min.Y, x+1, preview.Max.Y), grey)
+			}
+		}
+	}
+
+	flags := []struct {
+		label string
+		on    bool
+	}{
+		{"start", p.LoopStart},
+		{"end", p.LoopEnd},
+		{"stop", p.Stop},
+	}
+	for i, flag := range flags {
+		g.drawButton(screen, musicFlagRect(i), flag.label, flag.on)
+	}
+
+	for n := 0; n < synth.PatternCount; n++ {
+		r := musicPatternCell(n)
+		switch {
+		case n == playing:
+			fillRect(screen, r.Inset(1), g.Screen.Palette[8])
+		case patternUsed(bank, n):
+			fillRect(screen, r.Inset(1), g.Screen.Palette[12])
+		default:
+			fillRect(screen, r.Inset(1), grey)
+		}
+		if n == e.Pattern {
+			strokeRect(screen, r, white)
+		}
+	}
+
+	if playing >= 0 {
+		drawLabel(screen, 2, 88, fmt.Sprintf("playing #%02d", playing), white)
+	} else {
+		drawLabel(screen, 2, 88, "space: play", white)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Perf is what the ctrl+p overlay and stat() report about the running cart
+type Perf struct {
+	Visible bool
+	Upload  time.Duration // palette lookup and upload of the framebuffer in Draw
+	Calls   int           // api calls made by the last frame's update and draw
+	Memory  uint64        // bytes the cart's lua values took when the sandbox last measured
+
+	calls int
+}
+
+// countCalls wraps every go function in the dofi table, and the cls and
+// print globals, so each call adds to the frame's api count
+func (g *Game) countCalls(dofiTable *lua.LTable) {
+	wrap := func(fn *lua.LFunction) *lua.LFunction {
+		inner := fn.GFunction
+		return g.LuaVM.NewFunction(func(L *lua.LState) int {
+			g.Perf.calls++
+			return inner(L)
+		})
+	}
+
+	var names []lua.LValue
+	dofiTable.ForEach(func(name, value lua.LValue) {
+		if fn, ok := value.(*lua.LFunction); ok && fn.IsG {
+			names = append(names, name)
+		}
+	})
+	for _, name := range names {
+		dofiTable.RawSet(name, wrap(dofiTable.RawGet(name).(*lua.LFunction)))
+	}
+	for _, name := range []string{"cls", "print"} {
+		if fn, ok := g.LuaVM.GetGlobal(name).(*lua.LFunction); ok && fn.IsG {
+			g.LuaVM.SetGlobal(name, wrap(fn))
+		}
+	}
+}
+
+// startFrame keeps the api count and memory of the frame that just finished,
+// memory is what the sandbox last measured, a few times a second
+func (p *Perf) startFrame(memory uint64) {
+	p.Calls = p.calls
+	p.calls = 0
+	p.Memory = memory
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (g *Game) DrawPerf(screen *ebiten.Image) {
+	lines := []string{
+		fmt.Sprintf("fps %d/%d cpu %d%%", g.Loop.ActualFPS(), g.Loop.FPS, int(g.Loop.CPU()*100)),
+		fmt.Sprintf("upd %.1fms drw %.1fms", ms(g.Loop.UpdateTime()), ms(g.Loop.DrawTime())),
+		fmt.Sprintf("upl %.1fms mem %dk", ms(g.Perf.Upload), g.Perf.Memory/1024),
+		fmt.Sprintf("api %d calls", g.Perf.Calls),
+	}
+	lineHeight := g.Screen.FontSize + 1
+	fillRect(screen, image.Rect(0, 0, 88, len(lines)*lineHeight+2), color.RGBA{0, 0, 0, 192})
+	for i, line := range lines {
+		drawLabel(screen, 1, 1+i*lineHeight, line, g.Screen.Palette[11])
+	}
+}
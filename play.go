@@ -0,0 +1,200 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/fixed"
+	"github.com/mrdapoyo/dofi/input"
+	"github.com/mrdapoyo/dofi/loop"
+	"github.com/mrdapoyo/dofi/mem"
+	"github.com/mrdapoyo/dofi/sandbox"
+)
+
+// PlaySession remembers where a run started so escape can go back there
+type PlaySession struct {
+	ReturnTab   int
+	ReturnCli   bool
+	Line        int
+	Column      int
+	Source      []string // the code being run, for error lines
+	FromEditor  bool
+	Fixed       bool // the cartridge uses 16.16 fixed point math
+	drawPending bool
+}
+
+// ResetLuaVM throws away every global a previous run left behind, the new
+// vm is sandboxed and has the dofi api
+func (g *Game) ResetLuaVM() {
+	if g.Sandbox != nil {
+		g.Sandbox.Close()
+	}
+	g.Sandbox = sandbox.New(sandbox.DefaultLimits)
+	g.LuaVM = g.Sandbox.L
+	g.CartData = nil
+	g.Seed = fixed.Fix(time.Now().UnixNano())
+	g.Rand.Seed(g.runSeed())
+	g.setupLuaAPI()
+	// after the api, so its math functions get rounded too
+	if g.Play.Fixed {
+		g.Sandbox.UseFixed()
+	}
+}
+
+// StartCart runs the code editor's buffer from a clean VM and screen, then
+// calls _init. _update and _draw are driven by Update and Draw.
+func (g *Game) StartCart(returnTab int, returnCli bool) error {
+	editor, exists := CodeEditors[CodeEditorIndex]
+	if !exists {
+		editor = NewCodeEditor(nil)
+		CodeEditors[CodeEditorIndex] = editor
+	}
+	g.Play = PlaySession{
+		ReturnTab:  returnTab,
+		ReturnCli:  returnCli,
+		Line:       editor.Line,
+		Column:     editor.Column,
+		Source:     append([]string(nil), editor.Content...),
+		FromEditor: true,
+		Fixed:      g.FixedCart(),
+	}
+
+	g.ResetLuaVM()
+	g.StopSound()
+	g.Buttons = input.NewController()
+	g.DrawState.Reset()
+	g.DrawStack = nil
+	g.Memory.SaveROM()
+	g.Memory.Memset(mem.User, 0, mem.Screen-mem.User)
+	g.Canvas().Cls(0)
+
+	if err := g.Sandbox.Do(strings.Join(g.Play.Source, "\n")); err != nil {
+		return err
+	}
+	g.StartLoop()
+	if err := g.startReplay(); err != nil {
+		return err
+	}
+	if err := g.Sandbox.Call("_init", g.Sandbox.Limits.LoadTime); err != nil {
+		return err
+	}
+	g.FlushCartDataDue()
+	g.ScriptRunning = true
+	return nil
+}
+
+// StopCart goes back to wherever the run was started from
+func (g *Game) StopCart() {
+	g.leaveCart()
+	if g.Play.ReturnCli {
+		g.AppendLine("Script stopped.", false)
+		g.AppendLine("", true)
+	}
+}
+
+// AbortCart stops a run that went over a limit and says why in the cli
+func (g *Game) AbortCart(err error) {
+	g.leaveCart()
+	g.Navbar.CliEnabled = true
+	g.AppendLine("Cartridge stopped: "+err.Error(), false)
+	g.AppendLine("", true)
+}
+
+func (g *Game) leaveCart() {
+	g.ScriptRunning = false
+	g.StopSound()
+	g.FlushCartData()
+	g.finishReplay()
+	g.Devkit = Devkit{}
+	// pokes into cartridge data don't outlive the run
+	g.Memory.Reload(0, 0, mem.CartSize)
+	g.Navbar.CurrentTab = g.Play.ReturnTab
+	g.Navbar.CliEnabled = g.Play.ReturnCli
+
+	if editor, exists := CodeEditors[CodeEditorIndex]; exists && len(editor.Content) > 0 {
+		editor.Line = min(max(g.Play.Line, 0), len(editor.Content)-1)
+		editor.Column = min(max(g.Play.Column, 0), len(editor.Content[editor.Line]))
+		editor.ClearSelection()
+	}
+}
+
+// StartLoop runs the cartridge at 60fps when it has _update60, 30 otherwise
+func (g *Game) StartLoop() {
+	fps := 30
+	if g.LuaVM.GetGlobal("_update60").Type() == lua.LTFunction {
+		fps = 60
+	}
+	g.Loop = loop.New(fps)
+	// btnp repeats after the same time at either rate
+	g.Buttons.RepeatDelay = input.DefaultRepeatDelay * fps / 30
+	g.Buttons.RepeatInterval = input.DefaultRepeatInterval * fps / 30
+}
+
+// UpdatePlay runs _update or _update60 for every frame that's due
+func (g *Game) UpdatePlay() {
+	g.LatchButtons()
+	g.LatchDevkit()
+	for frames := g.Loop.Tick(); frames > 0 && g.ScriptRunning; frames-- {
+		g.updateFrame()
+	}
+}
+
+// updateFrame runs one frame's update
+func (g *Game) updateFrame() {
+	// ebiten can run two updates before a draw and the loop can catch up
+	// on several frames at once. a recording needs every frame drawn, so a
+	// draw still pending from the last frame runs now
+	if g.Play.drawPending && g.Loop.KeepDraws {
+		g.DrawPlay()
+		if !g.ScriptRunning {
+			return
+		}
+	}
+	if g.Replay != nil && g.Replay.Player != nil && g.Replay.Player.Done() {
+		g.StopCart()
+		return
+	}
+	g.UpdateButtons()
+	g.UpdateDevkit()
+	g.Perf.startFrame(g.Sandbox.Used())
+
+	update := "_update"
+	if g.Loop.FPS == 60 {
+		update = "_update60"
+	}
+	start := time.Now()
+	if err := g.Sandbox.Call(update, g.Sandbox.Limits.FrameTime); err != nil {
+		g.FailCart(update, err)
+		return
+	}
+	g.FlushCartDataDue()
+	g.Play.drawPending = g.Loop.Updated(time.Since(start))
+}
+
+// DrawPlay runs _draw if the last update asked for it
+func (g *Game) DrawPlay() {
+	if !g.Play.drawPending {
+		return
+	}
+	g.Play.drawPending = false
+	start := time.Now()
+	if err := g.Sandbox.Call("_draw", g.Sandbox.Limits.FrameTime); err != nil {
+		g.FailCart("_draw", err)
+		return
+	}
+	g.Loop.Drew(time.Since(start))
+	g.replayDrawn()
+}
+
+// UpdatePlayTab starts the cartridge when the play tab is opened
+func (g *Game) UpdatePlayTab() error {
+	if g.ScriptRunning {
+		return nil
+	}
+	if err := g.StartCart(g.Navbar.PreviousTab, false); err != nil {
+		g.FailCart("", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mrdapoyo/dofi/fixed"
+	"github.com/mrdapoyo/dofi/input"
+	"github.com/mrdapoyo/dofi/replay"
+)
+
+// ReplaySession is a run being recorded to a file, or played back from one.
+// only the seed and the buttons are recorded, carts that use the devkit
+// mouse and keyboard or saved cartdata can play back differently.
+type ReplaySession struct {
+	Path      string
+	Recording *replay.Recording
+	Player    *replay.Player // nil while recording
+}
+
+// RecordCart runs the code editor's cartridge and records it to path when
+// the run ends
+func (g *Game) RecordCart(path string) error {
+	g.Replay = &ReplaySession{Path: path, Recording: &replay.Recording{}}
+	return g.StartCart(g.Navbar.CurrentTab, true)
+}
+
+// ReplayCart runs the code editor's cartridge with the seed and buttons of
+// a recording loaded from path
+func (g *Game) ReplayCart(path string, recording *replay.Recording) error {
+	g.Replay = &ReplaySession{Path: path, Recording: recording, Player: replay.NewPlayer(recording)}
+	return g.StartCart(g.Navbar.CurrentTab, true)
+}
+
+// runSeed is the seed for rnd, the recorded one when playing back
+func (g *Game) runSeed() fixed.Fix {
+	if g.Replay != nil && g.Replay.Player != nil {
+		return fixed.Fix(g.Replay.Recording.Seed)
+	}
+	return g.Seed
+}
+
+// ReplayMismatch is a recording that can't play back on this cartridge
+type ReplayMismatch struct {
+	Detail string
+}
+
+func (e *ReplayMismatch) Error() string {
+	return "the replay was recorded " + e.Detail
+}
+
+func (g *Game) startReplay() error {
+	r := g.Replay
+	if r == nil {
+		return nil
+	}
+	// a dropped draw would change the screen hashes
+	g.Loop.KeepDraws = true
+	source := replay.Hash([]byte(strings.Join(g.Play.Source, "\n")))
+	if r.Player == nil {
+		r.Recording.Seed = int32(g.Seed)
+		r.Recording.FPS = g.Loop.FPS
+		r.Recording.Fixed = g.Play.Fixed
+		r.Recording.Source = source
+		return nil
+	}
+	// frames at another rate, or other math, can't line up
+	var mismatch string
+	switch {
+	case r.Recording.FPS != g.Loop.FPS:
+		mismatch = fmt.Sprintf("at %dfps, the cartridge runs at %dfps", r.Recording.FPS, g.Loop.FPS)
+	case r.Recording.Fixed && !g.Play.Fixed:
+		mismatch = "with fixed point math, try fixed on"
+	case !r.Recording.Fixed && g.Play.Fixed:
+		mismatch = "without fixed point math, try fixed off"
+	}
+	if mismatch != "" {
+		g.Replay = nil
+		return &ReplayMismatch{Detail: mismatch}
+	}
+	if r.Recording.Source != source {
+		g.AppendLine("Warning: the replay was recorded with different code", false)
+	}
+	return nil
+}
+
+// replayButtons records the buttons of a frame, or swaps in the recorded
+// ones when playing back
+func (g *Game) replayButtons(buttons [input.Players]input.Mask) [input.Players]input.Mask {
+	r := g.Replay
+	if r == nil {
+		return buttons
+	}
+	if r.Player == nil {
+		r.Recording.Record(buttons)
+		return buttons
+	}
+	recorded, _ := r.Player.Next()
+	return recorded
+}
+
+// replayDrawn records or checks the screen once a frame is drawn
+func (g *Game) replayDrawn() {
+	r := g.Replay
+	if r == nil {
+		return
+	}
+	screen := replay.Hash(g.Screen.Buffer.Pix)
+	if r.Player == nil {
+		r.Recording.Drawn(screen)
+	} else {
+		r.Player.Drawn(screen)
+	}
+}
+
+func (g *Game) finishReplay() {
+	r := g.Replay
+	if r == nil {
+		return
+	}
+	g.Replay = nil
+	if r.Player == nil {
+		// the run stopped before the last frame was drawn, it has no screen
+		if n := len(r.Recording.Frames); n > 0 && g.Play.drawPending {
+			r.Recording.Frames = r.Recording.Frames[:n-1]
+		}
+		if len(r.Recording.Frames) == 0 {
+			g.AppendLine("Nothing recorded, "+r.Path+" was not saved", false)
+			return
+		}
+		if err := r.Recording.Save(r.Path); err != nil {
+			g.AppendLine("Error saving replay: "+err.Error(), false)
+		} else {
+			g.AppendLine(fmt.Sprintf("Recorded %d frames to %s", len(r.Recording.Frames), r.Path), false)
+		}
+		return
+	}
+
+	total := len(r.Recording.Frames)
+	switch {
+	case r.Player.Diverged >= 0:
+		g.AppendLine(fmt.Sprintf("Replay diverged at frame %d of %d", r.Player.Diverged, total), false)
+	case r.Player.Frame == 0 && !r.Player.Done():
+		// the cartridge failed to load or stopped before its first frame
+		g.AppendLine(fmt.Sprintf("Replay could not start, none of its %d frames ran", total), false)
+	case !r.Player.Done():
+		g.AppendLine(fmt.Sprintf("Replay stopped after %d of %d frames, all matched", r.Player.Frame, total), false)
+	default:
+		g.AppendLine(fmt.Sprintf("Replay matched all %d frames", total), false)
+	}
+}
@@ -0,0 +1,228 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mrdapoyo/dofi/input"
+)
+
+// a recording is the random seed a run started with and the buttons held on
+// every frame, plus a hash of the screen after each frame to check a replay
+// against. it's plain text:
+//
+//	dofi replay
+//	version 1
+//	seed -1234567
+//	fps 30
+//	numbers float   (or fixed, the cartridge's math mode)
+//	source 8c1f...   (hash of the code that was recorded)
+//	00 00 00 00 5f3a...   (buttons of players 0-3, then the screen hash)
+//	01 00 00 00 77b2...
+
+const (
+	Header  = "dofi replay"
+	Version = 2
+)
+
+type Frame struct {
+	Buttons [input.Players]input.Mask
+	Screen  uint64
+}
+
+type Recording struct {
+	Seed   int32
+	FPS    int
+	Fixed  bool // recorded with 16.16 fixed point math
+	Source uint64
+	Frames []Frame
+}
+
+// Hash is what recordings use for code and screens, 64 bit fnv-1a
+func Hash(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// Record adds a frame with the buttons held during it, the screen is filled
+// in by Drawn once the frame has been drawn
+func (r *Recording) Record(buttons [input.Players]input.Mask) {
+	r.Frames = append(r.Frames, Frame{Buttons: buttons})
+}
+
+func (r *Recording) Drawn(screen uint64) {
+	if len(r.Frames) > 0 {
+		r.Frames[len(r.Frames)-1].Screen = screen
+	}
+}
+
+func Load(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+func (r *Recording) Save(path string) error {
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+func (r *Recording) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\nversion %d\n", Header, Version)
+	numbers := "float"
+	if r.Fixed {
+		numbers = "fixed"
+	}
+	fmt.Fprintf(bw, "seed %d\nfps %d\nnumbers %s\nsource %016x\n", r.Seed, r.FPS, numbers, r.Source)
+	for _, f := range r.Frames {
+		for _, m := range f.Buttons {
+			fmt.Fprintf(bw, "%02x ", uint8(m))
+		}
+		fmt.Fprintf(bw, "%016x\n", f.Screen)
+	}
+	return bw.Flush()
+}
+
+func Read(rd io.Reader) (*Recording, error) {
+	sc := bufio.NewScanner(rd)
+	lineNo := 0
+	next := func() (string, bool) {
+		if !sc.Scan() {
+			return "", false
+		}
+		lineNo++
+		return strings.TrimSpace(sc.Text()), true
+	}
+	// field reads "name value" from the next line
+	field := func(name string) (string, error) {
+		line, ok := next()
+		value, found := strings.CutPrefix(line, name+" ")
+		if !ok || !found {
+			return "", fmt.Errorf("line %d: missing %s", lineNo, name)
+		}
+		return value, nil
+	}
+
+	if line, ok := next(); !ok || line != Header {
+		return nil, fmt.Errorf("not a dofi replay")
+	}
+	value, err := field("version")
+	if err != nil {
+		return nil, err
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version > Version {
+		return nil, fmt.Errorf("line %d: unsupported version %q", lineNo, value)
+	}
+
+	r := &Recording{}
+	if value, err = field("seed"); err != nil {
+		return nil, err
+	}
+	seed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: bad seed: %v", lineNo, err)
+	}
+	r.Seed = int32(seed)
+	if value, err = field("fps"); err != nil {
+		return nil, err
+	}
+	if r.FPS, err = strconv.Atoi(value); err != nil {
+		return nil, fmt.Errorf("line %d: bad fps: %v", lineNo, err)
+	}
+	// version 1 recordings were all float
+	if version >= 2 {
+		if value, err = field("numbers"); err != nil {
+			return nil, err
+		}
+		switch value {
+		case "float":
+		case "fixed":
+			r.Fixed = true
+		default:
+			return nil, fmt.Errorf("line %d: bad numbers %q", lineNo, value)
+		}
+	}
+	if value, err = field("source"); err != nil {
+		return nil, err
+	}
+	if r.Source, err = strconv.ParseUint(value, 16, 64); err != nil {
+		return nil, fmt.Errorf("line %d: bad source hash: %v", lineNo, err)
+	}
+
+	for {
+		line, ok := next()
+		if !ok {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) != input.Players+1 {
+			return nil, fmt.Errorf("line %d: expected %d values, got %d", lineNo, input.Players+1, len(parts))
+		}
+		var f Frame
+		for p := 0; p < input.Players; p++ {
+			m, err := strconv.ParseUint(parts[p], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad buttons: %v", lineNo, err)
+			}
+			f.Buttons[p] = input.Mask(m)
+		}
+		if f.Screen, err = strconv.ParseUint(parts[input.Players], 16, 64); err != nil {
+			return nil, fmt.Errorf("line %d: bad screen hash: %v", lineNo, err)
+		}
+		r.Frames = append(r.Frames, f)
+	}
+	return r, sc.Err()
+}
+
+// Player feeds a recording back one frame at a time and checks the screens
+type Player struct {
+	Recording *Recording
+	Frame     int // frames played so far
+	Diverged  int // first frame whose screen didn't match, -1 while they all do
+}
+
+func NewPlayer(r *Recording) *Player {
+	return &Player{Recording: r, Diverged: -1}
+}
+
+// Next is the buttons of the next frame, false once the recording is over
+func (p *Player) Next() ([input.Players]input.Mask, bool) {
+	if p.Frame >= len(p.Recording.Frames) {
+		return [input.Players]input.Mask{}, false
+	}
+	p.Frame++
+	return p.Recording.Frames[p.Frame-1].Buttons, true
+}
+
+// Drawn checks the screen of the frame Next last returned
+func (p *Player) Drawn(screen uint64) {
+	if p.Frame == 0 || p.Diverged >= 0 {
+		return
+	}
+	if p.Recording.Frames[p.Frame-1].Screen != screen {
+		p.Diverged = p.Frame - 1
+	}
+}
+
+// Done reports whether every recorded frame has been played
+func (p *Player) Done() bool {
+	return p.Frame >= len(p.Recording.Frames)
+}
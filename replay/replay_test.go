@@ -0,0 +1,96 @@
+package replay
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mrdapoyo/dofi/input"
+)
+
+func TestRoundTrip(t *testing.T) {
+	r := &Recording{Seed: -1234567, FPS: 60, Fixed: true, Source: Hash([]byte("function _draw() end"))}
+	r.Record([input.Players]input.Mask{1 << input.Left, 0, 0, 1 << input.X})
+	r.Drawn(Hash([]byte{1, 2, 3}))
+	r.Record([input.Players]input.Mask{})
+	r.Drawn(0xffffffffffffffff)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if got.Seed != r.Seed || got.FPS != r.FPS || got.Fixed != r.Fixed || got.Source != r.Source {
+		t.Fatalf("header wrong. expected=%+v, got=%+v", r, got)
+	}
+	if len(got.Frames) != len(r.Frames) {
+		t.Fatalf("frame count wrong. expected=%d, got=%d", len(r.Frames), len(got.Frames))
+	}
+	for i := range r.Frames {
+		if got.Frames[i] != r.Frames[i] {
+			t.Fatalf("frames[%d] wrong. expected=%+v, got=%+v", i, r.Frames[i], got.Frames[i])
+		}
+	}
+}
+
+func TestReadVersion1(t *testing.T) {
+	r, err := Read(strings.NewReader("dofi replay\nversion 1\nseed 5\nfps 30\nsource 0\n00 00 00 00 1\n"))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if r.Fixed || r.FPS != 30 || len(r.Frames) != 1 {
+		t.Fatalf("version 1 recording wrong. got=%+v", r)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"dofi cartridge\n", "not a dofi replay"},
+		{"dofi replay\nversion 9\n", "unsupported version"},
+		{"dofi replay\nversion 1\nfps 30\n", "missing seed"},
+		{"dofi replay\nversion 1\nseed 1\nfps 30\nsource 0\n00 00 00 ff\n", "expected 5 values"},
+		{"dofi replay\nversion 1\nseed 1\nfps 30\nsource 0\n00 00 00 zz 0\n", "bad buttons"},
+		{"dofi replay\nversion 2\nseed 1\nfps 30\nsource 0\n", "missing numbers"},
+		{"dofi replay\nversion 2\nseed 1\nfps 30\nnumbers double\nsource 0\n", "bad numbers"},
+	}
+
+	for i, tt := range tests {
+		_, err := Read(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Fatalf("tests[%d] - error wrong. expected=%q, got=%v", i, tt.err, err)
+		}
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	r := &Recording{}
+	for i := 0; i < 3; i++ {
+		r.Record([input.Players]input.Mask{input.Mask(i)})
+		r.Drawn(uint64(i))
+	}
+
+	p := NewPlayer(r)
+	for i := 0; i < 3; i++ {
+		buttons, ok := p.Next()
+		if !ok || buttons[0] != input.Mask(i) {
+			t.Fatalf("frame %d wrong. got=%v, %t", i, buttons, ok)
+		}
+		screen := uint64(i)
+		if i == 1 {
+			screen = 99
+		}
+		p.Drawn(screen)
+	}
+	if _, ok := p.Next(); ok || !p.Done() {
+		t.Fatalf("player not done after the last frame")
+	}
+	if p.Diverged != 1 {
+		t.Fatalf("diverged frame wrong. expected=1, got=%d", p.Diverged)
+	}
+}
@@ -0,0 +1,70 @@
+package sandbox
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// ChunkName is what lua calls the cartridge code in error messages
+const ChunkName = "cart"
+
+// RuntimeError is a lua error taken apart for the crash screen
+type RuntimeError struct {
+	Message   string   // without the chunk and line prefix
+	Line      int      // line in the cartridge, 0 when unknown
+	Traceback []string // innermost call first
+	Syntax    bool
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Line > 0 {
+		return "line " + strconv.Itoa(e.Line) + ": " + e.Message
+	}
+	return e.Message
+}
+
+var (
+	runtimeLocation = regexp.MustCompile(`^` + ChunkName + `:(\d+): `)
+	syntaxLocation  = regexp.MustCompile(`^` + ChunkName + ` line:(\d+)\(column:\d+\) `)
+	frameLocation   = regexp.MustCompile(`^` + ChunkName + `:(\d+):`)
+)
+
+// ParseError finds the message, line and traceback in a gopher-lua error.
+// errors that don't come from lua are kept as they are.
+func ParseError(err error) *RuntimeError {
+	e := &RuntimeError{Message: err.Error()}
+	var api *lua.ApiError
+	if !errors.As(err, &api) {
+		return e
+	}
+
+	msg := strings.TrimSpace(api.Object.String())
+	if m := syntaxLocation.FindStringSubmatch(msg); m != nil {
+		e.Syntax = true
+		e.Line, _ = strconv.Atoi(m[1])
+		msg = strings.Join(strings.Fields(msg[len(m[0]):]), " ")
+	} else if m := runtimeLocation.FindStringSubmatch(msg); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		msg = msg[len(m[0]):]
+	}
+	e.Message = msg
+
+	lines := strings.Split(api.StackTrace, "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "[G]: ?" {
+			continue
+		}
+		e.Traceback = append(e.Traceback, line)
+		// errors raised with a non string value have no location, use the
+		// first frame in the cartridge
+		if m := frameLocation.FindStringSubmatch(line); m != nil && e.Line == 0 {
+			e.Line, _ = strconv.Atoi(m[1])
+		}
+	}
+	return e
+}
@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		src       string
+		message   string
+		line      int
+		syntax    bool
+		traceback int
+	}{
+		{"x = = 1", "near '=': syntax error", 1, true, 0},
+		{"\n\nlocal a = nil\nfunction f() return a.b end\nf()", "attempt to index a non-table object(nil) with key 'b'", 4, false, 2},
+		{"error('boom')", "boom", 1, false, 2},
+		{"\nerror({})", "", 2, false, 2},
+		{"x = 'abc\n", "near 'abc': unterminated string", 2, true, 0},
+	}
+
+	for i, tt := range tests {
+		s := New(testLimits)
+		err := s.Do(tt.src)
+		s.Close()
+		if err == nil {
+			t.Fatalf("tests[%d] - Do() returned no error", i)
+		}
+
+		e := ParseError(err)
+		if tt.message != "" && e.Message != tt.message {
+			t.Fatalf("tests[%d] - message wrong. expected=%q, got=%q", i, tt.message, e.Message)
+		}
+		if e.Line != tt.line {
+			t.Fatalf("tests[%d] - line wrong. expected=%d, got=%d", i, tt.line, e.Line)
+		}
+		if e.Syntax != tt.syntax {
+			t.Fatalf("tests[%d] - syntax wrong. expected=%t, got=%t", i, tt.syntax, e.Syntax)
+		}
+		if len(e.Traceback) != tt.traceback {
+			t.Fatalf("tests[%d] - traceback wrong. expected=%d frames, got=%q", i, tt.traceback, e.Traceback)
+		}
+	}
+}
+
+func TestParseCallError(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+	if err := s.Do("function _update()\n  local t = nil\n  return t[1]\nend"); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+
+	e := ParseError(s.Call("_update", testLimits.FrameTime))
+	if e.Line != 3 {
+		t.Fatalf("line wrong. expected=3, got=%d", e.Line)
+	}
+	if e.Error() != "line 3: "+e.Message {
+		t.Fatalf("Error() wrong. got=%q", e.Error())
+	}
+
+	plain := ParseError(errors.New("not lua"))
+	if plain.Message != "not lua" || plain.Line != 0 {
+		t.Fatalf("plain error wrong. got=%+v", plain)
+	}
+}
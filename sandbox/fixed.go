@@ -0,0 +1,341 @@
+package sandbox
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+
+	"github.com/mrdapoyo/dofi/fixed"
+)
+
+// in fixed point mode every number a cartridge works with is 16.16, like
+// pico-8's. gopher-lua only has float64 arithmetic, so the code is parsed,
+// number literals are rounded to 16.16 and every arithmetic operator
+// becomes a call to a go function doing it on fixed point bits. the
+// functions sit in globals no lua code can name.
+
+// fixedOps are the globals the rewritten code calls, by operator
+var fixedOps = map[string]string{
+	"+":   "(fixed +)",
+	"-":   "(fixed -)",
+	"*":   "(fixed *)",
+	"/":   "(fixed /)",
+	"%":   "(fixed %)",
+	"^":   "(fixed ^)",
+	"unm": "(fixed unm)",
+}
+
+var fixedEvents = map[string]string{
+	"+": "__add", "-": "__sub", "*": "__mul", "/": "__div", "%": "__mod", "^": "__pow",
+}
+
+// UseFixed switches the sandbox to 16.16 fixed point numbers. code loaded
+// by Do and loadstring from then on does its arithmetic in fixed point, and
+// tonumber and the math library round what they return.
+func (s *Sandbox) UseFixed() {
+	s.fixed = true
+	L := s.L
+	for op, name := range fixedOps {
+		L.SetGlobal(name, L.NewFunction(fixedArith(op)))
+	}
+	L.SetGlobal("loadstring", L.NewFunction(s.loadString))
+	if fn, ok := L.GetGlobal("tonumber").(*lua.LFunction); ok && fn.IsG {
+		L.SetGlobal("tonumber", L.NewFunction(roundResults(fn.GFunction)))
+	}
+	if mathlib, ok := L.GetGlobal(lua.MathLibName).(*lua.LTable); ok {
+		var names []lua.LValue
+		mathlib.ForEach(func(name, v lua.LValue) {
+			if fn, ok := v.(*lua.LFunction); ok && fn.IsG {
+				names = append(names, name)
+			}
+		})
+		for _, name := range names {
+			fn := mathlib.RawGet(name).(*lua.LFunction)
+			mathlib.RawSet(name, L.NewFunction(roundResults(fn.GFunction)))
+		}
+		for _, name := range []string{"pi", "huge"} {
+			if n, ok := L.GetField(mathlib, name).(lua.LNumber); ok {
+				L.SetField(mathlib, name, roundNumber(n))
+			}
+		}
+	}
+}
+
+// load compiles a chunk, through the fixed point rewrite when it's on
+func (s *Sandbox) load(r io.Reader, name string) (*lua.LFunction, error) {
+	if !s.fixed {
+		return s.L.Load(r, name)
+	}
+	chunk, err := parse.Parse(r, name)
+	if err != nil {
+		return nil, &lua.ApiError{Type: lua.ApiErrorSyntax, Object: lua.LString(err.Error()), Cause: err}
+	}
+	fixStmts(chunk)
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return nil, &lua.ApiError{Type: lua.ApiErrorSyntax, Object: lua.LString(err.Error()), Cause: err}
+	}
+	return s.L.NewFunctionFromProto(proto), nil
+}
+
+// loadString is loadstring for fixed point mode
+func (s *Sandbox) loadString(L *lua.LState) int {
+	src := L.CheckString(1)
+	name := L.OptString(2, "<string>")
+	fn, err := s.load(strings.NewReader(src), name)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(fn)
+	return 1
+}
+
+func roundNumber(n lua.LNumber) lua.LNumber {
+	return lua.LNumber(toFix(float64(n)).Float())
+}
+
+// toFix is fixed.FromFloat, except that infinities saturate the way a
+// division by zero does
+func toFix(f float64) fixed.Fix {
+	switch {
+	case math.IsInf(f, 1):
+		return fixed.Max
+	case math.IsInf(f, -1):
+		return fixed.Min
+	}
+	return fixed.FromFloat(f)
+}
+
+// roundResults wraps a go function so the numbers it returns are 16.16
+func roundResults(inner lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		n := inner(L)
+		for i := L.GetTop() - n + 1; i <= L.GetTop(); i++ {
+			if v, ok := L.Get(i).(lua.LNumber); ok {
+				L.Replace(i, roundNumber(v))
+			}
+		}
+		return n
+	}
+}
+
+// arithNumber is v as a number the way lua's arithmetic sees it, strings
+// included
+func arithNumber(v lua.LValue) (fixed.Fix, bool) {
+	switch v := v.(type) {
+	case lua.LNumber:
+		return toFix(float64(v)), true
+	case lua.LString:
+		if f, ok := parseNumber(string(v)); ok {
+			return toFix(f), true
+		}
+	}
+	return 0, false
+}
+
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, true
+	}
+	if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func fixedArith(op string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		a := L.Get(1)
+		b := a
+		if op != "unm" {
+			b = L.Get(2)
+		}
+		// metamethods come first, like in lua
+		if _, num := a.(lua.LNumber); !num || b.Type() != lua.LTNumber {
+			event := "__unm"
+			if op != "unm" {
+				event = fixedEvents[op]
+			}
+			meta := L.GetMetaField(a, event)
+			if meta == lua.LNil {
+				meta = L.GetMetaField(b, event)
+			}
+			if meta.Type() == lua.LTFunction {
+				L.Push(meta)
+				L.Push(a)
+				L.Push(b)
+				L.Call(2, 1)
+				return 1
+			}
+		}
+		x, ok1 := arithNumber(a)
+		y, ok2 := arithNumber(b)
+		if !ok1 || !ok2 {
+			if op == "unm" {
+				L.RaiseError("cannot perform unm operation between %v and %v", a.Type(), b.Type())
+			}
+			L.RaiseError("cannot perform %v operation between %v and %v",
+				strings.TrimLeft(fixedEvents[op], "_"), a.Type(), b.Type())
+		}
+		L.Push(lua.LNumber(fixedOp(op, x, y).Float()))
+		return 1
+	}
+}
+
+// fixedOp does one operator on 16.16 numbers, wrapping like pico-8
+func fixedOp(op string, x, y fixed.Fix) fixed.Fix {
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x.Mul(y)
+	case "/":
+		return x.Div(y)
+	case "%":
+		// the sign follows the divisor, x % 0 is 0
+		if y == 0 {
+			return 0
+		}
+		m := x % y
+		if m != 0 && (m < 0) != (y < 0) {
+			m += y
+		}
+		return m
+	case "^":
+		return toFix(math.Pow(x.Float(), y.Float()))
+	case "unm":
+		return -x
+	}
+	return 0
+}
+
+// fixStmts rewrites a chunk's literals and arithmetic for fixed point
+func fixStmts(stmts []ast.Stmt) {
+	for _, st := range stmts {
+		fixStmt(st)
+	}
+}
+
+func fixStmt(st ast.Stmt) {
+	switch st := st.(type) {
+	case *ast.AssignStmt:
+		fixExprs(st.Lhs)
+		fixExprs(st.Rhs)
+	case *ast.LocalAssignStmt:
+		fixExprs(st.Exprs)
+	case *ast.FuncCallStmt:
+		st.Expr = fixExpr(st.Expr)
+	case *ast.DoBlockStmt:
+		fixStmts(st.Stmts)
+	case *ast.WhileStmt:
+		st.Condition = fixExpr(st.Condition)
+		fixStmts(st.Stmts)
+	case *ast.RepeatStmt:
+		st.Condition = fixExpr(st.Condition)
+		fixStmts(st.Stmts)
+	case *ast.IfStmt:
+		st.Condition = fixExpr(st.Condition)
+		fixStmts(st.Then)
+		fixStmts(st.Else)
+	case *ast.NumberForStmt:
+		st.Init = fixExpr(st.Init)
+		st.Limit = fixExpr(st.Limit)
+		if st.Step != nil {
+			st.Step = fixExpr(st.Step)
+		}
+		fixStmts(st.Stmts)
+	case *ast.GenericForStmt:
+		fixExprs(st.Exprs)
+		fixStmts(st.Stmts)
+	case *ast.FuncDefStmt:
+		st.Name.Func = fixExpr(st.Name.Func)
+		if st.Name.Receiver != nil {
+			st.Name.Receiver = fixExpr(st.Name.Receiver)
+		}
+		fixStmts(st.Func.Stmts)
+	case *ast.ReturnStmt:
+		fixExprs(st.Exprs)
+	}
+}
+
+func fixExprs(exprs []ast.Expr) {
+	for i, e := range exprs {
+		exprs[i] = fixExpr(e)
+	}
+}
+
+func fixExpr(e ast.Expr) ast.Expr {
+	switch e := e.(type) {
+	case *ast.NumberExpr:
+		if f, ok := parseNumber(e.Value); ok {
+			e.Value = strconv.FormatFloat(toFix(f).Float(), 'g', -1, 64)
+		}
+	case *ast.AttrGetExpr:
+		e.Object = fixExpr(e.Object)
+		e.Key = fixExpr(e.Key)
+	case *ast.TableExpr:
+		for _, f := range e.Fields {
+			if f.Key != nil {
+				f.Key = fixExpr(f.Key)
+			}
+			f.Value = fixExpr(f.Value)
+		}
+	case *ast.FuncCallExpr:
+		if e.Func != nil {
+			e.Func = fixExpr(e.Func)
+		}
+		if e.Receiver != nil {
+			e.Receiver = fixExpr(e.Receiver)
+		}
+		fixExprs(e.Args)
+	case *ast.LogicalOpExpr:
+		e.Lhs = fixExpr(e.Lhs)
+		e.Rhs = fixExpr(e.Rhs)
+	case *ast.RelationalOpExpr:
+		e.Lhs = fixExpr(e.Lhs)
+		e.Rhs = fixExpr(e.Rhs)
+	case *ast.StringConcatOpExpr:
+		e.Lhs = fixExpr(e.Lhs)
+		e.Rhs = fixExpr(e.Rhs)
+	case *ast.UnaryNotOpExpr:
+		e.Expr = fixExpr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		e.Expr = fixExpr(e.Expr)
+	case *ast.FunctionExpr:
+		fixStmts(e.Stmts)
+	case *ast.ArithmeticOpExpr:
+		return fixedCall(e, fixedOps[e.Operator], fixExpr(e.Lhs), fixExpr(e.Rhs))
+	case *ast.UnaryMinusOpExpr:
+		// a negative literal stays a literal
+		if n, ok := e.Expr.(*ast.NumberExpr); ok {
+			if f, ok := parseNumber(n.Value); ok {
+				n.Value = strconv.FormatFloat(toFix(-f).Float(), 'g', -1, 64)
+				return n
+			}
+		}
+		return fixedCall(e, fixedOps["unm"], fixExpr(e.Expr))
+	}
+	return e
+}
+
+// fixedCall is a call to one of the fixedOps globals, on the line of the
+// expression it replaces so errors point at the cartridge's code
+func fixedCall(at ast.Expr, name string, args ...ast.Expr) ast.Expr {
+	fn := &ast.IdentExpr{Value: name}
+	fn.SetLine(at.Line())
+	fn.SetLastLine(at.LastLine())
+	call := &ast.FuncCallExpr{Func: fn, Args: args}
+	call.SetLine(at.Line())
+	call.SetLastLine(at.LastLine())
+	return call
+}
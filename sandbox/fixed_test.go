@@ -0,0 +1,74 @@
+package sandbox
+
+import (
+	"strings"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/mrdapoyo/dofi/fixed"
+)
+
+func TestFixedArithmetic(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+	s.UseFixed()
+
+	tests := []struct {
+		code     string
+		expected float64
+	}{
+		{"r = 1/3", fixed.One.Div(fixed.FromInt(3)).Float()},
+		{"r = 0.1+0.2 == 0.3 and 1 or 0", 1},
+		{"r = 32767+1", -32768},
+		{"r = -32768-1", 32767},
+		{"r = 1/0", fixed.Max.Float()},
+		{"r = -1/0", fixed.Min.Float()},
+		{"r = -7%3", 2},
+		{"r = 7%-3", -2},
+		{"r = 5%0", 0},
+		{"r = 200*200", fixed.FromInt(200).Mul(fixed.FromInt(200)).Float()},
+		{"r = 2^3", 8},
+		{"local x = 3 r = -x", -3},
+		{"r = -(-2)", 2},
+		{`r = "10"+1`, 11},
+		{"r = math.sqrt(2)", fixed.FromFloat(1.4142135623730951).Float()},
+		{"r = math.pi", fixed.FromFloat(3.141592653589793).Float()},
+		{`r = tonumber("0.1")`, fixed.FromFloat(0.1).Float()},
+		{`r = loadstring("return 1/3")()`, fixed.One.Div(fixed.FromInt(3)).Float()},
+		{"local t = 0 for i = 1, 10 do t = t + 0.1 end r = t", (fixed.FromFloat(0.1) * 10).Float()},
+		{`local v = setmetatable({}, {__add = function(a, b) return 42 end}) r = v + 1`, 42},
+	}
+
+	for i, tt := range tests {
+		if err := s.Do(tt.code); err != nil {
+			t.Fatalf("tests[%d] - %q failed: %v", i, tt.code, err)
+		}
+		n, ok := s.L.GetGlobal("r").(lua.LNumber)
+		if !ok || float64(n) != tt.expected {
+			t.Fatalf("tests[%d] - %q wrong. expected=%v, got=%v", i, tt.code, tt.expected, s.L.GetGlobal("r"))
+		}
+	}
+}
+
+func TestFixedErrors(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+	s.UseFixed()
+
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"local a = 1\nlocal b = a + {}", ":2:"},
+		{"local a = {}\nlocal b = -a", "unm"},
+		{"local a = 1 +", "cart"},
+	}
+
+	for i, tt := range tests {
+		err := s.Do(tt.code)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Fatalf("tests[%d] - error wrong. expected=%q in it, got=%v", i, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package sandbox
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"time"
+)
+
+// checkEvery is how many instructions run between two limit checks
+const checkEvery = 1 << 14
+
+// the heap still in use after the last gc, unlike the total allocated it
+// doesn't grow with garbage
+const liveMetric = "/gc/heap/live:bytes"
+
+// closed is what Done returns once a callback went over a limit
+var closed = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// limiter is the context the state and its coroutines run under for their
+// whole life. a context set and cancelled per callback would kill every
+// coroutine made in one callback by the next, since gopher-lua derives
+// theirs from it. the vm asks for Done before every instruction, so every
+// checkEvery of them the limiter looks at the running callback's time and
+// at how much the live heap grew since it started.
+type limiter struct {
+	running  bool
+	deadline time.Time // zero when the callback has no time limit
+	budget   time.Duration
+	timeout  string // the time error's detail, with a %v for the budget
+	memory   uint64
+	start    uint64
+	n        int
+	err      error // the limit the callback went over
+	sample   []metrics.Sample
+}
+
+func newLimiter() *limiter {
+	return &limiter{sample: []metrics.Sample{{Name: liveMetric}}}
+}
+
+// begin starts the limits of a callback, memory is how much the live heap
+// may grow during it
+func (l *limiter) begin(budget time.Duration, timeout string, memory uint64) {
+	*l = limiter{
+		running: true,
+		budget:  budget,
+		timeout: timeout,
+		memory:  memory,
+		sample:  l.sample,
+	}
+	if budget > 0 {
+		l.deadline = time.Now().Add(budget)
+	}
+	l.start = l.live()
+}
+
+// end stops the limits and returns the one the callback went over, if any
+func (l *limiter) end() error {
+	err := l.err
+	l.running = false
+	l.err = nil
+	return err
+}
+
+func (l *limiter) live() uint64 {
+	metrics.Read(l.sample)
+	if l.sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return l.sample[0].Value.Uint64()
+}
+
+func (l *limiter) check() {
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		l.err = &LimitError{Limit: "time", Detail: fmt.Sprintf(l.timeout, l.budget)}
+		return
+	}
+	if live := l.live(); l.memory > 0 && live > l.start && live-l.start > l.memory {
+		l.err = memoryError(live-l.start, l.memory)
+	}
+}
+
+func (l *limiter) Done() <-chan struct{} {
+	if l.running && l.err == nil {
+		l.n++
+		if l.n%checkEvery == 0 {
+			l.check()
+		}
+	}
+	if l.err != nil {
+		return closed
+	}
+	// nil never fires, and keeps coroutine contexts from hanging onto it
+	return nil
+}
+
+func (l *limiter) Err() error {
+	return l.err
+}
+
+func (l *limiter) Deadline() (time.Time, bool) {
+	return l.deadline, !l.deadline.IsZero()
+}
+
+func (l *limiter) Value(key any) any {
+	return nil
+}
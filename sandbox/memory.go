@@ -0,0 +1,92 @@
+package sandbox
+
+import lua "github.com/yuin/gopher-lua"
+
+// rough sizes of lua values, in bytes. gopher-lua doesn't count what a
+// state allocates, so the sandbox adds up what the cartridge can reach.
+const (
+	valueSize    = 16 // an LValue in a slice or map
+	tableSize    = 96
+	entrySize    = 48 // one hash part entry, key and value
+	stringSize   = 16
+	functionSize = 64
+	upvalueSize  = 32
+	threadSize   = 512
+	userdataSize = 48
+)
+
+// measure adds up the lua values reachable from the globals and the
+// registry, stopping once it's past limit so an oversized state costs no
+// more than that to measure
+type measure struct {
+	seen  map[lua.LValue]bool
+	total uint64
+	limit uint64
+}
+
+func (s *Sandbox) measure() uint64 {
+	m := measure{seen: map[lua.LValue]bool{}, limit: s.Limits.Memory}
+	m.value(s.L.G.Global)
+	m.value(s.L.G.Registry)
+	return m.total
+}
+
+func (m *measure) over() bool {
+	return m.limit > 0 && m.total > m.limit
+}
+
+func (m *measure) value(v lua.LValue) {
+	if m.over() {
+		return
+	}
+	switch v := v.(type) {
+	case lua.LString:
+		// equal strings may share memory, counting them twice is fine
+		m.total += stringSize + uint64(len(v))
+	case *lua.LTable:
+		if m.seen[v] {
+			return
+		}
+		m.seen[v] = true
+		m.total += tableSize
+		n := uint64(v.Len())
+		m.total += n * valueSize
+		v.ForEach(func(k, val lua.LValue) {
+			if kn, ok := k.(lua.LNumber); !ok || kn < 1 || uint64(kn) > n {
+				m.total += entrySize
+			}
+			m.value(k)
+			m.value(val)
+		})
+		m.value(v.Metatable)
+	case *lua.LFunction:
+		if m.seen[v] {
+			return
+		}
+		m.seen[v] = true
+		m.total += functionSize + uint64(len(v.Upvalues))*upvalueSize
+		for _, uv := range v.Upvalues {
+			m.value(uv.Value())
+		}
+		if v.Env != nil {
+			m.value(v.Env)
+		}
+	case *lua.LUserData:
+		if m.seen[v] {
+			return
+		}
+		m.seen[v] = true
+		m.total += userdataSize
+		m.value(v.Metatable)
+	case *lua.LState:
+		// a suspended coroutine holds its stack
+		if m.seen[v] {
+			return
+		}
+		m.seen[v] = true
+		m.total += threadSize
+		for i := 1; i <= v.GetTop(); i++ {
+			m.value(v.Get(i))
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Limits bound what a cartridge can take from the console
+type Limits struct {
+	FrameTime time.Duration // longest a single callback may run
+	LoadTime  time.Duration // longest the top level chunk and _init may run
+	Memory    uint64        // how much the cartridge's lua values may take, in bytes
+	CallStack int
+	Registry  int // most lua values on the stack at once
+}
+
+var DefaultLimits = Limits{
+	FrameTime: 250 * time.Millisecond,
+	LoadTime:  2 * time.Second,
+	Memory:    64 << 20,
+	CallStack: 256,
+	Registry:  256 * 1024,
+}
+
+// LimitError is returned when a run goes over one of its limits
+type LimitError struct {
+	Limit  string // "time" or "memory"
+	Detail string
+}
+
+func (e *LimitError) Error() string {
+	return e.Limit + " limit exceeded: " + e.Detail
+}
+
+// IsLimit reports whether err is a LimitError
+func IsLimit(err error) bool {
+	var limit *LimitError
+	return errors.As(err, &limit)
+}
+
+// libraries a cartridge gets, os, io, debug, package and channel are left out
+var libs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+	{lua.CoroutineLibName, lua.OpenCoroutine},
+}
+
+// base functions that reach the filesystem or the module loader
+var removed = []string{"dofile", "loadfile", "require", "module"}
+
+// measureEvery is how many callbacks run between two walks over the cart's
+// values, a walk costs more the more the cart keeps. the live heap check
+// in the limiter covers the callbacks between.
+const measureEvery = 30
+
+type Sandbox struct {
+	L      *lua.LState
+	Limits Limits
+
+	limit *limiter
+	used  uint64 // measured at load and every measureEvery callbacks
+	calls int
+	fixed bool // see UseFixed
+}
+
+// New makes a fresh lua state with only the safe libraries loaded
+func New(limits Limits) *Sandbox {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   limits.CallStack,
+		RegistryMaxSize: limits.Registry,
+	})
+	for _, lib := range libs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range removed {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	s := &Sandbox{L: L, Limits: limits, limit: newLimiter()}
+	L.SetContext(s.limit)
+	// string.rep can ask for gigabytes in one call, before any check runs
+	if strlib, ok := L.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		L.SetField(strlib, "rep", L.NewFunction(s.stringRep))
+	}
+	// coroutines run under the same limiter, instead of a context of their
+	// own that nothing checks
+	if colib, ok := L.GetGlobal(lua.CoroutineLibName).(*lua.LTable); ok {
+		for _, name := range []string{"create", "wrap"} {
+			if fn, ok := L.GetField(colib, name).(*lua.LFunction); ok && fn.IsG {
+				L.SetField(colib, name, L.NewFunction(s.limitThread(fn.GFunction)))
+			}
+		}
+	}
+
+	return s
+}
+
+// limitThread wraps coroutine.create or wrap to put the new thread under
+// the sandbox's limiter
+func (s *Sandbox) limitThread(inner lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		n := inner(L)
+		v := L.Get(-1)
+		if fn, ok := v.(*lua.LFunction); ok && len(fn.Upvalues) > 0 {
+			// wrap keeps the thread in its first upvalue
+			v = fn.Upvalues[0].Value()
+		}
+		if th, ok := v.(*lua.LState); ok {
+			th.SetContext(s.limit)
+		}
+		return n
+	}
+}
+
+func (s *Sandbox) Close() {
+	s.L.Close()
+}
+
+func (s *Sandbox) stringRep(L *lua.LState) int {
+	str := L.CheckString(1)
+	n := L.CheckInt(2)
+	if n <= 0 || str == "" {
+		L.Push(lua.LString(""))
+		return 1
+	}
+	if s.Limits.Memory > 0 && uint64(len(str))*uint64(n) > s.Limits.Memory {
+		L.RaiseError("memory limit exceeded: string.rep result too large")
+	}
+	L.Push(lua.LString(strings.Repeat(str, n)))
+	return 1
+}
+
+// Do runs a chunk of code within the load time limit
+func (s *Sandbox) Do(src string) error {
+	err := s.run(s.Limits.LoadTime, "the cartridge took longer than %v to load", func() error {
+		fn, err := s.load(strings.NewReader(src), ChunkName)
+		if err != nil {
+			return err
+		}
+		s.L.Push(fn)
+		return s.L.PCall(0, lua.MultRet, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return s.checkMemory()
+}
+
+// Call calls a global function if it exists, within budget
+func (s *Sandbox) Call(name string, budget time.Duration) error {
+	fn := s.L.GetGlobal(name)
+	if fn.Type() != lua.LTFunction {
+		return nil
+	}
+	err := s.run(budget, name+" took longer than %v", func() error {
+		return s.L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true})
+	})
+	if err != nil {
+		return err
+	}
+	s.calls++
+	if s.calls%measureEvery != 0 {
+		return nil
+	}
+	return s.checkMemory()
+}
+
+func (s *Sandbox) run(budget time.Duration, timeout string, fn func() error) error {
+	s.limit.begin(budget, timeout, s.Limits.Memory)
+	err := fn()
+	if limit := s.limit.end(); limit != nil {
+		return limit
+	}
+	return err
+}
+
+// Used is how much the cartridge's lua values took when last measured
+func (s *Sandbox) Used() uint64 {
+	return s.used
+}
+
+func (s *Sandbox) checkMemory() error {
+	s.used = s.measure()
+	if s.Limits.Memory > 0 && s.used > s.Limits.Memory {
+		return memoryError(s.used, s.Limits.Memory)
+	}
+	return nil
+}
+
+func memoryError(used, limit uint64) *LimitError {
+	return &LimitError{
+		Limit:  "memory",
+		Detail: fmt.Sprintf("using %dKB, the limit is %dKB", used/1024, limit/1024),
+	}
+}
@@ -0,0 +1,254 @@
+package sandbox
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+var testLimits = Limits{
+	FrameTime: 50 * time.Millisecond,
+	LoadTime:  100 * time.Millisecond,
+	Memory:    16 << 20,
+	CallStack: 128,
+	Registry:  64 * 1024,
+}
+
+func TestRemovedGlobals(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+
+	tests := []string{"os", "io", "debug", "package", "channel", "loadfile", "dofile", "require", "module"}
+	for i, name := range tests {
+		if v := s.L.GetGlobal(name); v != lua.LNil {
+			t.Fatalf("tests[%d] - %s available. got=%s", i, name, v.Type())
+		}
+	}
+
+	kept := []string{"print", "pairs", "pcall", "string", "table", "math", "coroutine", "loadstring"}
+	for i, name := range kept {
+		if v := s.L.GetGlobal(name); v == lua.LNil {
+			t.Fatalf("kept[%d] - %s missing", i, name)
+		}
+	}
+}
+
+func TestFreshState(t *testing.T) {
+	s := New(testLimits)
+	if err := s.Do("leak = 1"); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	s.Close()
+
+	s = New(testLimits)
+	defer s.Close()
+	if v := s.L.GetGlobal("leak"); v != lua.LNil {
+		t.Fatalf("global leaked into a new state. got=%s", v)
+	}
+}
+
+func TestTimeLimit(t *testing.T) {
+	tests := []struct {
+		src  string
+		call string
+	}{
+		{"while true do end", ""},
+		{"function _update() while true do end end", "_update"},
+		// pcall can't swallow the timeout
+		{"function _update() while true do pcall(function() while true do end end) end end", "_update"},
+	}
+
+	for i, tt := range tests {
+		s := New(testLimits)
+		start := time.Now()
+		err := s.Do(tt.src)
+		if tt.call != "" {
+			if err != nil {
+				t.Fatalf("tests[%d] - Do() returned error: %v", i, err)
+			}
+			err = s.Call(tt.call, testLimits.FrameTime)
+		}
+		s.Close()
+
+		if !IsLimit(err) || !strings.HasPrefix(err.Error(), "time limit exceeded") {
+			t.Fatalf("tests[%d] - expected a time limit error, got=%v", i, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("tests[%d] - took too long to stop. got=%v", i, elapsed)
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+
+	err := s.Do(`
+		hoard = {}
+		function _update()
+			for i = 1, 100000 do
+				hoard[#hoard + 1] = {i}
+			end
+		end`)
+	if err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+
+	for frame := 0; frame < 200; frame++ {
+		if err = s.Call("_update", time.Second); err != nil {
+			break
+		}
+	}
+	if !IsLimit(err) || !strings.HasPrefix(err.Error(), "memory limit exceeded") {
+		t.Fatalf("expected a memory limit error, got=%v", err)
+	}
+}
+
+func TestMemoryLimitInsideCallback(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+
+	// never returns on its own, the watch has to stop it
+	if err := s.Do(`
+		function _update()
+			local t = {}
+			for i = 1, 1e9 do t[i] = {i} end
+		end`); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	start := time.Now()
+	err := s.Call("_update", time.Minute)
+	if !IsLimit(err) || !strings.HasPrefix(err.Error(), "memory limit exceeded") {
+		t.Fatalf("expected a memory limit error, got=%v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("took too long to stop. got=%v", elapsed)
+	}
+}
+
+func TestGarbageIsNotMemory(t *testing.T) {
+	limits := testLimits
+	limits.LoadTime = 10 * time.Second
+	s := New(limits)
+	defer s.Close()
+
+	// allocates far more than the limit in total, but keeps little of it
+	if err := s.Do(`local s = "" for i = 1, 20000 do s = s .. "x" end`); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+}
+
+func TestCoroutineAcrossCalls(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+
+	err := s.Do(`
+		function _init()
+			co = coroutine.create(function()
+				for i = 1, 3 do coroutine.yield(i) end
+			end)
+			gen = coroutine.wrap(function()
+				for i = 1, 3 do coroutine.yield(i) end
+			end)
+		end
+		function _update()
+			local ok, v = coroutine.resume(co)
+			if not ok then error(v) end
+			got = v + gen()
+		end`)
+	if err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	if err := s.Call("_init", testLimits.LoadTime); err != nil {
+		t.Fatalf("_init returned error: %v", err)
+	}
+	for frame := 1; frame <= 3; frame++ {
+		if err := s.Call("_update", testLimits.FrameTime); err != nil {
+			t.Fatalf("frame %d - _update returned error: %v", frame, err)
+		}
+		if got := s.L.GetGlobal("got"); got != lua.LNumber(2*frame) {
+			t.Fatalf("frame %d - got wrong. expected=%d, got=%v", frame, 2*frame, got)
+		}
+	}
+}
+
+func TestTimeLimitInsideCoroutine(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+
+	if err := s.Do(`
+		function _update()
+			coroutine.resume(coroutine.create(function() while true do end end))
+		end`); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	start := time.Now()
+	err := s.Call("_update", testLimits.FrameTime)
+	if !IsLimit(err) || !strings.HasPrefix(err.Error(), "time limit exceeded") {
+		t.Fatalf("expected a time limit error, got=%v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("took too long to stop. got=%v", elapsed)
+	}
+}
+
+func TestUsedCountsLuaValues(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+
+	if err := s.Do(`function _update() end`); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	empty := s.Used()
+	// memory outside of the vm doesn't count
+	outside := make([]byte, 8<<20)
+	if err := s.Call("_update", testLimits.FrameTime); err != nil {
+		t.Fatalf("Call() returned error: %v", err)
+	}
+	if s.Used() != empty {
+		t.Fatalf("used changed without lua allocating. expected=%d, got=%d", empty, s.Used())
+	}
+	_ = outside
+
+	if err := s.Do(`hoard = {} for i = 1, 1000 do hoard[i] = string.rep("x", 1000) .. i end`); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	if grew := s.Used() - empty; grew < 1000*1000 || grew > 2*1000*1000 {
+		t.Fatalf("used wrong. expected about 1MB more, got %d bytes more", grew)
+	}
+}
+
+func TestStringRep(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+
+	if err := s.Do(`x = string.rep("ab", 3)`); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	if got := s.L.GetGlobal("x").String(); got != "ababab" {
+		t.Fatalf("string.rep wrong. expected=%q, got=%q", "ababab", got)
+	}
+
+	err := s.Do(`x = ("x"):rep(1e12)`)
+	if err == nil || !strings.Contains(err.Error(), "memory limit exceeded") {
+		t.Fatalf("expected a memory error, got=%v", err)
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	s := New(testLimits)
+	defer s.Close()
+
+	if err := s.Do("function _draw() error('boom') end"); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	err := s.Call("_draw", testLimits.FrameTime)
+	if err == nil || IsLimit(err) || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected the script's error, got=%v", err)
+	}
+	if err := s.Call("_missing", testLimits.FrameTime); err != nil {
+		t.Fatalf("missing function returned error: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build !js
+
+package savedata
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Dir keeps each store in <dir>/<id>.txt
+type Dir string
+
+// DefaultBackend stores cartdata under the user config dir, in dofi/cartdata
+func DefaultBackend() (Backend, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return Dir(filepath.Join(config, "dofi", "cartdata")), nil
+}
+
+func (d Dir) path(id string) string {
+	return filepath.Join(string(d), id+".txt")
+}
+
+func (d Dir) Load(id string) ([]byte, error) {
+	data, err := os.ReadFile(d.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Save writes a temp file next to the store and renames it over, so a crash
+// mid write never leaves half a file
+func (d Dir) Save(id string, data []byte) error {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(string(d), id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path(id))
+}
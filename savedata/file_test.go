@@ -0,0 +1,38 @@
+//go:build !js
+
+package savedata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	dir := Dir(filepath.Join(t.TempDir(), "cartdata"))
+	s, err := Open(dir, "donut")
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	s.Set(1, 42)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	s.Set(1, 43)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("second flush failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(string(dir))
+	if err != nil {
+		t.Fatalf("read dir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "donut.txt" {
+		t.Fatalf("temp files left behind. got=%v", entries)
+	}
+
+	s, err = Open(dir, "donut")
+	if err != nil || s.Get(1) != 43 {
+		t.Fatalf("reopen wrong. err=%v, slot=%v", err, s.Get(1))
+	}
+}
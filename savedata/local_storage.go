@@ -0,0 +1,48 @@
+//go:build js
+
+package savedata
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// LocalStorage keeps each store in the browser's localStorage under
+// "dofi.cartdata.<id>". a single setItem replaces the value at once.
+type LocalStorage struct{}
+
+// DefaultBackend stores cartdata in localStorage
+func DefaultBackend() (Backend, error) {
+	if storage := js.Global().Get("localStorage"); storage.IsUndefined() || storage.IsNull() {
+		return nil, errors.New("localStorage is not available")
+	}
+	return LocalStorage{}, nil
+}
+
+func (LocalStorage) key(id string) string {
+	return "dofi.cartdata." + id
+}
+
+func (l LocalStorage) Load(id string) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("localStorage: cannot read " + id)
+		}
+	}()
+	value := js.Global().Get("localStorage").Call("getItem", l.key(id))
+	if value.IsNull() || value.IsUndefined() {
+		return nil, nil
+	}
+	return []byte(value.String()), nil
+}
+
+func (l LocalStorage) Save(id string, data []byte) (err error) {
+	// setItem throws when the quota is used up or storage is disabled
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("localStorage: cannot save " + id)
+		}
+	}()
+	js.Global().Get("localStorage").Call("setItem", l.key(id), string(data))
+	return nil
+}
@@ -0,0 +1,160 @@
+package savedata
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a cartridge can keep a few numbers between runs, like high scores. the
+// store is saved as plain text, one slot per line:
+//
+//	dofi cartdata
+//	0
+//	1250
+//	...
+
+const (
+	Header = "dofi cartdata"
+	Slots  = 64
+	MaxID  = 64
+)
+
+// SaveDelay is how long a change waits for FlushDue, so a cart that calls
+// dset every frame doesn't write the store every frame
+const SaveDelay = time.Second
+
+// Backend keeps the encoded stores, a file per id on desktop and
+// localStorage in the browser
+type Backend interface {
+	Load(id string) ([]byte, error) // nil data and no error when nothing was saved yet
+	Save(id string, data []byte) error
+}
+
+type Store struct {
+	ID     string
+	Slots  [Slots]float64
+	Loaded bool // the store had been saved before
+	Now    func() time.Time
+	Failed bool // the last save failed, FlushDue retries a SaveDelay later
+
+	backend Backend
+	dirty   bool
+	changed time.Time // the first change since the last save
+}
+
+// ValidID reports whether id can name a store, 1 to 64 of a-z, 0-9 and _
+func ValidID(id string) bool {
+	if id == "" || len(id) > MaxID {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Open loads the store called id, or an empty one if it was never saved
+func Open(backend Backend, id string) (*Store, error) {
+	if !ValidID(id) {
+		return nil, fmt.Errorf("invalid cartdata id %q, use up to %d of a-z, 0-9 and _", id, MaxID)
+	}
+	s := &Store{ID: id, Now: time.Now, backend: backend}
+	data, err := backend.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		if err := s.decode(data); err != nil {
+			return nil, err
+		}
+		s.Loaded = true
+	}
+	return s, nil
+}
+
+// Get is slot i, 0 when i is out of range
+func (s *Store) Get(i int) float64 {
+	if i < 0 || i >= Slots {
+		return 0
+	}
+	return s.Slots[i]
+}
+
+// Set changes slot i, it's written on the next Flush or a due FlushDue
+func (s *Store) Set(i int, v float64) {
+	if i < 0 || i >= Slots || s.Slots[i] == v {
+		return
+	}
+	s.Slots[i] = v
+	if !s.dirty {
+		s.changed = s.Now()
+	}
+	s.dirty = true
+}
+
+// Flush saves the store if anything changed since the last save
+func (s *Store) Flush() error {
+	if !s.dirty {
+		return nil
+	}
+	if err := s.backend.Save(s.ID, s.encode()); err != nil {
+		// wait again before retrying instead of every frame
+		s.changed = s.Now()
+		s.Failed = true
+		return err
+	}
+	s.dirty = false
+	s.Failed = false
+	return nil
+}
+
+// FlushDue saves the store once its first unsaved change is SaveDelay old
+func (s *Store) FlushDue() error {
+	if !s.dirty || s.Now().Sub(s.changed) < SaveDelay {
+		return nil
+	}
+	return s.Flush()
+}
+
+func (s *Store) encode() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(Header + "\n")
+	for _, v := range s.Slots {
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64) + "\n")
+	}
+	return buf.Bytes()
+}
+
+func (s *Store) decode(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != Header {
+		return fmt.Errorf("cartdata %s: not a cartdata file", s.ID)
+	}
+	// missing slots stay 0, so the store can grow later
+	for i := 0; i < Slots && scanner.Scan(); i++ {
+		v, err := strconv.ParseFloat(strings.TrimSpace(scanner.Text()), 64)
+		if err != nil {
+			return fmt.Errorf("cartdata %s: slot %d: %v", s.ID, i, err)
+		}
+		s.Slots[i] = v
+	}
+	return scanner.Err()
+}
+
+// Memory keeps stores in memory, for tests and when nothing else works
+type Memory map[string][]byte
+
+func (m Memory) Load(id string) ([]byte, error) {
+	return m[id], nil
+}
+
+func (m Memory) Save(id string, data []byte) error {
+	m[id] = append([]byte(nil), data...)
+	return nil
+}
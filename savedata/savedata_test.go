@@ -0,0 +1,143 @@
+package savedata
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected bool
+	}{
+		{"donut", true},
+		{"my_game_2", true},
+		{"", false},
+		{"Donut", false},
+		{"../etc", false},
+		{"a b", false},
+		{string(make([]byte, MaxID+1)), false},
+	}
+
+	for i, tt := range tests {
+		if got := ValidID(tt.id); got != tt.expected {
+			t.Fatalf("tests[%d] - ValidID(%q) wrong. expected=%t, got=%t", i, tt.id, tt.expected, got)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	backend := Memory{}
+	s, err := Open(backend, "donut")
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	s.Set(0, 1250)
+	s.Set(63, -0.5)
+	s.Set(64, 9) // ignored
+	if err := s.Flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	s, err = Open(backend, "donut")
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	tests := []struct {
+		slot     int
+		expected float64
+	}{
+		{0, 1250},
+		{1, 0},
+		{63, -0.5},
+		{64, 0},
+		{-1, 0},
+	}
+	for i, tt := range tests {
+		if got := s.Get(tt.slot); got != tt.expected {
+			t.Fatalf("tests[%d] - slot %d wrong. expected=%v, got=%v", i, tt.slot, tt.expected, got)
+		}
+	}
+
+	if _, err := Open(backend, "Bad ID"); err == nil {
+		t.Fatalf("expected an error for an invalid id")
+	}
+	backend["broken"] = []byte("not cartdata\n")
+	if _, err := Open(backend, "broken"); err == nil {
+		t.Fatalf("expected an error for a broken store")
+	}
+}
+
+func TestFlushOnlyWhenChanged(t *testing.T) {
+	backend := Memory{}
+	s, _ := Open(backend, "scores")
+	s.Set(3, 0)
+	s.Flush()
+	if _, saved := backend["scores"]; saved {
+		t.Fatalf("store saved without changes")
+	}
+	s.Set(3, 7)
+	s.Flush()
+	if _, saved := backend["scores"]; !saved {
+		t.Fatalf("store not saved after a change")
+	}
+}
+
+func TestFlushDue(t *testing.T) {
+	backend := Memory{}
+	s, _ := Open(backend, "scores")
+	now := time.Unix(0, 0)
+	s.Now = func() time.Time { return now }
+
+	// a change every frame for a while is saved once a SaveDelay in
+	saves := 0
+	for frame := 0; frame < 90; frame++ {
+		s.Set(0, float64(frame+1))
+		now = now.Add(time.Second / 60)
+		delete(backend, "scores")
+		s.FlushDue()
+		if _, saved := backend["scores"]; saved {
+			saves++
+		}
+	}
+	if saves != 1 {
+		t.Fatalf("saves wrong. expected=1, got=%d", saves)
+	}
+
+	// Flush doesn't wait
+	s.Set(0, -1)
+	s.Flush()
+	if _, saved := backend["scores"]; !saved {
+		t.Fatalf("store not saved by Flush")
+	}
+}
+
+type failing struct{ saves int }
+
+func (f *failing) Load(id string) ([]byte, error) { return nil, nil }
+func (f *failing) Save(id string, data []byte) error {
+	f.saves++
+	return errors.New("disk full")
+}
+
+func TestFlushDueAfterFailure(t *testing.T) {
+	backend := &failing{}
+	s, _ := Open(backend, "scores")
+	now := time.Unix(0, 0)
+	s.Now = func() time.Time { return now }
+
+	// a failed save is retried a SaveDelay later, not every frame
+	s.Set(0, 1)
+	for frame := 0; frame < 150; frame++ {
+		now = now.Add(time.Second / 60)
+		s.FlushDue()
+	}
+	expected := int(150 * time.Second / 60 / SaveDelay)
+	if backend.saves != expected {
+		t.Fatalf("saves wrong. expected=%d, got=%d", expected, backend.saves)
+	}
+	if !s.Failed {
+		t.Fatalf("store not marked failed")
+	}
+}
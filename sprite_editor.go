@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/mrdapoyo/dofi/gfx"
+)
+
+type SpriteTool int
+
+const (
+	ToolPencil SpriteTool = iota
+	ToolFill
+	ToolLine
+	ToolRect
+	ToolSelect
+)
+
+var spriteTools = []struct {
+	Label string
+	Key   ebiten.Key
+}{
+	{"p", ebiten.KeyP},
+	{"f", ebiten.KeyF},
+	{"l", ebiten.KeyL},
+	{"r", ebiten.KeyR},
+	{"s", ebiten.KeyS},
+}
+
+const MaxSpriteUndo = 64
+
+// layout of the draw tab, in content coordinates
+var (
+	spriteCanvasRect  = image.Rect(2, 2, 66, 66)
+	spritePaletteRect = image.Rect(70, 2, 102, 34)
+	spriteFlagsRect   = image.Rect(70, 36, 102, 40)
+	spriteToolsRect   = image.Rect(70, 44, 120, 53)
+	spriteZoomRect    = image.Rect(104, 12, 122, 20)
+	spritePagesRect   = image.Rect(2, 72, 50, 80)
+	spriteSheetRect   = image.Rect(0, 84, 128, 116)
+)
+
+const spritePageRows = 4 // rows of sprites in the sheet strip
+
+type SpriteEditor struct {
+	Sprite int
+	Color  uint8
+	Tool   SpriteTool
+	Zoom   int // sprites per side shown on the canvas: 1, 2 or 4
+	Page   int
+
+	HasSelection bool
+	Selection    image.Rectangle // sheet pixels
+
+	drawing        bool
+	startX, startY int
+	lastX, lastY   int
+
+	copied     []uint8
+	copiedW    int
+	copiedH    int
+	undo, redo []spriteSnapshot
+	drawState  gfx.DrawState
+}
+
+type spriteSnapshot struct {
+	pixels []uint8
+	flags  []uint8
+}
+
+func NewSpriteEditor() SpriteEditor {
+	return SpriteEditor{
+		Color:     8,
+		Zoom:      1,
+		drawState: gfx.NewDrawState(),
+	}
+}
+
+// View is the part of the sheet shown on the canvas
+func (e *SpriteEditor) View() image.Rectangle {
+	size := e.Zoom * gfx.SpriteSize
+	x, y := gfx.SpriteOrigin(e.Sprite)
+	x = min(x, gfx.SheetWidth-size)
+	y = min(y, gfx.SheetHeight-size)
+	return image.Rect(x, y, x+size, y+size)
+}
+
+func (e *SpriteEditor) scale() int {
+	return spriteCanvasRect.Dx() / e.View().Dx()
+}
+
+// maps content coordinates to sheet pixels, clamped to the view
+func (e *SpriteEditor) canvasToSheet(x, y int) (int, int) {
+	view := e.View()
+	s := e.scale()
+	sx := view.Min.X + (x-spriteCanvasRect.Min.X)/s
+	sy := view.Min.Y + (y-spriteCanvasRect.Min.Y)/s
+	return min(max(sx, view.Min.X), view.Max.X-1), min(max(sy, view.Min.Y), view.Max.Y-1)
+}
+
+func (e *SpriteEditor) canvas(sheet *gfx.SpriteSheet) gfx.Canvas {
+	view := e.View()
+	e.drawState.Reset()
+	e.drawState.Clip(view.Min.X, view.Min.Y, view.Dx(), view.Dy())
+	return gfx.Canvas{FB: sheet.Pixels, State: &e.drawState}
+}
+
+func (e *SpriteEditor) snapshot(sheet *gfx.SpriteSheet) spriteSnapshot {
+	return spriteSnapshot{
+		pixels: append([]uint8(nil), sheet.Pixels.Pix...),
+		flags:  append([]uint8(nil), sheet.Flags...),
+	}
+}
+
+func (e *SpriteEditor) restore(sheet *gfx.SpriteSheet, s spriteSnapshot) {
+	copy(sheet.Pixels.Pix, s.pixels)
+	copy(sheet.Flags, s.flags)
+}
+
+// PushUndo remembers the sheet before a change
+func (e *SpriteEditor) PushUndo(sheet *gfx.SpriteSheet) {
+	e.undo = append(e.undo, e.snapshot(sheet))
+	if len(e.undo) > MaxSpriteUndo {
+		e.undo = e.undo[1:]
+	}
+	e.redo = nil
+}
+
+func (e *SpriteEditor) Undo(sheet *gfx.SpriteSheet) {
+	if len(e.undo) == 0 {
+		return
+	}
+	e.redo = append(e.redo, e.snapshot(sheet))
+	e.restore(sheet, e.undo[len(e.undo)-1])
+	e.undo = e.undo[:len(e.undo)-1]
+}
+
+func (e *SpriteEditor) Redo(sheet *gfx.SpriteSheet) {
+	if len(e.redo) == 0 {
+		return
+	}
+	e.undo = append(e.undo, e.snapshot(sheet))
+	e.restore(sheet, e.redo[len(e.redo)-1])
+	e.redo = e.redo[:len(e.redo)-1]
+}
+
+func (e *SpriteEditor) SelectSprite(n int) {
+	e.Sprite = (n%gfx.SpriteCount + gfx.SpriteCount) % gfx.SpriteCount
+	e.Page = e.Sprite / gfx.SpritesPerRow / spritePageRows
+}
+
+func (e *SpriteEditor) Copy(sheet *gfx.SpriteSheet) {
+	r := e.Selection
+	if !e.HasSelection {
+		r = e.View()
+	}
+	e.copiedW, e.copiedH = r.Dx(), r.Dy()
+	e.copied = make([]uint8, 0, r.Dx()*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			e.copied = append(e.copied, sheet.Pixels.Get(x, y))
+		}
+	}
+}
+
+// Paste puts the copied pixels at the selection, or at the current sprite
+func (e *SpriteEditor) Paste(sheet *gfx.SpriteSheet) {
+	if e.copied == nil {
+		return
+	}
+	e.PushUndo(sheet)
+	x0, y0 := e.View().Min.X, e.View().Min.Y
+	if e.HasSelection {
+		x0, y0 = e.Selection.Min.X, e.Selection.Min.Y
+	}
+	for y := 0; y < e.copiedH; y++ {
+		for x := 0; x < e.copiedW; x++ {
+			sheet.Pixels.Set(x0+x, y0+y, e.copied[y*e.copiedW+x])
+		}
+	}
+}
+
+func (e *SpriteEditor) ClearSelection(sheet *gfx.SpriteSheet) {
+	r := e.Selection
+	if !e.HasSelection {
+		r = e.View()
+	}
+	e.PushUndo(sheet)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sheet.Pixels.Set(x, y, 0)
+		}
+	}
+}
+
+func (e *SpriteEditor) startStroke(sheet *gfx.SpriteSheet, x, y int) {
+	switch e.Tool {
+	case ToolSelect:
+		e.HasSelection = true
+		e.Selection = image.Rect(x, y, x+1, y+1)
+	case ToolFill:
+		view := e.View()
+		e.PushUndo(sheet)
+		gfx.FloodFill(sheet.Pixels, x, y, e.Color, view.Min.X, view.Min.Y, view.Max.X, view.Max.Y)
+		return
+	default:
+		e.PushUndo(sheet)
+	}
+	e.drawing = true
+	e.startX, e.startY = x, y
+	e.lastX, e.lastY = x, y
+	e.continueStroke(sheet, x, y)
+}
+
+func (e *SpriteEditor) continueStroke(sheet *gfx.SpriteSheet, x, y int) {
+	c := e.canvas(sheet)
+	switch e.Tool {
+	case ToolPencil:
+		c.Line(e.lastX, e.lastY, x, y, e.Color)
+	case ToolLine, ToolRect:
+		// redraw the preview on top of the sheet as it was before the drag
+		e.restore(sheet, e.undo[len(e.undo)-1])
+		if e.Tool == ToolLine {
+			c.Line(e.startX, e.startY, x, y, e.Color)
+		} else {
+			c.Rect(e.startX, e.startY, x, y, e.Color)
+		}
+	case ToolSelect:
+		e.Selection = image.Rect(min(e.startX, x), min(e.startY, y), max(e.startX, x)+1, max(e.startY, y)+1)
+	}
+	e.lastX, e.lastY = x, y
+}
+
+func (g *Game) UpdateSpriteEditor() error {
+	e := &g.SpriteEditor
+	sheet := g.Sprites
+	mx, my := g.contentMouse()
+	mouse := image.Pt(mx, my)
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+
+	if ctrl {
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyZ):
+			e.Undo(sheet)
+		case inpututil.IsKeyJustPressed(ebiten.KeyY):
+			e.Redo(sheet)
+		case inpututil.IsKeyJustPressed(ebiten.KeyC):
+			e.Copy(sheet)
+		case inpututil.IsKeyJustPressed(ebiten.KeyV):
+			e.Paste(sheet)
+		}
+	} else {
+		for i, tool := range spriteTools {
+			if inpututil.IsKeyJustPressed(tool.Key) {
+				e.Tool = SpriteTool(i)
+			}
+		}
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+			e.SelectSprite(e.Sprite - 1)
+		case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+			e.SelectSprite(e.Sprite + 1)
+		case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+			e.SelectSprite(e.Sprite - gfx.SpritesPerRow)
+		case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+			e.SelectSprite(e.Sprite + gfx.SpritesPerRow)
+		case inpututil.IsKeyJustPressed(ebiten.KeyDelete):
+			e.ClearSelection(sheet)
+		}
+	}
+
+	// mouse wheel zooms the canvas
+	if _, wheel := ebiten.Wheel(); wheel > 0 && e.Zoom > 1 {
+		e.Zoom /= 2
+	} else if wheel < 0 && e.Zoom < 4 {
+		e.Zoom *= 2
+	}
+
+	if e.drawing {
+		x, y := e.canvasToSheet(mx, my)
+		if g.Input.IsMouseDown {
+			e.continueStroke(sheet, x, y)
+		} else {
+			e.drawing = false
+		}
+		return nil
+	}
+
+	// right click on the canvas picks a color
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) && mouse.In(spriteCanvasRect) {
+		e.Color = sheet.Pixels.Get(e.canvasToSheet(mx, my))
+	}
+
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return nil
+	}
+
+	switch {
+	case mouse.In(spriteCanvasRect):
+		x, y := e.canvasToSheet(mx, my)
+		if e.Tool != ToolSelect {
+			e.HasSelection = false
+		}
+		e.startStroke(sheet, x, y)
+	case mouse.In(spritePaletteRect):
+		col := (my-spritePaletteRect.Min.Y)/8*4 + (mx-spritePaletteRect.Min.X)/8
+		e.Color = uint8(col)
+	case mouse.In(spriteFlagsRect):
+		flag := (mx - spriteFlagsRect.Min.X) / 4
+		e.PushUndo(sheet)
+		sheet.SetFlag(e.Sprite, flag, !sheet.Flag(e.Sprite, flag))
+	case mouse.In(spriteToolsRect):
+		if tool := (mx - spriteToolsRect.Min.X) / 10; tool < len(spriteTools) {
+			e.Tool = SpriteTool(tool)
+		}
+	case mouse.In(spriteZoomRect):
+		e.Zoom *= 2
+		if e.Zoom > 4 {
+			e.Zoom = 1
+		}
+	case mouse.In(spritePagesRect):
+		e.Page = min((mx-spritePagesRect.Min.X)/12, gfx.SheetHeight/gfx.SpriteSize/spritePageRows-1)
+	case mouse.In(spriteSheetRect):
+		x := mx - spriteSheetRect.Min.X
+		y := my - spriteSheetRect.Min.Y + e.Page*spritePageRows*gfx.SpriteSize
+		e.Sprite = gfx.SpriteAt(x, y)
+		e.HasSelection = false
+	}
+	return nil
+}
+
+func (g *Game) DrawSpriteEditor(screen *ebiten.Image) {
+	e := &g.SpriteEditor
+	sheet := g.Sprites
+	screen.Fill(g.Screen.CliBgColor)
+	white := g.Screen.Palette[7]
+	grey := g.Screen.Palette[5]
+
+	// canvas
+	view := e.View()
+	s := e.scale()
+	canvasOp := &ebiten.DrawImageOptions{}
+	canvasOp.GeoM.Scale(float64(s), float64(s))
+	canvasOp.GeoM.Translate(float64(spriteCanvasRect.Min.X), float64(spriteCanvasRect.Min.Y))
+	screen.DrawImage(g.indexedImage(sheet.Pixels, view), canvasOp)
+	strokeRect(screen, spriteCanvasRect.Inset(-1), grey)
+
+	if e.HasSelection {
+		sel := e.Selection.Intersect(view)
+		if !sel.Empty() {
+			r := image.Rect(
+				spriteCanvasRect.Min.X+(sel.Min.X-view.Min.X)*s,
+				spriteCanvasRect.Min.Y+(sel.Min.Y-view.Min.Y)*s,
+				spriteCanvasRect.Min.X+(sel.Max.X-view.Min.X)*s,
+				spriteCanvasRect.Min.Y+(sel.Max.Y-view.Min.Y)*s,
+			)
+			if CursorBlinkFrames < CursorBlinkRate {
+				strokeRect(screen, r, white)
+			} else {
+				strokeRect(screen, r, color.Black)
+			}
+		}
+	}
+
+	// palette
+	for i := 0; i < gfx.PaletteSize; i++ {
+		r := image.Rect(0, 0, 8, 8).Add(spritePaletteRect.Min).Add(image.Pt(i%4*8, i/4*8))
+		fillRect(screen, r, g.Screen.Palette[i])
+		if uint8(i) == e.Color {
+			strokeRect(screen, r, white)
+			strokeRect(screen, r.Inset(1), color.Black)
+		}
+	}
+
+	drawLabel(screen, 104, 3, fmt.Sprintf("#%03d", e.Sprite), white)
+	strokeRect(screen, spriteZoomRect, grey)
+	drawLabel(screen, spriteZoomRect.Min.X+2, spriteZoomRect.Min.Y+2, fmt.Sprintf("%dx%d", view.Dx(), view.Dy()), white)
+
+	// flags
+	for f := 0; f < gfx.SpriteFlags; f++ {
+		r := image.Rect(0, 0, 3, 3).Add(spriteFlagsRect.Min).Add(image.Pt(f*4, 0))
+		if sheet.Flag(e.Sprite, f) {
+			fillRect(screen, r, g.Screen.Palette[8+f])
+		} else {
+			fillRect(screen, r, grey)
+		}
+	}
+
+	// tools
+	for i, tool := range spriteTools {
+		r := image.Rect(0, 0, 9, 9).Add(spriteToolsRect.Min).Add(image.Pt(i*10, 0))
+		if SpriteTool(i) == e.Tool {
+			fillRect(screen, r, g.Navbar.TabColor)
+		} else {
+			fillRect(screen, r, grey)
+		}
+		drawLabel(screen, r.Min.X+3, r.Min.Y+2, tool.Label, white)
+	}
+
+	// sheet pages
+	for p := 0; p < gfx.SheetHeight/gfx.SpriteSize/spritePageRows; p++ {
+		r := image.Rect(0, 0, 10, 8).Add(spritePagesRect.Min).Add(image.Pt(p*12, 0))
+		if p == e.Page {
+			fillRect(screen, r, g.Navbar.TabColor)
+		} else {
+			fillRect(screen, r, grey)
+		}
+		drawLabel(screen, r.Min.X+3, r.Min.Y+2, fmt.Sprint(p), white)
+	}
+
+	pageY := e.Page * spritePageRows * gfx.SpriteSize
+	strip := image.Rect(0, pageY, gfx.SheetWidth, pageY+spriteSheetRect.Dy())
+	stripOp := &ebiten.DrawImageOptions{}
+	stripOp.GeoM.Translate(float64(spriteSheetRect.Min.X), float64(spriteSheetRect.Min.Y))
+	screen.DrawImage(g.indexedImage(sheet.Pixels, strip), stripOp)
+
+	// outline the sprites shown on the canvas
+	outline := view.Add(image.Pt(spriteSheetRect.Min.X, spriteSheetRect.Min.Y-pageY)).Inset(-1)
+	strokeRect(screen, outline.Intersect(spriteSheetRect.Inset(-1)), white)
+}
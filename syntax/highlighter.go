@@ -0,0 +1,81 @@
+package syntax
+
+import (
+	"image/color"
+
+	"github.com/mrdapoyo/dofi/gfx"
+)
+
+// Theme is the color of each kind of token
+type Theme [Kinds]color.RGBA
+
+var DefaultTheme = Theme{
+	Plain:    gfx.DefaultPalette[7],
+	Keyword:  gfx.DefaultPalette[14],
+	String:   gfx.DefaultPalette[10],
+	Number:   gfx.DefaultPalette[9],
+	Comment:  gfx.DefaultPalette[6],
+	API:      gfx.DefaultPalette[11],
+	Operator: gfx.DefaultPalette[15],
+}
+
+type line struct {
+	done   bool // tokenized, false for lines align made room for
+	text   string
+	in     State
+	out    State
+	tokens []Token
+}
+
+// Highlighter keeps the tokens of every line of a buffer. Update only
+// retokenizes lines whose text changed, or whose start state changed
+// because a line above opened or closed a long comment.
+type Highlighter struct {
+	lines       []line
+	Retokenized int // lines tokenized by the last Update
+}
+
+func (h *Highlighter) Update(content []string) {
+	h.Retokenized = 0
+	h.align(content)
+	var state State
+	for i, text := range content {
+		if l := h.lines[i]; l.done && l.text == text && l.in == state {
+			state = h.lines[i].out
+			continue
+		}
+		tokens, out := Line(text, state)
+		h.lines[i] = line{done: true, text: text, in: state, out: out, tokens: tokens}
+		h.Retokenized++
+		state = out
+	}
+}
+
+// align lines up the cache with content after lines were added or removed,
+// keeping the unchanged lines at the start and end
+func (h *Highlighter) align(content []string) {
+	if len(h.lines) == len(content) {
+		return
+	}
+	prefix := 0
+	for prefix < len(h.lines) && prefix < len(content) && h.lines[prefix].text == content[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(h.lines)-prefix && suffix < len(content)-prefix &&
+		h.lines[len(h.lines)-1-suffix].text == content[len(content)-1-suffix] {
+		suffix++
+	}
+	lines := make([]line, len(content))
+	copy(lines, h.lines[:prefix])
+	copy(lines[len(content)-suffix:], h.lines[len(h.lines)-suffix:])
+	h.lines = lines
+}
+
+// Tokens is line i as of the last Update
+func (h *Highlighter) Tokens(i int) []Token {
+	if i < 0 || i >= len(h.lines) {
+		return nil
+	}
+	return h.lines[i].tokens
+}
@@ -0,0 +1,64 @@
+package syntax
+
+import "testing"
+
+func TestIncrementalUpdate(t *testing.T) {
+	content := []string{
+		"function _draw()",
+		"  cls()",
+		"  print(\"hi\")",
+		"end",
+	}
+	var h Highlighter
+	h.Update(content)
+	if h.Retokenized != 4 {
+		t.Fatalf("first update wrong. expected=4, got=%d", h.Retokenized)
+	}
+
+	tests := []struct {
+		name        string
+		edit        func([]string) []string
+		retokenized int
+	}{
+		{"nothing changed", func(c []string) []string { return c }, 0},
+		{"one line edited", func(c []string) []string {
+			c[1] = "  cls(1)"
+			return c
+		}, 1},
+		{"line inserted", func(c []string) []string {
+			return append(c[:2], append([]string{"  x = 1"}, c[2:]...)...)
+		}, 1},
+		{"line deleted", func(c []string) []string {
+			return append(c[:2], c[3:]...)
+		}, 0},
+		// opening a long comment changes every line below it
+		{"comment opened", func(c []string) []string {
+			c[0] = "--[[ function _draw()"
+			return c
+		}, 4},
+		{"comment closed", func(c []string) []string {
+			c[1] = "  cls(1) ]]"
+			return c
+		}, 3},
+	}
+
+	for i, tt := range tests {
+		content = tt.edit(append([]string(nil), content...))
+		h.Update(content)
+		if h.Retokenized != tt.retokenized {
+			t.Fatalf("tests[%d] - %s: retokenized wrong. expected=%d, got=%d", i, tt.name, tt.retokenized, h.Retokenized)
+		}
+	}
+
+	// the cache matches tokenizing from scratch
+	var fresh Highlighter
+	fresh.Update(content)
+	for i := range content {
+		if got, expected := describe(content[i], h.Tokens(i)), describe(content[i], fresh.Tokens(i)); got != expected {
+			t.Fatalf("line %d wrong.\nexpected=%s\ngot=     %s", i, expected, got)
+		}
+	}
+	if h.Tokens(-1) != nil || h.Tokens(len(content)) != nil {
+		t.Fatalf("tokens outside of the buffer should be nil")
+	}
+}
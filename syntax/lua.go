@@ -0,0 +1,229 @@
+package syntax
+
+import "strings"
+
+// a small lua tokenizer for the code editor. it works a line at a time and
+// hands a State to the next line, so long comments and long strings can
+// span lines and an edit only retokenizes from the changed line on.
+
+type Kind int
+
+const (
+	Plain Kind = iota // identifiers and whitespace
+	Keyword
+	String
+	Number
+	Comment
+	API // dofi.* calls
+	Operator
+
+	Kinds
+)
+
+// Token is a run of line[Start:End] of one kind
+type Token struct {
+	Start int
+	End   int
+	Kind  Kind
+}
+
+// State is what's still open at the end of a line
+type State struct {
+	Kind  Kind // Plain, or Comment or String inside a long bracket
+	Level int  // number of = in the long bracket, [==[ is 2
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true,
+	"or": true, "repeat": true, "return": true, "then": true, "true": true,
+	"until": true, "while": true,
+}
+
+// the api table, dofi.name is colored as one token
+const apiTable = "dofi"
+
+// longest operators first so .. isn't read as two dots
+var operators = []string{
+	"...", "..", "==", "~=", "<=", ">=", "<<", ">>", "//", "::",
+	"+", "-", "*", "/", "%", "^", "#", "&", "~", "|", "<", ">", "=",
+	"(", ")", "{", "}", "[", "]", ";", ":", ",", ".",
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// longBracket reports the level of a [[ or [=[ opening at line[i:]
+func longBracket(line string, i int) (level int, ok bool) {
+	if i >= len(line) || line[i] != '[' {
+		return 0, false
+	}
+	j := i + 1
+	for j < len(line) && line[j] == '=' {
+		j++
+	}
+	if j < len(line) && line[j] == '[' {
+		return j - i - 1, true
+	}
+	return 0, false
+}
+
+// closeLong finds the end of ]=] of the given level from i, -1 if the line
+// doesn't close it
+func closeLong(line string, i, level int) int {
+	closing := "]" + strings.Repeat("=", level) + "]"
+	if n := strings.Index(line[i:], closing); n >= 0 {
+		return i + n + len(closing)
+	}
+	return -1
+}
+
+// Line splits one line into tokens, starting inside whatever in left open
+func Line(line string, in State) ([]Token, State) {
+	var tokens []Token
+	add := func(start, end int, kind Kind) {
+		// neighbours of one kind merge, so drawing needs fewer runs
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind && tokens[n-1].End == start {
+			tokens[n-1].End = end
+			return
+		}
+		tokens = append(tokens, Token{start, end, kind})
+	}
+
+	i := 0
+	if in.Kind != Plain {
+		end := closeLong(line, 0, in.Level)
+		if end < 0 {
+			if line != "" {
+				add(0, len(line), in.Kind)
+			}
+			return tokens, in
+		}
+		add(0, end, in.Kind)
+		i = end
+	}
+
+	for i < len(line) {
+		c := line[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t':
+			for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+				i++
+			}
+			add(start, i, Plain)
+
+		case strings.HasPrefix(line[i:], "--"):
+			if level, ok := longBracket(line, i+2); ok {
+				end := closeLong(line, i+4+level, level)
+				if end < 0 {
+					add(start, len(line), Comment)
+					return tokens, State{Comment, level}
+				}
+				add(start, end, Comment)
+				i = end
+				continue
+			}
+			add(start, len(line), Comment)
+			return tokens, State{}
+
+		case c == '[':
+			level, ok := longBracket(line, i)
+			if !ok {
+				i++
+				add(start, i, Operator)
+				continue
+			}
+			end := closeLong(line, i+2+level, level)
+			if end < 0 {
+				add(start, len(line), String)
+				return tokens, State{String, level}
+			}
+			add(start, end, String)
+			i = end
+
+		case c == '"' || c == '\'':
+			i++
+			for i < len(line) && line[i] != c {
+				if line[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			// an unfinished string stops at the end of the line
+			i = min(i+1, len(line))
+			add(start, i, String)
+
+		case isDigit(c) || c == '.' && i+1 < len(line) && isDigit(line[i+1]):
+			i = number(line, i)
+			add(start, i, Number)
+
+		case isLetter(c):
+			for i < len(line) && (isLetter(line[i]) || isDigit(line[i])) {
+				i++
+			}
+			word := line[start:i]
+			switch {
+			case keywords[word]:
+				add(start, i, Keyword)
+			case word == apiTable && i+1 < len(line) && line[i] == '.' && isLetter(line[i+1]):
+				i++
+				for i < len(line) && (isLetter(line[i]) || isDigit(line[i])) {
+					i++
+				}
+				add(start, i, API)
+			default:
+				add(start, i, Plain)
+			}
+
+		default:
+			kind := Plain // anything lua wouldn't accept
+			i++
+			for _, op := range operators {
+				if strings.HasPrefix(line[start:], op) {
+					kind = Operator
+					i = start + len(op)
+					break
+				}
+			}
+			add(start, i, kind)
+		}
+	}
+	return tokens, State{}
+}
+
+// number reads a decimal or hex number, with fraction and exponent
+func number(line string, i int) int {
+	digit, exponent := isDigit, "eE"
+	if strings.HasPrefix(line[i:], "0x") || strings.HasPrefix(line[i:], "0X") {
+		digit, exponent = isHex, "pP"
+		i += 2
+	}
+	for i < len(line) && (digit(line[i]) || line[i] == '.') {
+		// .. after a number is concatenation
+		if line[i] == '.' && i+1 < len(line) && line[i+1] == '.' {
+			return i
+		}
+		i++
+	}
+	if i < len(line) && strings.IndexByte(exponent, line[i]) >= 0 {
+		i++
+		if i < len(line) && (line[i] == '+' || line[i] == '-') {
+			i++
+		}
+		for i < len(line) && isDigit(line[i]) {
+			i++
+		}
+	}
+	return i
+}
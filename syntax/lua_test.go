@@ -0,0 +1,96 @@
+package syntax
+
+import (
+	"strings"
+	"testing"
+)
+
+var kindNames = [Kinds]string{"plain", "keyword", "string", "number", "comment", "api", "op"}
+
+// describe writes tokens as kind:text pairs, skipping whitespace
+func describe(line string, tokens []Token) string {
+	var parts []string
+	for _, t := range tokens {
+		// whitespace merges into plain runs, trim it off
+		text := strings.TrimSpace(line[t.Start:t.End])
+		if text == "" {
+			continue
+		}
+		parts = append(parts, kindNames[t.Kind]+":"+text)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"local x = 10", "keyword:local plain:x op:= number:10"},
+		{"dofi.pset(x, y, 7)", "api:dofi.pset op:( plain:x op:, plain:y op:, number:7 op:)"},
+		{"dofi = 1", "plain:dofi op:= number:1"},
+		{"s = 'it\\'s' .. \"ok\"", "plain:s op:= string:'it\\'s' op:.. string:\"ok\""},
+		{"x = 1..2", "plain:x op:= number:1 op:.. number:2"},
+		{"n = 0x1F + 3.5e-2 + .5", "plain:n op:= number:0x1F op:+ number:3.5e-2 op:+ number:.5"},
+		{"if a ~= b then -- note", "keyword:if plain:a op:~= plain:b keyword:then comment:-- note"},
+		{"t[i] = [[long]]", "plain:t op:[ plain:i op:] op:= string:[[long]]"},
+		{"--[[ short ]] x", "comment:--[[ short ]] plain:x"},
+		{"s = \"unfinished", "plain:s op:= string:\"unfinished"},
+		{"a // b >> 2", "plain:a op:// plain:b op:>> number:2"},
+		{"functional end_", "plain:functional end_"},
+		{"x = @", "plain:x op:= plain:@"},
+	}
+
+	for i, tt := range tests {
+		tokens, state := Line(tt.input, State{})
+		if got := describe(tt.input, tokens); got != tt.expected {
+			t.Fatalf("tests[%d] - tokens wrong.\nexpected=%s\ngot=     %s", i, tt.expected, got)
+		}
+		if state != (State{}) {
+			t.Fatalf("tests[%d] - state left open. got=%+v", i, state)
+		}
+	}
+}
+
+func TestTokensCoverLine(t *testing.T) {
+	line := "  for i=1,#t do dofi.spr(t[i], 8*i, 0) end -- draw"
+	tokens, _ := Line(line, State{})
+	pos := 0
+	for i, tok := range tokens {
+		if tok.Start != pos || tok.End <= tok.Start {
+			t.Fatalf("tokens[%d] wrong. expected start=%d, got=%+v", i, pos, tok)
+		}
+		pos = tok.End
+	}
+	if pos != len(line) {
+		t.Fatalf("tokens end at %d, line is %d long", pos, len(line))
+	}
+}
+
+func TestMultiLine(t *testing.T) {
+	tests := []struct {
+		input    string
+		in       State
+		expected string
+		out      State
+	}{
+		{"x = 1 --[[ starts", State{}, "plain:x op:= number:1 comment:--[[ starts", State{Comment, 0}},
+		{"still a comment", State{Comment, 0}, "comment:still a comment", State{Comment, 0}},
+		{"", State{Comment, 0}, "", State{Comment, 0}},
+		{"ends ]] y = 2", State{Comment, 0}, "comment:ends ]] plain:y op:= number:2", State{}},
+		{"s = [==[ text", State{}, "plain:s op:= string:[==[ text", State{String, 2}},
+		{"]] not yet ]=]", State{String, 2}, "string:]] not yet ]=]", State{String, 2}},
+		{"done ]==] .. x", State{String, 2}, "string:done ]==] op:.. plain:x", State{}},
+		{"--[=[ ]] ]=]", State{}, "comment:--[=[ ]] ]=]", State{}},
+	}
+
+	for i, tt := range tests {
+		tokens, out := Line(tt.input, tt.in)
+		if got := describe(tt.input, tokens); got != tt.expected {
+			t.Fatalf("tests[%d] - tokens wrong.\nexpected=%s\ngot=     %s", i, tt.expected, got)
+		}
+		if out != tt.out {
+			t.Fatalf("tests[%d] - state wrong. expected=%+v, got=%+v", i, tt.out, out)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package synth
+
+// sound data is kept in the same packed bytes the cartridge stores, so the
+// editors, the synth and the cartridge all look at one copy.
+//
+// an sfx is 32 notes of 2 bytes followed by speed, loop start, loop end and a
+// reserved byte. a note packs pitch (6 bits), waveform (3), volume (3) and
+// effect (3). a music pattern is one byte per channel: sfx index (6 bits),
+// bit 6 mutes the channel, and bit 7 of the first three bytes marks loop
+// start, loop end and stop.
+
+const (
+	SFXCount  = 64
+	NoteCount = 32
+	SFXSize   = NoteCount*2 + 4
+
+	PatternCount = 64
+	Channels     = 4
+	PatternSize  = Channels
+
+	DefaultSpeed = 16
+)
+
+const (
+	WaveTriangle = iota
+	WaveTiltedSaw
+	WaveSaw
+	WaveSquare
+	WavePulse
+	WaveOrgan
+	WaveNoise
+	WavePhaser
+)
+
+const (
+	EffectNone = iota
+	EffectSlide
+	EffectVibrato
+	EffectDrop
+	EffectFadeIn
+	EffectFadeOut
+	EffectArpFast
+	EffectArpSlow
+)
+
+type Note struct {
+	Pitch    uint8 // 0-63, 33 is A4
+	Waveform uint8 // 0-7
+	Volume   uint8 // 0-7, 0 is silent
+	Effect   uint8 // 0-7
+}
+
+func DecodeNote(v uint16) Note {
+	return Note{
+		Pitch:    uint8(v & 0x3f),
+		Waveform: uint8(v >> 6 & 7),
+		Volume:   uint8(v >> 9 & 7),
+		Effect:   uint8(v >> 12 & 7),
+	}
+}
+
+func (n Note) Encode() uint16 {
+	return uint16(n.Pitch&0x3f) | uint16(n.Waveform&7)<<6 | uint16(n.Volume&7)<<9 | uint16(n.Effect&7)<<12
+}
+
+type Pattern struct {
+	SFX       [Channels]uint8
+	Enabled   [Channels]bool
+	LoopStart bool
+	LoopEnd   bool
+	Stop      bool
+}
+
+type Bank struct {
+	SFX   []byte
+	Music []byte
+}
+
+func NewBank() *Bank {
+	return &Bank{
+		SFX:   make([]byte, SFXCount*SFXSize),
+		Music: make([]byte, PatternCount*PatternSize),
+	}
+}
+
+func (b *Bank) sfx(n int) []byte {
+	n &= SFXCount - 1
+	return b.SFX[n*SFXSize : (n+1)*SFXSize]
+}
+
+func (b *Bank) Note(n, i int) Note {
+	s := b.sfx(n)
+	i &= NoteCount - 1
+	return DecodeNote(uint16(s[i*2]) | uint16(s[i*2+1])<<8)
+}
+
+func (b *Bank) SetNote(n, i int, note Note) {
+	s := b.sfx(n)
+	i &= NoteCount - 1
+	v := note.Encode()
+	s[i*2] = byte(v)
+	s[i*2+1] = byte(v >> 8)
+}
+
+// Speed is the length of each note in ticks of 1/120s, 0 means the default
+func (b *Bank) Speed(n int) int {
+	if speed := b.sfx(n)[NoteCount*2]; speed != 0 {
+		return int(speed)
+	}
+	return DefaultSpeed
+}
+
+func (b *Bank) SetSpeed(n, speed int) {
+	b.sfx(n)[NoteCount*2] = uint8(min(max(speed, 1), 255))
+}
+
+// Loop returns the loop points, the sfx loops when end > start
+func (b *Bank) Loop(n int) (start, end int) {
+	s := b.sfx(n)
+	return int(s[NoteCount*2+1]), int(s[NoteCount*2+2])
+}
+
+func (b *Bank) SetLoop(n, start, end int) {
+	s := b.sfx(n)
+	s[NoteCount*2+1] = uint8(min(max(start, 0), NoteCount))
+	s[NoteCount*2+2] = uint8(min(max(end, 0), NoteCount))
+}
+
+func (b *Bank) Pattern(n int) Pattern {
+	n &= PatternCount - 1
+	raw := b.Music[n*PatternSize : (n+1)*PatternSize]
+	var p Pattern
+	for c := 0; c < Channels; c++ {
+		p.SFX[c] = raw[c] & 0x3f
+		p.Enabled[c] = raw[c]&0x40 == 0
+	}
+	p.LoopStart = raw[0]&0x80 != 0
+	p.LoopEnd = raw[1]&0x80 != 0
+	p.Stop = raw[2]&0x80 != 0
+	return p
+}
+
+func (b *Bank) SetPattern(n int, p Pattern) {
+	n &= PatternCount - 1
+	raw := b.Music[n*PatternSize : (n+1)*PatternSize]
+	for c := 0; c < Channels; c++ {
+		raw[c] = p.SFX[c] & 0x3f
+		if !p.Enabled[c] {
+			raw[c] |= 0x40
+		}
+	}
+	if p.LoopStart {
+		raw[0] |= 0x80
+	}
+	if p.LoopEnd {
+		raw[1] |= 0x80
+	}
+	if p.Stop {
+		raw[2] |= 0x80
+	}
+}
+
+// IsEmpty reports whether an sfx has no audible notes
+func (b *Bank) IsEmpty(n int) bool {
+	for i := 0; i < NoteCount; i++ {
+		if b.Note(n, i).Volume > 0 {
+			return false
+		}
+	}
+	return true
+}
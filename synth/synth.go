@@ -0,0 +1,432 @@
+package synth
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// one tick is 183 samples at 22050Hz, roughly 1/120s. note length is
+	// speed * ticks.
+	BaseSampleRate = 22050
+	TickSamples    = 183
+
+	channelGain = 0.3
+	vibratoHz   = 7.5
+)
+
+type channel struct {
+	sfx       int // -1 when idle
+	note      int
+	pos       int // samples into the current note
+	phase     float64
+	phase2    float64
+	noise     float64
+	seed      uint32
+	prevPitch float64
+	release   bool // ignore the loop points from now on
+	music     bool // started by the music player
+}
+
+// Synth renders the sfx of a bank on 4 channels. it is safe to call from the
+// game loop while an audio player reads from it.
+type Synth struct {
+	Bank       *Bank // the game loop's, poke and the editors write it
+	SampleRate int
+
+	mu       sync.Mutex
+	bank     *Bank // what the audio plays, a copy of Bank made by Sync
+	channels [Channels]channel
+	clock    int
+
+	pattern      int // -1 when no music is playing
+	startPattern int
+	patternLeft  int // samples until the next pattern
+	musicVolume  float64
+	fadeStep     float64 // change of musicVolume per sample
+}
+
+func New(bank *Bank, sampleRate int) *Synth {
+	s := &Synth{
+		Bank:        bank,
+		SampleRate:  sampleRate,
+		bank:        NewBank(),
+		pattern:     -1,
+		musicVolume: 1,
+	}
+	for i := range s.channels {
+		s.channels[i].sfx = -1
+		s.channels[i].seed = uint32(i*7919 + 1)
+	}
+	s.sync()
+	return s
+}
+
+// Sync copies Bank into what the audio plays from. Bank is only read and
+// written on the game loop, so call it there whenever it may have changed.
+// starting an sfx or music syncs too.
+func (s *Synth) Sync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sync()
+}
+
+func (s *Synth) sync() {
+	copy(s.bank.SFX, s.Bank.SFX)
+	copy(s.bank.Music, s.Bank.Music)
+}
+
+func (s *Synth) tickSamples() int {
+	return max(TickSamples*s.SampleRate/BaseSampleRate, 1)
+}
+
+// noteSamples is how long one note of sfx n lasts, the lock must be held
+func (s *Synth) noteSamples(n int) int {
+	return s.bank.Speed(n) * s.tickSamples()
+}
+
+// PlaySFX starts sfx n on channel ch, or on a free channel when ch is -1.
+// n = -1 stops the channel (or every sfx when ch is -1), n = -2 lets a
+// looping sfx run to its end, other negative n do nothing.
+func (s *Synth) PlaySFX(n, ch int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch n {
+	case -1:
+		for i := range s.channels {
+			if ch == i || (ch < 0 && !s.channels[i].music) {
+				s.channels[i].sfx = -1
+			}
+		}
+		return
+	case -2:
+		for i := range s.channels {
+			if ch == i || ch < 0 {
+				s.channels[i].release = true
+			}
+		}
+		return
+	}
+	if n < 0 {
+		return
+	}
+
+	if ch < 0 || ch >= Channels {
+		ch = s.freeChannel()
+	}
+	s.sync()
+	s.start(ch, n, false)
+}
+
+// prefers idle channels, then ones not used by the music
+func (s *Synth) freeChannel() int {
+	for i := range s.channels {
+		if s.channels[i].sfx < 0 {
+			return i
+		}
+	}
+	for i := range s.channels {
+		if !s.channels[i].music {
+			return i
+		}
+	}
+	return 0
+}
+
+func (s *Synth) start(ch, n int, music bool) {
+	c := &s.channels[ch]
+	c.sfx = n & (SFXCount - 1)
+	c.note = 0
+	c.pos = 0
+	c.release = false
+	c.music = music
+	c.prevPitch = float64(s.bank.Note(c.sfx, 0).Pitch)
+}
+
+// Playing returns the sfx on a channel and its current note, or -1, -1
+func (s *Synth) Playing(ch int) (sfx, note int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.channels[ch&(Channels-1)]
+	if c.sfx < 0 {
+		return -1, -1
+	}
+	return c.sfx, c.note
+}
+
+// PlayMusic starts the song at pattern n, fading in over fadeMs. n = -1
+// stops the music, fading out over fadeMs.
+func (s *Synth) PlayMusic(n int, fadeMs int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fadeSamples := float64(fadeMs) * float64(s.SampleRate) / 1000
+	if n < 0 {
+		if s.pattern < 0 {
+			return
+		}
+		if fadeSamples <= 0 {
+			s.stopMusic()
+			return
+		}
+		s.fadeStep = -s.musicVolume / fadeSamples
+		return
+	}
+
+	s.sync()
+	s.startPattern = n & (PatternCount - 1)
+	s.musicVolume = 1
+	s.fadeStep = 0
+	if fadeSamples > 0 {
+		s.musicVolume = 0
+		s.fadeStep = 1 / fadeSamples
+	}
+	s.playPattern(s.startPattern)
+}
+
+// Pattern is the pattern the music is playing, or -1
+func (s *Synth) Pattern() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pattern
+}
+
+func (s *Synth) stopMusic() {
+	s.pattern = -1
+	for i := range s.channels {
+		if s.channels[i].music {
+			s.channels[i].sfx = -1
+			s.channels[i].music = false
+		}
+	}
+}
+
+func (s *Synth) playPattern(n int) {
+	p := s.bank.Pattern(n)
+	silent := true
+	for c := 0; c < Channels; c++ {
+		if p.Enabled[c] && !s.bank.IsEmpty(int(p.SFX[c])) {
+			silent = false
+		}
+	}
+	if silent {
+		s.stopMusic()
+		return
+	}
+
+	s.pattern = n
+	// the pattern lasts as long as its first non looping channel, or the
+	// first channel when they all loop
+	leader := -1
+	for c := 0; c < Channels; c++ {
+		if !p.Enabled[c] {
+			continue
+		}
+		start, end := s.bank.Loop(int(p.SFX[c]))
+		if leader < 0 || (end <= start && s.loops(int(p.SFX[leader]))) {
+			leader = c
+		}
+	}
+	s.patternLeft = NoteCount * s.noteSamples(int(p.SFX[leader]))
+
+	for c := 0; c < Channels; c++ {
+		ch := &s.channels[c]
+		if p.Enabled[c] {
+			s.start(c, int(p.SFX[c]), true)
+		} else if ch.music {
+			ch.sfx = -1
+			ch.music = false
+		}
+	}
+}
+
+func (s *Synth) loops(n int) bool {
+	start, end := s.bank.Loop(n)
+	return end > start
+}
+
+func (s *Synth) nextPattern() {
+	p := s.bank.Pattern(s.pattern)
+	switch {
+	case p.Stop:
+		s.stopMusic()
+	case p.LoopEnd:
+		// back to the closest loop start, or where the song started
+		target := s.startPattern
+		for i := s.pattern; i >= 0; i-- {
+			if s.bank.Pattern(i).LoopStart {
+				target = i
+				break
+			}
+		}
+		s.playPattern(target)
+	case s.pattern+1 >= PatternCount:
+		s.stopMusic()
+	default:
+		s.playPattern(s.pattern + 1)
+	}
+}
+
+// Render fills buf with mono samples in [-1, 1]
+func (s *Synth) Render(buf []float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range buf {
+		var mix float64
+		for c := range s.channels {
+			ch := &s.channels[c]
+			if ch.sfx < 0 {
+				continue
+			}
+			v := s.sample(ch) * channelGain
+			if ch.music {
+				v *= s.musicVolume
+			}
+			mix += v
+		}
+		buf[i] = float32(min(max(mix, -1), 1))
+		s.clock++
+
+		if s.pattern >= 0 {
+			if s.fadeStep != 0 {
+				s.musicVolume += s.fadeStep
+				if s.musicVolume >= 1 {
+					s.musicVolume = 1
+					s.fadeStep = 0
+				} else if s.musicVolume <= 0 {
+					s.musicVolume = 0
+					s.fadeStep = 0
+					s.stopMusic()
+					continue
+				}
+			}
+			s.patternLeft--
+			if s.patternLeft <= 0 {
+				s.nextPattern()
+			}
+		}
+	}
+}
+
+// Read renders 16 bit little endian stereo, for audio players. it never
+// runs out.
+func (s *Synth) Read(p []byte) (int, error) {
+	frames := len(p) / 4
+	buf := make([]float32, frames)
+	s.Render(buf)
+	for i, v := range buf {
+		sample := int16(v * math.MaxInt16)
+		p[i*4] = byte(sample)
+		p[i*4+1] = byte(sample >> 8)
+		p[i*4+2] = byte(sample)
+		p[i*4+3] = byte(sample >> 8)
+	}
+	return frames * 4, nil
+}
+
+func PitchFrequency(pitch float64) float64 {
+	return 440 * math.Pow(2, (pitch-33)/12)
+}
+
+// sample renders one sample of a channel and moves it forward
+func (s *Synth) sample(c *channel) float64 {
+	noteLen := s.noteSamples(c.sfx)
+	note := s.bank.Note(c.sfx, c.note)
+	t := float64(c.pos) / float64(noteLen)
+	pitch := float64(note.Pitch)
+	volume := float64(note.Volume) / 7
+
+	switch note.Effect {
+	case EffectSlide:
+		pitch = c.prevPitch + (pitch-c.prevPitch)*t
+	case EffectVibrato:
+		pitch += 0.5 * math.Sin(2*math.Pi*vibratoHz*float64(s.clock)/float64(s.SampleRate))
+	case EffectFadeIn:
+		volume *= t
+	case EffectFadeOut:
+		volume *= 1 - t
+	case EffectArpFast, EffectArpSlow:
+		// cycle through the group of 4 notes this one belongs to
+		ticks := 4
+		if note.Effect == EffectArpSlow {
+			ticks = 8
+		}
+		step := (c.note*noteLen + c.pos) / (ticks * s.tickSamples()) % 4
+		pitch = float64(s.bank.Note(c.sfx, c.note&^3+step).Pitch)
+	}
+
+	freq := PitchFrequency(pitch)
+	if note.Effect == EffectDrop {
+		freq *= 1 - t
+	}
+
+	var out float64
+	if volume > 0 {
+		out = c.oscillator(note.Waveform) * volume
+	}
+
+	step := freq / float64(s.SampleRate)
+	c.phase += step
+	c.phase2 += step * 1.0075
+	if note.Waveform == WaveNoise {
+		// noise gets a new random level several times per cycle
+		if int(c.phase*8) != int((c.phase-step)*8) {
+			c.seed = c.seed*1664525 + 1013904223
+			c.noise = float64(c.seed>>8)/float64(1<<23) - 1
+		}
+	}
+	c.phase -= math.Floor(c.phase)
+	c.phase2 -= math.Floor(c.phase2)
+
+	c.pos++
+	if c.pos >= noteLen {
+		c.pos = 0
+		c.prevPitch = float64(note.Pitch)
+		c.note++
+		if start, end := s.bank.Loop(c.sfx); end > start && !c.release && c.note >= end {
+			c.note = start
+		}
+		if c.note >= NoteCount {
+			c.sfx = -1
+		}
+	}
+	return out
+}
+
+func triangle(phase float64) float64 {
+	return math.Abs(4*phase-2) - 1
+}
+
+func (c *channel) oscillator(waveform uint8) float64 {
+	p := c.phase
+	switch waveform {
+	case WaveTiltedSaw:
+		if p < 0.875 {
+			return p/0.875*2 - 1
+		}
+		return 1 - (p-0.875)/0.125*2
+	case WaveSaw:
+		return 2*p - 1
+	case WaveSquare:
+		if p < 0.5 {
+			return 1
+		}
+		return -1
+	case WavePulse:
+		if p < 0.3125 {
+			return 1
+		}
+		return -1
+	case WaveOrgan:
+		p2 := p * 2
+		return (triangle(p) + triangle(p2-math.Floor(p2))) / 2
+	case WaveNoise:
+		return c.noise
+	case WavePhaser:
+		return (triangle(p) + triangle(c.phase2)) / 2
+	default:
+		return triangle(p)
+	}
+}
@@ -0,0 +1,274 @@
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+const testRate = BaseSampleRate
+
+// fills sfx n with one note repeated on every step
+func fillSFX(b *Bank, n int, note Note, speed int) {
+	for i := 0; i < NoteCount; i++ {
+		b.SetNote(n, i, note)
+	}
+	b.SetSpeed(n, speed)
+}
+
+func render(s *Synth, samples int) []float32 {
+	buf := make([]float32, samples)
+	s.Render(buf)
+	return buf
+}
+
+func TestNoteEncoding(t *testing.T) {
+	tests := []Note{
+		{},
+		{Pitch: 33, Waveform: WaveSquare, Volume: 5, Effect: EffectVibrato},
+		{Pitch: 63, Waveform: WavePhaser, Volume: 7, Effect: EffectArpSlow},
+		{Pitch: 1, Waveform: WaveNoise, Volume: 1, Effect: EffectDrop},
+	}
+
+	for i, tt := range tests {
+		b := NewBank()
+		b.SetNote(3, i, tt)
+		if got := b.Note(3, i); got != tt {
+			t.Fatalf("tests[%d] - note wrong. expected=%+v, got=%+v", i, tt, got)
+		}
+	}
+}
+
+func TestPatternEncoding(t *testing.T) {
+	tests := []Pattern{
+		{Enabled: [Channels]bool{true, true, true, true}},
+		{SFX: [Channels]uint8{1, 2, 63, 0}, Enabled: [Channels]bool{true, false, true, false}, LoopStart: true},
+		{SFX: [Channels]uint8{5}, Enabled: [Channels]bool{true}, LoopEnd: true, Stop: true},
+	}
+
+	for i, tt := range tests {
+		b := NewBank()
+		b.SetPattern(i, tt)
+		if got := b.Pattern(i); got != tt {
+			t.Fatalf("tests[%d] - pattern wrong. expected=%+v, got=%+v", i, tt, got)
+		}
+	}
+}
+
+func TestSilence(t *testing.T) {
+	b := NewBank()
+	fillSFX(b, 0, Note{Pitch: 33, Waveform: WaveSquare}, 1)
+	s := New(b, testRate)
+	s.PlaySFX(0, -1)
+
+	for i, v := range render(s, 1000) {
+		if v != 0 {
+			t.Fatalf("sample %d not silent. got=%v", i, v)
+		}
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	tests := []struct {
+		pitch    uint8
+		expected float64
+	}{
+		{33, 440},
+		{21, 220},
+		{45, 880},
+	}
+
+	for i, tt := range tests {
+		b := NewBank()
+		fillSFX(b, 0, Note{Pitch: tt.pitch, Waveform: WaveSquare, Volume: 7}, 255)
+		s := New(b, testRate)
+		s.PlaySFX(0, 0)
+
+		// a square wave changes sign twice per cycle
+		buf := render(s, testRate)
+		crossings := 0
+		for j := 1; j < len(buf); j++ {
+			if (buf[j-1] < 0) != (buf[j] < 0) {
+				crossings++
+			}
+		}
+		got := float64(crossings) / 2
+		if math.Abs(got-tt.expected) > 2 {
+			t.Fatalf("tests[%d] - frequency wrong. expected=%v, got=%v", i, tt.expected, got)
+		}
+	}
+}
+
+func TestSFXLength(t *testing.T) {
+	b := NewBank()
+	fillSFX(b, 2, Note{Pitch: 20, Waveform: WaveTriangle, Volume: 4}, 2)
+	s := New(b, testRate)
+	s.PlaySFX(2, 1)
+
+	length := NoteCount * 2 * TickSamples
+	render(s, length-1)
+	if sfx, note := s.Playing(1); sfx != 2 || note != NoteCount-1 {
+		t.Fatalf("sfx stopped early. expected=2,%d, got=%d,%d", NoteCount-1, sfx, note)
+	}
+	render(s, 1)
+	if sfx, _ := s.Playing(1); sfx != -1 {
+		t.Fatalf("sfx still playing. got=%d", sfx)
+	}
+	for i, v := range render(s, 100) {
+		if v != 0 {
+			t.Fatalf("sample %d after the end not silent. got=%v", i, v)
+		}
+	}
+}
+
+func TestLoop(t *testing.T) {
+	b := NewBank()
+	fillSFX(b, 0, Note{Pitch: 30, Waveform: WaveSaw, Volume: 3}, 1)
+	b.SetLoop(0, 4, 8)
+	s := New(b, testRate)
+	s.PlaySFX(0, 0)
+
+	render(s, 100*TickSamples)
+	sfx, note := s.Playing(0)
+	if sfx != 0 || note < 4 || note >= 8 {
+		t.Fatalf("sfx not looping. got=%d,%d", sfx, note)
+	}
+
+	// releasing lets it play to the end
+	s.PlaySFX(-2, 0)
+	render(s, NoteCount*TickSamples)
+	if sfx, _ := s.Playing(0); sfx != -1 {
+		t.Fatalf("released sfx still playing. got=%d", sfx)
+	}
+}
+
+func TestFreeChannel(t *testing.T) {
+	b := NewBank()
+	fillSFX(b, 0, Note{Pitch: 30, Volume: 3}, 16)
+	s := New(b, testRate)
+
+	for ch := 0; ch < Channels; ch++ {
+		s.PlaySFX(0, -1)
+		if sfx, _ := s.Playing(ch); sfx != 0 {
+			t.Fatalf("channel %d not picked. got=%d", ch, sfx)
+		}
+	}
+
+	s.PlaySFX(-1, -1)
+	for ch := 0; ch < Channels; ch++ {
+		if sfx, _ := s.Playing(ch); sfx != -1 {
+			t.Fatalf("channel %d not stopped. got=%d", ch, sfx)
+		}
+	}
+}
+
+func TestMusic(t *testing.T) {
+	b := NewBank()
+	fillSFX(b, 0, Note{Pitch: 30, Volume: 3}, 1)
+	fillSFX(b, 1, Note{Pitch: 40, Volume: 3}, 1)
+	b.SetPattern(0, Pattern{SFX: [Channels]uint8{0, 1}, Enabled: [Channels]bool{true, true}, LoopStart: true})
+	b.SetPattern(1, Pattern{SFX: [Channels]uint8{1}, Enabled: [Channels]bool{true}, LoopEnd: true})
+	b.SetPattern(2, Pattern{SFX: [Channels]uint8{1}, Enabled: [Channels]bool{true}, Stop: true})
+
+	s := New(b, testRate)
+	s.PlayMusic(0, 0)
+	patternLen := NoteCount * TickSamples
+
+	tests := []struct {
+		pattern int
+		ch1     int
+	}{
+		{0, 1},
+		{1, -1},
+		{0, 1},
+		{1, -1},
+	}
+	for i, tt := range tests {
+		if got := s.Pattern(); got != tt.pattern {
+			t.Fatalf("tests[%d] - pattern wrong. expected=%d, got=%d", i, tt.pattern, got)
+		}
+		if got, _ := s.Playing(1); got != tt.ch1 {
+			t.Fatalf("tests[%d] - channel 1 wrong. expected=%d, got=%d", i, tt.ch1, got)
+		}
+		render(s, patternLen)
+	}
+
+	s.PlayMusic(2, 0)
+	render(s, patternLen)
+	if got := s.Pattern(); got != -1 {
+		t.Fatalf("music didn't stop. got=%d", got)
+	}
+
+	// fading out stops the music once silent
+	s.PlayMusic(0, 0)
+	s.PlayMusic(-1, 10)
+	render(s, testRate/100+1)
+	if got := s.Pattern(); got != -1 {
+		t.Fatalf("music didn't fade out. got=%d", got)
+	}
+}
+
+func TestEmptyPatternStops(t *testing.T) {
+	b := NewBank()
+	s := New(b, testRate)
+	s.PlayMusic(0, 0)
+	if got := s.Pattern(); got != -1 {
+		t.Fatalf("empty pattern played. got=%d", got)
+	}
+}
+
+func TestNegativeSFXIgnored(t *testing.T) {
+	b := NewBank()
+	fillSFX(b, 61, Note{Pitch: 30, Volume: 3}, 16)
+	fillSFX(b, 62, Note{Pitch: 30, Volume: 3}, 16)
+	s := New(b, testRate)
+
+	for _, n := range []int{-3, -66, -1000} {
+		s.PlaySFX(n, -1)
+		s.PlaySFX(n, 2)
+	}
+	for ch := 0; ch < Channels; ch++ {
+		if sfx, _ := s.Playing(ch); sfx != -1 {
+			t.Fatalf("channel %d playing. got=%d", ch, sfx)
+		}
+	}
+}
+
+func TestSyncBank(t *testing.T) {
+	b := NewBank()
+	fillSFX(b, 0, Note{Pitch: 30, Waveform: WaveSquare, Volume: 7}, 255)
+	s := New(b, testRate)
+	s.PlaySFX(0, 0)
+
+	// the audio keeps playing its copy until the game syncs
+	fillSFX(b, 0, Note{}, 255)
+	if silent(render(s, 100)) {
+		t.Fatalf("edit reached the audio before Sync")
+	}
+	s.Sync()
+	if !silent(render(s, 100)) {
+		t.Fatalf("edit didn't reach the audio after Sync")
+	}
+
+	// the game writing and syncing while the audio renders
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			render(s, 64)
+		}
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		b.SetNote(0, i, Note{Pitch: uint8(i), Volume: 5})
+		s.Sync()
+	}
+	<-done
+}
+
+func silent(buf []float32) bool {
+	for _, v := range buf {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
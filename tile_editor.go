@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+
+	"github.com/mrdapoyo/dofi/gfx"
+)
+
+const MaxTileUndo = 64
+
+// layout of the tile tab, in content coordinates
+var (
+	tileMapRect    = image.Rect(0, 0, 128, 80)
+	tileFilterRect = image.Rect(2, 83, 50, 88)
+	tilePagesRect  = image.Rect(2, 92, 82, 99)
+	tileSheetRect  = image.Rect(0, 102, 128, 118)
+)
+
+const tilePageRows = 2 // rows of sprites in the sheet strip
+
+type TileEditor struct {
+	Sprite   uint8
+	CameraX  int // top left cell of the view
+	CameraY  int
+	CellSize int // 8, 4 or 2 pixels per cell on screen
+	Page     int
+	Filter   uint8 // flag mask, cells that don't match are dimmed
+
+	painting   bool
+	panning    bool
+	panX, panY int
+	undo, redo [][]uint8
+}
+
+func NewTileEditor() TileEditor {
+	return TileEditor{
+		Sprite:   1,
+		CellSize: 8,
+	}
+}
+
+func (e *TileEditor) viewCells() (int, int) {
+	return tileMapRect.Dx() / e.CellSize, tileMapRect.Dy() / e.CellSize
+}
+
+// keeps the view inside the map
+func (e *TileEditor) clampCamera() {
+	w, h := e.viewCells()
+	e.CameraX = min(max(e.CameraX, 0), max(gfx.MapWidth-w, 0))
+	e.CameraY = min(max(e.CameraY, 0), max(gfx.MapHeight-h, 0))
+}
+
+// the map cell under content coordinates x, y
+func (e *TileEditor) cellAt(x, y int) (int, int) {
+	return e.CameraX + (x-tileMapRect.Min.X)/e.CellSize, e.CameraY + (y-tileMapRect.Min.Y)/e.CellSize
+}
+
+func (e *TileEditor) PushUndo(m *gfx.TileMap) {
+	e.undo = append(e.undo, append([]uint8(nil), m.Cells...))
+	if len(e.undo) > MaxTileUndo {
+		e.undo = e.undo[1:]
+	}
+	e.redo = nil
+}
+
+func (e *TileEditor) Undo(m *gfx.TileMap) {
+	if len(e.undo) == 0 {
+		return
+	}
+	e.redo = append(e.redo, append([]uint8(nil), m.Cells...))
+	copy(m.Cells, e.undo[len(e.undo)-1])
+	e.undo = e.undo[:len(e.undo)-1]
+}
+
+func (e *TileEditor) Redo(m *gfx.TileMap) {
+	if len(e.redo) == 0 {
+		return
+	}
+	e.undo = append(e.undo, append([]uint8(nil), m.Cells...))
+	copy(m.Cells, e.redo[len(e.redo)-1])
+	e.redo = e.redo[:len(e.redo)-1]
+}
+
+func (g *Game) UpdateTileEditor() error {
+	e := &g.TileEditor
+	m := g.Map
+	mx, my := g.contentMouse()
+	mouse := image.Pt(mx, my)
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+
+	if ctrl {
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyZ):
+			e.Undo(m)
+		case inpututil.IsKeyJustPressed(ebiten.KeyY):
+			e.Redo(m)
+		}
+	} else {
+		step := 1
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			step = 8
+		}
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyLeft):
+			e.CameraX -= step
+		case inpututil.IsKeyJustPressed(ebiten.KeyRight):
+			e.CameraX += step
+		case inpututil.IsKeyJustPressed(ebiten.KeyUp):
+			e.CameraY -= step
+		case inpututil.IsKeyJustPressed(ebiten.KeyDown):
+			e.CameraY += step
+		}
+	}
+
+	// mouse wheel zooms around the cell under the cursor
+	if _, wheel := ebiten.Wheel(); wheel != 0 && mouse.In(tileMapRect) {
+		cx, cy := e.cellAt(mx, my)
+		if wheel > 0 && e.CellSize < 8 {
+			e.CellSize *= 2
+		} else if wheel < 0 && e.CellSize > 2 {
+			e.CellSize /= 2
+		}
+		e.CameraX = cx - (mx-tileMapRect.Min.X)/e.CellSize
+		e.CameraY = cy - (my-tileMapRect.Min.Y)/e.CellSize
+	}
+
+	// right drag pans
+	if e.panning {
+		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) {
+			dx := (e.panX - mx) / e.CellSize
+			dy := (e.panY - my) / e.CellSize
+			e.CameraX += dx
+			e.CameraY += dy
+			e.panX -= dx * e.CellSize
+			e.panY -= dy * e.CellSize
+		} else {
+			e.panning = false
+		}
+	} else if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) && mouse.In(tileMapRect) {
+		e.panning = true
+		e.panX, e.panY = mx, my
+	}
+	e.clampCamera()
+
+	// middle click picks the sprite under the cursor
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) && mouse.In(tileMapRect) {
+		e.Sprite = m.Get(e.cellAt(mx, my))
+	}
+
+	if e.painting {
+		if g.Input.IsMouseDown {
+			if mouse.In(tileMapRect) {
+				x, y := e.cellAt(mx, my)
+				m.Set(x, y, e.Sprite)
+			}
+		} else {
+			e.painting = false
+		}
+		return nil
+	}
+
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return nil
+	}
+
+	switch {
+	case mouse.In(tileMapRect):
+		e.PushUndo(m)
+		e.painting = true
+		x, y := e.cellAt(mx, my)
+		m.Set(x, y, e.Sprite)
+	case mouse.In(tileFilterRect):
+		e.Filter ^= 1 << ((mx - tileFilterRect.Min.X) / 6)
+	case mouse.In(tilePagesRect):
+		e.Page = min((mx-tilePagesRect.Min.X)/10, gfx.SheetHeight/gfx.SpriteSize/tilePageRows-1)
+	case mouse.In(tileSheetRect):
+		x := mx - tileSheetRect.Min.X
+		y := my - tileSheetRect.Min.Y + e.Page*tilePageRows*gfx.SpriteSize
+		e.Sprite = uint8(gfx.SpriteAt(x, y))
+	}
+	return nil
+}
+
+func (g *Game) DrawTileEditor(screen *ebiten.Image) {
+	e := &g.TileEditor
+	screen.Fill(g.Screen.CliBgColor)
+	white := g.Screen.Palette[7]
+	grey := g.Screen.Palette[5]
+
+	// render the visible cells at 8 pixels each, then scale down
+	w, h := e.viewCells()
+	view := gfx.NewFramebuffer(w*gfx.SpriteSize, h*gfx.SpriteSize)
+	state := gfx.NewDrawState()
+	canvas := gfx.Canvas{FB: view, State: &state}
+	canvas.Map(g.Map, g.Sprites, e.CameraX, e.CameraY, 0, 0, w, h, 0)
+
+	mapOp := &ebiten.DrawImageOptions{}
+	scale := float64(e.CellSize) / gfx.SpriteSize
+	mapOp.GeoM.Scale(scale, scale)
+	mapOp.GeoM.Translate(float64(tileMapRect.Min.X), float64(tileMapRect.Min.Y))
+	screen.DrawImage(g.indexedImage(view, image.Rect(0, 0, view.Width, view.Height)), mapOp)
+
+	// dim everything the flag filter hides
+	if e.Filter != 0 {
+		shade := ebiten.NewImage(e.CellSize, e.CellSize)
+		shade.Fill(color.RGBA{0, 0, 0, 170})
+		for j := 0; j < h; j++ {
+			for i := 0; i < w; i++ {
+				if n := g.Map.Get(e.CameraX+i, e.CameraY+j); !g.Sprites.Matches(n, e.Filter) {
+					op := &ebiten.DrawImageOptions{}
+					op.GeoM.Translate(float64(tileMapRect.Min.X+i*e.CellSize), float64(tileMapRect.Min.Y+j*e.CellSize))
+					screen.DrawImage(shade, op)
+				}
+			}
+		}
+	}
+
+	mx, my := g.contentMouse()
+	info := fmt.Sprintf("%d,%d", e.CameraX, e.CameraY)
+	if image.Pt(mx, my).In(tileMapRect) {
+		cx, cy := e.cellAt(mx, my)
+		cell := image.Rect(0, 0, e.CellSize, e.CellSize).Add(image.Pt(
+			tileMapRect.Min.X+(cx-e.CameraX)*e.CellSize, tileMapRect.Min.Y+(cy-e.CameraY)*e.CellSize))
+		strokeRect(screen, cell.Inset(-1), white)
+		info = fmt.Sprintf("%d,%d", cx, cy)
+	}
+	drawLabel(screen, 56, 83, info, white)
+	drawLabel(screen, 104, 83, fmt.Sprintf("#%03d", e.Sprite), white)
+
+	// flag filter
+	for f := 0; f < gfx.SpriteFlags; f++ {
+		r := image.Rect(0, 0, 5, 5).Add(tileFilterRect.Min).Add(image.Pt(f*6, 0))
+		if e.Filter>>f&1 == 1 {
+			fillRect(screen, r, g.Screen.Palette[8+f])
+		} else {
+			fillRect(screen, r, grey)
+		}
+	}
+
+	// sheet pages
+	for p := 0; p < gfx.SheetHeight/gfx.SpriteSize/tilePageRows; p++ {
+		r := image.Rect(0, 0, 9, 7).Add(tilePagesRect.Min).Add(image.Pt(p*10, 0))
+		if p == e.Page {
+			fillRect(screen, r, g.Navbar.TabColor)
+		} else {
+			fillRect(screen, r, grey)
+		}
+		drawLabel(screen, r.Min.X+3, r.Min.Y+1, fmt.Sprint(p), white)
+	}
+
+	pageY := e.Page * tilePageRows * gfx.SpriteSize
+	strip := image.Rect(0, pageY, gfx.SheetWidth, pageY+tileSheetRect.Dy())
+	stripOp := &ebiten.DrawImageOptions{}
+	stripOp.GeoM.Translate(float64(tileSheetRect.Min.X), float64(tileSheetRect.Min.Y))
+	screen.DrawImage(g.indexedImage(g.Sprites.Pixels, strip), stripOp)
+
+	sx, sy := gfx.SpriteOrigin(int(e.Sprite))
+	selected := image.Rect(sx, sy-pageY, sx+gfx.SpriteSize, sy-pageY+gfx.SpriteSize).Add(tileSheetRect.Min)
+	if selected.Overlaps(tileSheetRect) {
+		strokeRect(screen, selected.Inset(-1), white)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+
+	"github.com/mrdapoyo/dofi/gfx"
+)
+
+// small drawing helpers shared by the editor tabs
+
+func fillRect(dst *ebiten.Image, r image.Rectangle, c color.Color) {
+	r = r.Intersect(dst.Bounds())
+	if r.Empty() {
+		return
+	}
+	dst.SubImage(r).(*ebiten.Image).Fill(c)
+}
+
+func strokeRect(dst *ebiten.Image, r image.Rectangle, c color.Color) {
+	fillRect(dst, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1), c)
+	fillRect(dst, image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), c)
+	fillRect(dst, image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y), c)
+	fillRect(dst, image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y), c)
+}
+
+func drawLabel(dst *ebiten.Image, x, y int, value string, c color.Color) {
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(x), float64(y))
+	op.ColorScale.ScaleWithColor(c)
+	text.Draw(dst, value, TextFace, op)
+}
+
+// indexedImage turns part of a palette indexed buffer into an image, using
+// the real palette colors (editors ignore the display palette)
+func (g *Game) indexedImage(fb *gfx.Framebuffer, r image.Rectangle) *ebiten.Image {
+	r = r.Intersect(image.Rect(0, 0, fb.Width, fb.Height))
+	img := ebiten.NewImage(max(r.Dx(), 1), max(r.Dy(), 1))
+	if r.Empty() {
+		return img
+	}
+	pixels := make([]byte, r.Dx()*r.Dy()*4)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := g.Screen.Palette[fb.Get(x, y)]
+			i := ((y-r.Min.Y)*r.Dx() + x - r.Min.X) * 4
+			pixels[i], pixels[i+1], pixels[i+2], pixels[i+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	img.WritePixels(pixels)
+	return img
+}
+
+// mouse position relative to the content area below the navbar
+func (g *Game) contentMouse() (int, int) {
+	return g.Input.MouseX, g.Input.MouseY - g.Navbar.NavbarHeight
+}